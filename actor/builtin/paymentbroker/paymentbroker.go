@@ -658,7 +658,7 @@ const separator = 0x0
 // SignVoucher creates the signature for the given combination of
 // channel, amount, validAt (earliest block height for redeem) and from address.
 // It does so by signing the following bytes: (channelID | 0x0 | amount | 0x0 | validAt)
-func SignVoucher(channelID *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight, addr address.Address, condition *types.Predicate, signer types.Signer) (types.Signature, error) {
+func SignVoucher(channelID *types.ChannelID, amount *types.AttoFIL, validAt *types.BlockHeight, addr address.Address, condition *types.Predicate, signer types.Signer) (types.RawSignature, error) {
 	data, err := createVoucherSignatureData(channelID, amount, validAt, condition)
 	if err != nil {
 		return nil, err
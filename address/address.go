@@ -2,6 +2,7 @@ package address
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -90,6 +91,20 @@ func (a Address) String() string {
 	return str
 }
 
+// StringForNetwork returns the address encoded as a string for network.
+func (a Address) StringForNetwork(network Network) (string, error) {
+	return encode(network, a)
+}
+
+// ID returns the actor ID of an ID address. ok is false if the address does not use the
+// ID protocol.
+func (a Address) ID() (id uint64, ok bool) {
+	if a.Empty() || a.Protocol() != ID {
+		return 0, false
+	}
+	return leb128.ToUInt64(a.Payload()), true
+}
+
 // Empty returns true if the address is empty, false otherwise.
 func (a Address) Empty() bool {
 	return a == Undef
@@ -162,6 +177,19 @@ func NewFromString(addr string) (Address, error) {
 	return decode(addr)
 }
 
+// NewFromStringForNetwork returns the address represented by the string `addr`, which
+// must be encoded for network.
+func NewFromStringForNetwork(network Network, addr string) (Address, error) {
+	prefix, err := networkPrefix(network)
+	if err != nil {
+		return Undef, err
+	}
+	if len(addr) > 0 && addr != UndefAddressString && string(addr[0]) != prefix {
+		return Undef, ErrWrongNetwork
+	}
+	return decode(addr)
+}
+
 // NewFromBytes return the address represented by the bytes `addr`.
 func NewFromBytes(addr []byte) (Address, error) {
 	if len(addr) == 0 {
@@ -191,6 +219,9 @@ func addressHash(ingest []byte) []byte {
 func newAddress(protocol Protocol, payload []byte) (Address, error) {
 	switch protocol {
 	case ID:
+		if len(payload) > binary.MaxVarintLen64 {
+			return Undef, ErrInvalidPayload
+		}
 	case SECP256K1, Actor:
 		if len(payload) != PayloadHashLength {
 			return Undef, ErrInvalidPayload
@@ -215,14 +246,9 @@ func encode(network Network, addr Address) (string, error) {
 	if addr == Undef {
 		return UndefAddressString, nil
 	}
-	var ntwk string
-	switch network {
-	case Mainnet:
-		ntwk = MainnetPrefix
-	case Testnet:
-		ntwk = TestnetPrefix
-	default:
-		return UndefAddressString, ErrUnknownNetwork
+	ntwk, err := networkPrefix(network)
+	if err != nil {
+		return UndefAddressString, err
 	}
 
 	var strAddr string
@@ -249,7 +275,7 @@ func decode(a string) (Address, error) {
 		return Undef, ErrInvalidLength
 	}
 
-	if string(a[0]) != MainnetPrefix && string(a[0]) != TestnetPrefix {
+	if _, ok := networkOfPrefix(a[:1]); !ok {
 		return Undef, ErrUnknownNetwork
 	}
 
@@ -2,6 +2,7 @@ package address
 
 import (
 	"encoding/base32"
+	"encoding/binary"
 	"fmt"
 	"math"
 	"math/rand"
@@ -22,6 +23,39 @@ func init() {
 	rand.Seed(time.Now().Unix())
 }
 
+func TestAddressEmpty(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.True(t, Undef.Empty())
+	assert.True(t, Address{}.Empty())
+
+	addr, err := NewIDAddress(1)
+	require.NoError(t, err)
+	assert.False(t, addr.Empty())
+}
+
+func TestAddressID(t *testing.T) {
+	tf.UnitTest(t)
+
+	addr, err := NewIDAddress(1234)
+	require.NoError(t, err)
+	id, ok := addr.ID()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1234), id)
+
+	parsed, err := NewFromString(addr.String())
+	require.NoError(t, err)
+	assert.Equal(t, addr, parsed)
+	id, ok = parsed.ID()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1234), id)
+
+	_, ok = NewForTestGetter()().ID()
+	assert.False(t, ok)
+	_, ok = Undef.ID()
+	assert.False(t, ok)
+}
+
 func TestRandomIDAddress(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -371,6 +405,7 @@ func TestInvalidByteAddresses(t *testing.T) {
 
 		// ID protocol
 		{[]byte{0}, ErrInvalidLength},
+		{append([]byte{0}, make([]byte, binary.MaxVarintLen64+1)...), ErrInvalidPayload},
 
 		// SECP256K1 Protocol
 		{append([]byte{1}, make([]byte, PayloadHashLength-1)...), ErrInvalidPayload},
@@ -421,3 +456,40 @@ func TestAddressFormat(t *testing.T) {
 	assert.Equal(t, UndefAddressString, Undef.String())
 	assert.Equal(t, UndefAddressString, fmt.Sprintf("%v", Undef))
 }
+
+func TestAddressNetworks(t *testing.T) {
+	tf.UnitTest(t)
+
+	pk := bls.PrivateKeyPublicKey(bls.PrivateKeyGenerate())
+	addr, err := NewBLSAddress(pk[:])
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		network Network
+		prefix  string
+		other   Network
+	}{
+		{Testnet, TestnetPrefix, Mainnet},
+		{Mainnet, MainnetPrefix, Testnet},
+	} {
+		str, err := addr.StringForNetwork(tc.network)
+		require.NoError(t, err)
+		assert.Equal(t, tc.prefix+"3", str[:2])
+
+		parsed, err := NewFromStringForNetwork(tc.network, str)
+		require.NoError(t, err)
+		assert.Equal(t, addr, parsed)
+
+		_, err = NewFromStringForNetwork(tc.other, str)
+		assert.Equal(t, ErrWrongNetwork, err)
+	}
+
+	_, err = addr.StringForNetwork(Network(7))
+	assert.Equal(t, ErrUnknownNetwork, err)
+	_, err = NewFromStringForNetwork(Network(7), addr.String())
+	assert.Equal(t, ErrUnknownNetwork, err)
+
+	parsed, err := NewFromStringForNetwork(Mainnet, UndefAddressString)
+	require.NoError(t, err)
+	assert.Equal(t, Undef, parsed)
+}
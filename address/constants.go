@@ -54,6 +54,12 @@ var (
 var (
 	// ErrUnknownNetwork is returned when encountering an unknown network in an address.
 	ErrUnknownNetwork = errors.New("unknown address network")
+	// ErrWrongNetwork is returned when an address is encoded for a different network than expected.
+	ErrWrongNetwork = errors.New("address is for a different network")
+	// ErrInvalidNetworkPrefix is returned when registering a network with an unusable prefix.
+	ErrInvalidNetworkPrefix = errors.New("network prefix must be a single non-digit character")
+	// ErrNetworkRegistered is returned when registering a network or prefix that is already registered.
+	ErrNetworkRegistered = errors.New("network or prefix is already registered")
 
 	// ErrUnknownProtocol is returned when encountering an unknown protocol in an address.
 	ErrUnknownProtocol = errors.New("unknown address protocol")
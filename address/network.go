@@ -0,0 +1,74 @@
+package address
+
+import (
+	"sync"
+)
+
+// networks maps each network to the one character prefix of its address strings.
+var networks = struct {
+	lk       sync.RWMutex
+	prefixes map[Network]string
+	byPrefix map[string]Network
+}{
+	prefixes: map[Network]string{Mainnet: MainnetPrefix, Testnet: TestnetPrefix},
+	byPrefix: map[string]Network{MainnetPrefix: Mainnet, TestnetPrefix: Testnet},
+}
+
+// RegisterNetwork makes addresses encoded for network start with prefix, so that addresses
+// of networks other than mainnet and testnet, such as devnets, can be encoded and decoded.
+// The prefix must be a single character other than a digit. Registering a network again
+// with the same prefix does nothing, but neither the network nor the prefix may already be
+// registered otherwise.
+func RegisterNetwork(network Network, prefix string) error {
+	if len(prefix) != 1 || (prefix[0] >= '0' && prefix[0] <= '9') {
+		return ErrInvalidNetworkPrefix
+	}
+
+	networks.lk.Lock()
+	defer networks.lk.Unlock()
+	if existing, ok := networks.prefixes[network]; ok && existing == prefix {
+		return nil
+	}
+	if _, ok := networks.prefixes[network]; ok {
+		return ErrNetworkRegistered
+	}
+	if _, ok := networks.byPrefix[prefix]; ok {
+		return ErrNetworkRegistered
+	}
+	networks.prefixes[network] = prefix
+	networks.byPrefix[prefix] = network
+	return nil
+}
+
+// DetectNetwork returns the network the address string s is encoded for. It returns an error
+// if s is not a valid address or its prefix is not that of a registered network.
+func DetectNetwork(s string) (Network, error) {
+	if _, err := decode(s); err != nil {
+		return 0, err
+	}
+	if len(s) == 0 || s == UndefAddressString {
+		return 0, ErrUnknownNetwork
+	}
+	network, ok := networkOfPrefix(s[:1])
+	if !ok {
+		return 0, ErrUnknownNetwork
+	}
+	return network, nil
+}
+
+func networkPrefix(network Network) (string, error) {
+	networks.lk.RLock()
+	defer networks.lk.RUnlock()
+	prefix, ok := networks.prefixes[network]
+	if !ok {
+		return "", ErrUnknownNetwork
+	}
+	return prefix, nil
+}
+
+func networkOfPrefix(prefix string) (Network, bool) {
+	networks.lk.RLock()
+	defer networks.lk.RUnlock()
+	network, ok := networks.byPrefix[prefix]
+	return network, ok
+}
@@ -0,0 +1,60 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDetectNetwork(t *testing.T) {
+	tf.UnitTest(t)
+
+	addr, err := NewActorAddress([]byte("detect network"))
+	require.NoError(t, err)
+
+	for _, network := range []Network{Mainnet, Testnet} {
+		s, err := addr.StringForNetwork(network)
+		require.NoError(t, err)
+		detected, err := DetectNetwork(s)
+		require.NoError(t, err)
+		assert.Equal(t, network, detected)
+	}
+
+	_, err = DetectNetwork("x" + addr.String()[1:])
+	assert.Equal(t, ErrUnknownNetwork, err)
+	_, err = DetectNetwork(UndefAddressString)
+	assert.Equal(t, ErrUnknownNetwork, err)
+	corrupt := []byte(addr.String())
+	if corrupt[5] == 'a' {
+		corrupt[5] = 'b'
+	} else {
+		corrupt[5] = 'a'
+	}
+	_, err = DetectNetwork(string(corrupt))
+	assert.Error(t, err)
+
+	t.Run("custom networks", func(t *testing.T) {
+		const devnet Network = 100
+		require.NoError(t, RegisterNetwork(devnet, "d"))
+		require.NoError(t, RegisterNetwork(devnet, "d"))
+
+		s, err := addr.StringForNetwork(devnet)
+		require.NoError(t, err)
+		assert.Equal(t, "d", s[:1])
+		detected, err := DetectNetwork(s)
+		require.NoError(t, err)
+		assert.Equal(t, devnet, detected)
+
+		decoded, err := NewFromString(s)
+		require.NoError(t, err)
+		assert.Equal(t, addr, decoded)
+
+		assert.Equal(t, ErrNetworkRegistered, RegisterNetwork(devnet, "e"))
+		assert.Equal(t, ErrNetworkRegistered, RegisterNetwork(devnet+1, TestnetPrefix))
+		assert.Equal(t, ErrInvalidNetworkPrefix, RegisterNetwork(devnet+1, "7"))
+		assert.Equal(t, ErrInvalidNetworkPrefix, RegisterNetwork(devnet+1, "dev"))
+	})
+}
@@ -98,7 +98,7 @@ Signature: %s
 				base64.StdEncoding.EncodeToString(msg.Params),
 				msg.GasPrice.String(),
 				strconv.FormatUint(uint64(msg.GasLimit), 10),
-				base64.StdEncoding.EncodeToString(msg.Signature),
+				base64.StdEncoding.EncodeToString(msg.Signature.Data),
 			)
 			return err
 		}),
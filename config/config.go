@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -207,12 +208,84 @@ type MessagePoolConfig struct {
 	MaxPoolSize int `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap types.Uint64 `json:"maxNonceGap"`
+	// BlockGasLimit is the gas budget of a block used to predict whether a pending message
+	// will be included in the next block
+	BlockGasLimit types.GasUnits `json:"blockGasLimit"`
+	// HighWaterMark is the fraction of MaxPoolSize at which the pool warns that it is filling up,
+	// or zero to disable the warning
+	HighWaterMark float64 `json:"highWaterMark"`
+	// LowWaterMark is the fraction of MaxPoolSize the pool must drop below before it warns again
+	LowWaterMark float64 `json:"lowWaterMark"`
+	// MaxReorgDepth is the maximum number of tipsets the pool walks back to find the common
+	// ancestor of a reorg, or zero for no limit
+	MaxReorgDepth types.Uint64 `json:"maxReorgDepth"`
+	// ReplaceOnlyHead restricts replace-by-fee to a sender's lowest pending nonce
+	ReplaceOnlyHead bool `json:"replaceOnlyHead"`
+	// AsyncAcceptedHook runs the pool's OnAccepted callback on its own goroutine rather than
+	// before the add returns
+	AsyncAcceptedHook bool `json:"asyncAcceptedHook"`
+	// AdmissionLogSize is the number of recent attempts to add a message the pool remembers
+	// for debugging, or zero to remember none
+	AdmissionLogSize int `json:"admissionLogSize"`
+	// FlushInterval is how often a persistent pool writes its pending messages to disk, such as
+	// "30s". If empty, changes are written as they happen.
+	FlushInterval string `json:"flushInterval"`
+	// PerSenderBlockQuota is the most messages from one sender the pool selects for a block,
+	// or zero for no limit
+	PerSenderBlockQuota int `json:"perSenderBlockQuota"`
+	// MinGasForMethod is the smallest gas limit the pool accepts on a message invoking each
+	// method. Methods not listed have no minimum.
+	MinGasForMethod map[string]types.GasUnits `json:"minGasForMethod"`
+	// TrustLoaded skips signature verification for messages a persistent pool reloads from
+	// disk at startup. All other checks still apply, so messages whose nonces have since been
+	// used or whose senders can no longer pay for them are dropped.
+	TrustLoaded bool `json:"trustLoaded"`
+	// AllowedSenderCodes are the code CIDs of actors other than account actors that the pool
+	// accepts messages from, such as multisig actors. Messages are still checked against the
+	// account-only rule when blocks are processed.
+	AllowedSenderCodes map[cid.Cid]bool `json:"allowedSenderCodes"`
+	// FutureQueueSize is the number of messages the pool holds apart from its pending messages
+	// because the nonces before theirs have not arrived. They do not count against MaxPoolSize
+	// and become pending once the gap is filled. They are not persisted. If zero such messages
+	// are simply pending.
+	FutureQueueSize int `json:"futureQueueSize"`
+	// RequiredBalanceMultiple, if positive, requires a sender's balance to cover the funds
+	// required by all its pending messages, including the one being added, times this
+	// multiple. If zero only the funds required by the message being added are checked.
+	RequiredBalanceMultiple int `json:"requiredBalanceMultiple"`
+	// MethodRateLimits caps the rate at which the pool admits newly received messages invoking
+	// each method, across all senders. Methods not listed are not limited.
+	MethodRateLimits map[string]MethodRateLimit `json:"methodRateLimits"`
+	// MaxParamsSize is the largest params, in bytes, the pool accepts on a message, or zero
+	// for no limit beyond that on the size of the whole message
+	MaxParamsSize int `json:"maxParamsSize"`
+	// VerifyBlockMessages checks that each block the pool fetches while updating for a new
+	// head hashes to the CID it was fetched by, so that its messages are the block's own
+	VerifyBlockMessages bool `json:"verifyBlockMessages"`
+}
+
+// MethodRateLimit is the rate at which the message pool admits messages invoking a method.
+type MethodRateLimit struct {
+	// PerSecond is the number of messages admitted per second once the burst is used up
+	PerSecond float64 `json:"perSecond"`
+	// Burst is the number of messages admitted at once
+	Burst int `json:"burst"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 10000,
-		MaxNonceGap: 100,
+		MaxPoolSize:      10000,
+		MaxNonceGap:      100,
+		BlockGasLimit:    types.BlockGasLimit,
+		HighWaterMark:    0.9,
+		LowWaterMark:     0.8,
+		AdmissionLogSize: 256,
+		MinGasForMethod:  map[string]types.GasUnits{},
+		MethodRateLimits: map[string]MethodRateLimit{},
+
+		VerifyBlockMessages: true,
+
+		AllowedSenderCodes: map[cid.Cid]bool{},
 	}
 }
 
@@ -54,7 +54,7 @@ var (
 // TicketSigner is an interface for a test signer that can create tickets.
 type TicketSigner interface {
 	GetAddressForPubKey(pk []byte) (address.Address, error)
-	SignBytes(data []byte, signerAddr address.Address) (types.Signature, error)
+	SignBytes(data []byte, signerAddr address.Address) (types.RawSignature, error)
 }
 
 // TODO none of these parameters are chosen correctly
@@ -305,7 +305,7 @@ func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.T
 //    See https://github.com/filecoin-project/specs/blob/master/expected-consensus.md
 //    for an explanation of the math here.
 func IsWinningTicket(ctx context.Context, bs blockstore.Blockstore, ptv PowerTableView, st state.Tree,
-	ticket types.Signature, miner address.Address) (bool, error) {
+	ticket types.RawSignature, miner address.Address) (bool, error) {
 
 	totalPower, err := ptv.Total(ctx, st, bs)
 	if err != nil {
@@ -322,7 +322,7 @@ func IsWinningTicket(ctx context.Context, bs blockstore.Blockstore, ptv PowerTab
 
 // CompareTicketPower abstracts the actual comparison logic so it can be used by some test
 // helpers
-func CompareTicketPower(ticket types.Signature, minerPower uint64, totalPower uint64) bool {
+func CompareTicketPower(ticket types.RawSignature, minerPower uint64, totalPower uint64) bool {
 	lhs := &big.Int{}
 	lhs.SetBytes(ticket)
 	lhs.Mul(lhs, big.NewInt(int64(totalPower)))
@@ -408,10 +408,10 @@ func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.Storag
 // 	params:  proof  []byte, the proof to sign
 // 			 signerPubKey []byte, the public key for the signer. Must exist in the signer
 //      	 signer, implements TicketSigner interface. Must have signerPubKey in its keyinfo.
-//  returns:  types.Signature ( []byte ), error
-func CreateTicket(proof types.PoStProof, signerPubKey []byte, signer TicketSigner) (types.Signature, error) {
+//  returns:  types.RawSignature ( []byte ), error
+func CreateTicket(proof types.PoStProof, signerPubKey []byte, signer TicketSigner) (types.RawSignature, error) {
 
-	var ticket types.Signature
+	var ticket types.RawSignature
 
 	signerAddr, err := signer.GetAddressForPubKey(signerPubKey)
 	if err != nil {
@@ -29,7 +29,7 @@ var (
 
 func init() {
 	defaultAccounts = map[address.Address]*types.AttoFIL{
-		address.NetworkAddress: types.NewAttoFILFromFIL(10000000000),
+		address.NetworkAddress: types.NewAttoFILFromFIL(types.TotalFilecoin),
 		address.TestAddress:    types.NewAttoFILFromFIL(50000),
 		address.TestAddress2:   types.NewAttoFILFromFIL(60000),
 	}
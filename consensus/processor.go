@@ -350,10 +350,14 @@ var (
 	errGasTooHighForCurrentBlock = errors.NewRevertError("message gas limit too high for current block")
 	errNonceTooHigh              = errors.NewRevertError("nonce too high")
 	errNonceTooLow               = errors.NewRevertError("nonce too low")
+	errNonceGapTooLarge          = errors.NewRevertError("message nonce is too much greater than actor nonce")
 	errNonAccountActor           = errors.NewRevertError("message from non-account actor")
 	errNegativeValue             = errors.NewRevertError("negative value")
 	errInsufficientGas           = errors.NewRevertError("balance insufficient to cover transfer+gas")
 	errInvalidSignature          = errors.NewRevertError("invalid signature by sender over message data")
+	errEmptySender               = errors.NewRevertError("message has no sender")
+	errEmptyRecipient            = errors.NewRevertError("message has no recipient")
+	errInvalidCreateActor        = errors.NewRevertError("create actor message params are not a code cid")
 	// TODO we'll eventually handle sending to self.
 	errSelfSend = errors.NewRevertError("cannot send to self")
 )
@@ -639,6 +643,9 @@ func isPermanentError(err error) bool {
 	return err == errInsufficientGas ||
 		err == errSelfSend ||
 		err == errInvalidSignature ||
+		err == errEmptySender ||
+		err == errEmptyRecipient ||
+		err == errInvalidCreateActor ||
 		err == errNonceTooLow ||
 		err == errNonAccountActor ||
 		err == errNegativeValue ||
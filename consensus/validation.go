@@ -2,15 +2,15 @@ package consensus
 
 import (
 	"context"
-	"github.com/filecoin-project/go-filecoin/address"
-	"math/big"
+
+	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
-	"github.com/filecoin-project/go-filecoin/vm/errors"
 )
 
 // SignedMessageValidator validates incoming signed messages.
@@ -22,6 +22,8 @@ type SignedMessageValidator interface {
 
 type defaultMessageValidator struct {
 	allowHighNonce bool
+	// allowedSenderCodes are the codes of actors other than account actors that may send messages.
+	allowedSenderCodes map[cid.Cid]bool
 }
 
 // NewDefaultMessageValidator creates a new default validator.
@@ -41,11 +43,30 @@ func NewOutboundMessageValidator() SignedMessageValidator {
 var _ SignedMessageValidator = (*defaultMessageValidator)(nil)
 
 func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.SignedMessage, fromActor *actor.Actor) error {
+	// Run the structural checks before the much more expensive signature verification,
+	// since most invalid messages fail one of them.
+	if err := validateStructure(msg); err != nil {
+		return err
+	}
+
 	if !msg.VerifySignature() {
 		return errInvalidSignature
 	}
 
-	if msg.From == msg.To {
+	return v.validateAgainstActor(msg, fromActor)
+}
+
+// validateStructure runs the checks that need nothing but the message itself.
+func validateStructure(msg *types.SignedMessage) error {
+	if msg.From.Empty() {
+		return errEmptySender
+	}
+
+	if err := validateRecipient(&msg.Message); err != nil {
+		return err
+	}
+
+	if !msg.To.Empty() && msg.From == msg.To {
 		return errSelfSend
 	}
 
@@ -53,22 +74,27 @@ func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.Signe
 		return errGasPriceZero
 	}
 
-	// Sender must be an account actor, or an empty actor which will be upgraded to an account actor
-	// when the message is processed.
-	if !(fromActor.Empty() || account.IsAccount(fromActor)) {
-		return errNonAccountActor
-	}
-
 	if msg.Value.IsNegative() {
-		log.Info("Cannot transfer negative value", fromActor, msg.Value)
+		log.Info("Cannot transfer negative value", msg.Value)
 		return errNegativeValue
 	}
 
 	if msg.GasLimit > types.BlockGasLimit {
-		log.Info("Message gas limit above block limit", fromActor, msg, types.BlockGasLimit)
+		log.Info("Message gas limit above block limit", msg, types.BlockGasLimit)
 		return errGasAboveBlockLimit
 	}
 
+	return nil
+}
+
+// validateAgainstActor runs the checks that depend on the state of the sending actor.
+func (v *defaultMessageValidator) validateAgainstActor(msg *types.SignedMessage, fromActor *actor.Actor) error {
+	// Sender must be an account actor, or an empty actor which will be upgraded to an account actor
+	// when the message is processed, unless its code is explicitly allowed.
+	if !(fromActor.Empty() || account.IsAccount(fromActor) || v.allowedSenderCodes[fromActor.Code]) {
+		return errNonAccountActor
+	}
+
 	// Avoid processing messages for actors that cannot pay.
 	if !canCoverGasLimit(msg, fromActor) {
 		log.Info("Insufficient funds to cover gas limit: ", fromActor, msg)
@@ -88,12 +114,26 @@ func (v *defaultMessageValidator) Validate(ctx context.Context, msg *types.Signe
 	return nil
 }
 
+// validateRecipient checks the message's recipient. Create-actor messages are the only
+// messages allowed to have no recipient, and must carry the code cid as their params.
+func validateRecipient(msg *types.Message) error {
+	if !msg.To.Empty() {
+		return nil
+	}
+	if !msg.IsCreateActor() {
+		return errEmptyRecipient
+	}
+	if _, err := cid.Cast(msg.Params); err != nil {
+		return errInvalidCreateActor
+	}
+	return nil
+}
+
 // Check's whether the maximum gas charge + message value is within the actor's balance.
 // Note that this is an imperfect test, since nested messages invoked by this one may transfer
 // more value from the actor's balance.
 func canCoverGasLimit(msg *types.SignedMessage, actor *actor.Actor) bool {
-	maximumGasCharge := msg.GasPrice.MulBigInt(big.NewInt(int64(msg.GasLimit)))
-	return maximumGasCharge.LessEqual(actor.Balance.Sub(msg.Value))
+	return msg.RequiredFunds().LessEqual(actor.Balance)
 }
 
 // IngestionValidatorAPI allows the validator to access latest state
@@ -108,18 +148,35 @@ type IngestionValidator struct {
 	validator defaultMessageValidator
 }
 
-// NewIngestionValidator creates a new validator with an api
+// NewIngestionValidator creates a new validator with an api. Besides account actors, it
+// accepts messages from actors with the codes in cfg.AllowedSenderCodes.
 func NewIngestionValidator(api ingestionValidatorAPI, cfg *config.MessagePoolConfig) *IngestionValidator {
 	return &IngestionValidator{
 		api:       api,
 		cfg:       cfg,
-		validator: defaultMessageValidator{allowHighNonce: true},
+		validator: defaultMessageValidator{allowHighNonce: true, allowedSenderCodes: cfg.AllowedSenderCodes},
 	}
 }
 
-// Validate validates the signed message.
-// Errors probably mean the validation failed, but possibly indicate a failure to retrieve state
-func (v *IngestionValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+// ValidateStructure runs the checks that need neither chain state nor signature
+// verification, so callers can reject most invalid messages before doing either.
+// Validate runs these checks too.
+func (v *IngestionValidator) ValidateStructure(msg *types.SignedMessage) error {
+	return withValidationCode(validateStructure(msg))
+}
+
+// ValidateSignature checks that msg is signed by its sender.
+func (v *IngestionValidator) ValidateSignature(msg *types.SignedMessage) error {
+	if !msg.VerifySignature() {
+		return withValidationCode(errInvalidSignature)
+	}
+	return nil
+}
+
+// ValidateState runs the checks against the sender's actor in the latest state, which is
+// retrieved first.
+// Errors probably mean the validation failed, but possibly indicate a failure to retrieve state.
+func (v *IngestionValidator) ValidateState(ctx context.Context, msg *types.SignedMessage) error {
 	// retrieve from actor
 	fromActor, err := v.api.ActorFromLatestState(ctx, msg.From)
 	if err != nil {
@@ -131,9 +188,50 @@ func (v *IngestionValidator) Validate(ctx context.Context, msg *types.SignedMess
 	}
 
 	// check that message nonce is not too high
-	if msg.Nonce > fromActor.Nonce && msg.Nonce-fromActor.Nonce > v.cfg.MaxNonceGap {
-		return errors.NewRevertErrorf("message nonce (%d) is too much greater than actor nonce (%d)", msg.Nonce, fromActor.Nonce)
+	if msg.Nonce.GapFrom(fromActor.Nonce) > uint64(v.cfg.MaxNonceGap) {
+		log.Info("Nonce gap too large: ", msg.Nonce, fromActor.Nonce, fromActor, msg)
+		return types.NewValidationError(types.ValidationNonceGap, errNonceGapTooLarge)
+	}
+
+	return withValidationCode(v.validator.validateAgainstActor(msg, fromActor))
+}
+
+// Validate validates the signed message, running ValidateStructure, ValidateSignature and
+// ValidateState in turn.
+// Errors probably mean the validation failed, but possibly indicate a failure to retrieve state.
+// Validation failures are returned as *types.ValidationError.
+func (v *IngestionValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	if err := v.ValidateStructure(msg); err != nil {
+		return err
 	}
+	if err := v.ValidateSignature(msg); err != nil {
+		return err
+	}
+	return v.ValidateState(ctx, msg)
+}
+
+// withValidationCode wraps err in a *types.ValidationError if it is one of the errors
+// returned by defaultMessageValidator.
+func withValidationCode(err error) error {
+	if code, ok := validationCodes[err]; ok {
+		return types.NewValidationError(code, err)
+	}
+	return err
+}
 
-	return v.validator.Validate(ctx, msg, fromActor)
+// validationCodes maps the errors returned by defaultMessageValidator to their codes.
+var validationCodes = map[error]types.ValidationCode{
+	errNonceGapTooLarge:   types.ValidationNonceGap,
+	errInvalidSignature:   types.ValidationBadSignature,
+	errSelfSend:           types.ValidationSelfSend,
+	errEmptySender:        types.ValidationBadSender,
+	errEmptyRecipient:     types.ValidationBadRecipient,
+	errInvalidCreateActor: types.ValidationBadRecipient,
+	errGasPriceZero:       types.ValidationGasPriceZero,
+	errNonAccountActor:    types.ValidationNonAccountActor,
+	errNegativeValue:      types.ValidationNegativeValue,
+	errGasAboveBlockLimit: types.ValidationGasLimit,
+	errInsufficientGas:    types.ValidationInsufficientBalance,
+	errNonceTooLow:        types.ValidationNonceTooLow,
+	errNonceTooHigh:       types.ValidationNonceTooHigh,
 }
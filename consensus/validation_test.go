@@ -3,6 +3,7 @@ package consensus_test
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/filecoin-project/go-filecoin/actor"
@@ -46,7 +47,7 @@ func TestMessageValidator(t *testing.T) {
 
 	t.Run("invalid signature fails", func(t *testing.T) {
 		msg := newMessage(t, alice, bob, 100, 5, 1, 0)
-		msg.Signature = []byte{}
+		msg.Signature = types.Signature{}
 		assert.Errorf(t, validator.Validate(ctx, msg, actor), "signature")
 
 	})
@@ -56,6 +57,16 @@ func TestMessageValidator(t *testing.T) {
 		assert.Errorf(t, validator.Validate(ctx, msg, actor), "self")
 	})
 
+	t.Run("empty sender is not a self send", func(t *testing.T) {
+		// Neither address of an unsigned create actor message from nobody is set.
+		create := types.NewCreateActorMessage(address.Undef, 100, attoFil(5), types.AccountActorCodeCid)
+		msg := &types.SignedMessage{MeteredMessage: *types.NewMeteredMessage(*create, types.NewGasPrice(1), types.NewGasUnits(0))}
+		err := validator.Validate(ctx, msg, actor)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no sender")
+		assert.NotContains(t, err.Error(), "self")
+	})
+
 	t.Run("non-account actor fails", func(t *testing.T) {
 		badActor := newActor(t, 1000, 100)
 		badActor.Code = types.SomeCid()
@@ -133,6 +144,89 @@ func TestIngestionValidator(t *testing.T) {
 		err := validator.Validate(ctx, msg)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "too much greater than actor nonce")
+
+		atGap := uint64(act.Nonce.AddGap(uint64(mpoolCfg.MaxNonceGap)))
+		assert.NoError(t, validator.Validate(ctx, newMessage(t, alice, bob, atGap, 5, 1, 0)))
+		err = validator.Validate(ctx, newMessage(t, alice, bob, atGap+1, 5, 1, 0))
+		assert.Equal(t, types.ValidationNonceGap, types.ValidationCodeOf(err))
+		err = validator.Validate(ctx, newMessage(t, alice, bob, math.MaxUint64, 5, 1, 0))
+		assert.Equal(t, types.ValidationNonceGap, types.ValidationCodeOf(err))
+	})
+
+	t.Run("Returns validation codes", func(t *testing.T) {
+		badSig := newMessage(t, alice, bob, 53, 5, 1, 0)
+		badSig.Signature = types.Signature{}
+
+		testCases := []struct {
+			name string
+			msg  *types.SignedMessage
+			code types.ValidationCode
+		}{
+			{"nonce gap", newMessage(t, alice, bob, uint64(act.Nonce+mpoolCfg.MaxNonceGap+1), 5, 1, 0), types.ValidationNonceGap},
+			{"self send", newMessage(t, alice, alice, 53, 5, 1, 0), types.ValidationSelfSend},
+			{"bad signature", badSig, types.ValidationBadSignature},
+			{"no recipient", newMessage(t, alice, address.Undef, 53, 5, 1, 0), types.ValidationBadRecipient},
+			{"gas price zero", newMessage(t, alice, bob, 53, 5, 0, 0), types.ValidationGasPriceZero},
+			{"negative value", newMessage(t, alice, bob, 53, -5, 1, 0), types.ValidationNegativeValue},
+			{"gas limit", newMessage(t, alice, bob, 53, 5, 1, uint64(types.BlockGasLimit)+1), types.ValidationGasLimit},
+			{"insufficient balance", newMessage(t, alice, bob, 53, 2000, 1, 0), types.ValidationInsufficientBalance},
+			{"nonce too low", newMessage(t, alice, bob, 52, 5, 1, 0), types.ValidationNonceTooLow},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				err := validator.Validate(ctx, tc.msg)
+				require.Error(t, err)
+				verr, ok := err.(*types.ValidationError)
+				require.True(t, ok, "expected a validation error, got %T", err)
+				assert.Equal(t, tc.code, verr.Code)
+				assert.Equal(t, tc.code, types.ValidationCodeOf(err))
+			})
+		}
+	})
+
+	t.Run("Admits create actor message without recipient", func(t *testing.T) {
+		msg := types.NewCreateActorMessage(alice, 53, attoFil(5), types.AccountActorCodeCid)
+		smsg, err := types.NewSignedMessage(*msg, signer, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		assert.NoError(t, validator.Validate(ctx, smsg))
+	})
+
+	t.Run("Admits allowed non-account senders", func(t *testing.T) {
+		minerAPI := NewMockIngestionValidatorAPI()
+		minerAPI.ActorAddr = alice
+		minerAPI.Actor = newActor(t, 1000, 53)
+		minerAPI.Actor.Code = types.MinerActorCodeCid
+		msg := newMessage(t, alice, bob, 53, 5, 1, 0)
+
+		err := consensus.NewIngestionValidator(minerAPI, config.NewDefaultConfig().Mpool).Validate(ctx, msg)
+		assert.Equal(t, types.ValidationNonAccountActor, types.ValidationCodeOf(err))
+
+		allowing := config.NewDefaultConfig().Mpool
+		allowing.AllowedSenderCodes[types.MinerActorCodeCid] = true
+		assert.NoError(t, consensus.NewIngestionValidator(minerAPI, allowing).Validate(ctx, msg))
+	})
+
+	t.Run("Admits ID address recipient", func(t *testing.T) {
+		to, err := address.NewIDAddress(7)
+		require.NoError(t, err)
+		msg := newMessage(t, alice, to, 53, 5, 1, 0)
+		assert.NoError(t, validator.Validate(ctx, msg))
+	})
+
+	t.Run("Rejects missing recipient", func(t *testing.T) {
+		msg := newMessage(t, alice, address.Undef, 53, 5, 1, 0)
+		err := validator.Validate(ctx, msg)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no recipient")
+
+		// The create actor method alone does not make a message a valid create actor message.
+		badCreate := types.NewCreateActorMessage(alice, 53, attoFil(5), types.AccountActorCodeCid)
+		badCreate.Params = []byte("not a cid")
+		smsg, err := types.NewSignedMessage(*badCreate, signer, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		err = validator.Validate(ctx, smsg)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "code cid")
 	})
 
 	t.Run("Actor not found is not an error", func(t *testing.T) {
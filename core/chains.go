@@ -3,15 +3,27 @@ package core
 import (
 	"context"
 
+	"github.com/pkg/errors"
+
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+// ErrReorgTooDeep is returned when the common ancestor of two chains is further back than
+// the permitted depth.
+var ErrReorgTooDeep = errors.New("common ancestor is deeper than the maximum reorg depth")
+
 // CollectBlocksToCommonAncestor traverses chains from two tipsets (called old and new) until their common
 // ancestor, collecting all blocks that are in one chain but not the other.
 // The resulting lists of blocks are ordered by decreasing height; the ordering of blocks with the same
 // height is undefined until https://github.com/filecoin-project/go-filecoin/issues/2310 is resolved.
 func CollectBlocksToCommonAncestor(ctx context.Context, store chain.BlockProvider, oldHead, newHead types.TipSet) (oldBlocks, newBlocks []*types.Block, err error) {
+	return CollectBlocksToCommonAncestorWithLimit(ctx, store, oldHead, newHead, 0)
+}
+
+// CollectBlocksToCommonAncestorWithLimit is CollectBlocksToCommonAncestor, but returns ErrReorgTooDeep
+// rather than walking more than maxDepth tipsets back from either head. A maxDepth of zero means no limit.
+func CollectBlocksToCommonAncestorWithLimit(ctx context.Context, store chain.BlockProvider, oldHead, newHead types.TipSet, maxDepth uint64) (oldBlocks, newBlocks []*types.Block, err error) {
 	// Strategy: walk head-of-chain pointers old and new back until they are at the same height,
 	// then walk back in lockstep to find the common ancestor.
 
@@ -20,7 +32,7 @@ func CollectBlocksToCommonAncestor(ctx context.Context, store chain.BlockProvide
 	if err != nil {
 		return
 	}
-	oldBlocks, oldItr, err := collectBlocks(ctx, store, oldHead, newHeight)
+	oldBlocks, oldDepth, oldItr, err := collectBlocks(ctx, store, oldHead, newHeight, maxDepth)
 	if err != nil {
 		return
 	}
@@ -30,7 +42,7 @@ func CollectBlocksToCommonAncestor(ctx context.Context, store chain.BlockProvide
 	if err != nil {
 		return
 	}
-	newBlocks, newItr, err := collectBlocks(ctx, store, newHead, oldHeight)
+	newBlocks, newDepth, newItr, err := collectBlocks(ctx, store, newHead, oldHeight, maxDepth)
 	if err != nil {
 		return
 	}
@@ -44,6 +56,12 @@ func CollectBlocksToCommonAncestor(ctx context.Context, store chain.BlockProvide
 		for _, b := range newItr.Value() {
 			newBlocks = append(newBlocks, b)
 		}
+		oldDepth++
+		newDepth++
+		if tooDeep(oldDepth, maxDepth) || tooDeep(newDepth, maxDepth) {
+			err = ErrReorgTooDeep
+			return
+		}
 
 		// Advance iterators
 		if err = oldItr.Next(); err != nil {
@@ -57,10 +75,13 @@ func CollectBlocksToCommonAncestor(ctx context.Context, store chain.BlockProvide
 }
 
 // collectBlocks collects blocks by traversing the chain from a tipset towards its parents, until some
-// minimum height (excluding the tipset at that height).
-// Returns the blocks collected and a tipset iterator positioned at the tipset at `endHeight`
-func collectBlocks(ctx context.Context, store chain.BlockProvider, head types.TipSet, endHeight uint64) ([]*types.Block, *chain.TipsetIterator, error) {
+// minimum height (excluding the tipset at that height), or ErrReorgTooDeep if that is more than
+// maxDepth tipsets.
+// Returns the blocks collected, the number of tipsets they came from and a tipset iterator positioned
+// at the tipset at `endHeight`
+func collectBlocks(ctx context.Context, store chain.BlockProvider, head types.TipSet, endHeight, maxDepth uint64) ([]*types.Block, uint64, *chain.TipsetIterator, error) {
 	var blocks []*types.Block
+	var depth uint64
 	var err error
 	tsItr := chain.IterAncestors(ctx, store, head)
 	for ; err == nil && !tsItr.Complete(); err = tsItr.Next() {
@@ -72,6 +93,14 @@ func collectBlocks(ctx context.Context, store chain.BlockProvider, head types.Ti
 		for _, b := range ts {
 			blocks = append(blocks, b)
 		}
+		depth++
+		if tooDeep(depth, maxDepth) {
+			return nil, depth, nil, ErrReorgTooDeep
+		}
 	}
-	return blocks, tsItr, err
+	return blocks, depth, tsItr, err
+}
+
+func tooDeep(depth, maxDepth uint64) bool {
+	return maxDepth > 0 && depth > maxDepth
 }
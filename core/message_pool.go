@@ -1,16 +1,23 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"math/big"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ipfs/go-cid"
 	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
 
+	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/metrics"
+	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -19,14 +26,45 @@ var mpSize = metrics.NewInt64Gauge("message_pool_size", "The size of the message
 // MessageTimeOut is the number of tipsets we should receive before timing out messages
 const MessageTimeOut = 6
 
+// ReplaceByFeePercent is the minimum percentage by which a message's gas price must exceed
+// that of a pending message with the same sender and nonce in order to replace it.
+const ReplaceByFeePercent = 10
+
 type timedmessage struct {
-	message *types.SignedMessage
-	addedAt uint64
+	message  *types.SignedMessage
+	cid      cid.Cid // the CID of message, computed when it is first added
+	addedAt  uint64
+	ttl      uint64 // blocks after addedAt at which the message expires, or zero for MessageTimeOut tip sets
+	trusted  bool   // true if the message's signature was verified before, so it is not verified again
+	flagged  bool   // true if the message was admitted in sync mode despite a low nonce or balance
+	incoming bool   // true if the message was just received, so it counts against method rate limits
+}
+
+// expired returns true if the message has a ttl that has run out by headHeight.
+func (tm *timedmessage) expired(headHeight uint64) bool {
+	return tm.ttl > 0 && headHeight >= tm.addedAt+tm.ttl
 }
 
+var (
+	errPoolFull       = errors.New("message pool is full")
+	errDuplicateNonce = errors.New("message pool contains message with same actor and nonce but different cid")
+	errNotPending     = errors.New("message is not pending")
+	errFeeBumpTooLow  = errors.Errorf("new gas price must exceed the pending gas price by at least %d%%", ReplaceByFeePercent)
+
+	// ErrCannotReplaceNonHead is returned when a message would replace a pending message
+	// other than its sender's lowest pending nonce and the pool only allows replacing that one.
+	ErrCannotReplaceNonHead = errors.New("only the pending message with the lowest nonce may be replaced")
+	// ErrGasLimitBelowMethodMin is returned when a message's gas limit is below the minimum
+	// configured for its method.
+	ErrGasLimitBelowMethodMin = errors.New("gas limit is below the minimum for the method")
+	// ErrParamsTooLarge is returned when a message's params are longer than MaxParamsSize.
+	ErrParamsTooLarge = errors.New("message params are too large")
+)
+
 // MessagePoolAPI defines an interface to api resources the message pool needs.
 type MessagePoolAPI interface {
 	BlockHeight() (uint64, error)
+	ActorFromLatestState(ctx context.Context, address address.Address) (*actor.Actor, error)
 }
 
 // MessagePoolValidator defines a validator that ensures a message can go through the pool.
@@ -34,6 +72,18 @@ type MessagePoolValidator interface {
 	Validate(ctx context.Context, msg *types.SignedMessage) error
 }
 
+// StagedValidator is implemented by validators whose checks can be run separately. The pool
+// runs the structural checks, which need neither chain state nor signature verification,
+// before looking up the sender, so that messages failing them cost as little as possible, and
+// runs the others afterwards instead of Validate. Validate must be equivalent to running
+// ValidateStructure, ValidateSignature and ValidateState.
+type StagedValidator interface {
+	MessagePoolValidator
+	ValidateStructure(msg *types.SignedMessage) error
+	ValidateSignature(msg *types.SignedMessage) error
+	ValidateState(ctx context.Context, msg *types.SignedMessage) error
+}
+
 type addressNonce struct {
 	addr  address.Address
 	nonce uint64
@@ -43,9 +93,24 @@ func newAddressNonce(msg *types.SignedMessage) addressNonce {
 	return addressNonce{addr: msg.From, nonce: uint64(msg.Nonce)}
 }
 
+// AddReceipt describes the outcome of a successful addition to the message pool.
+type AddReceipt struct {
+	// Cid is the CID of the added message.
+	Cid cid.Cid
+	// Replaced is the CID of the pending message with the same sender and nonce that
+	// this message displaced by paying a higher gas price, or cid.Undef if none.
+	Replaced cid.Cid
+	// Duplicate is true if the message was already pending, in which case the add was a no-op.
+	Duplicate bool
+	// Promoted are the CIDs of the messages from the same sender that moved from the future
+	// queue to pending because this message filled the gap before their nonces.
+	Promoted []cid.Cid
+}
+
 // MessagePool keeps an unordered, de-duplicated set of Messages and supports removal by CID.
 // By 'de-duplicated' we mean that insertion of a message by cid that already
-// exists is a nop. We use a MessagePool to store all messages received by this node
+// exists is a nop. A message with the same actor and nonce as a pending message but a
+// sufficiently higher gas price replaces it. We use a MessagePool to store all messages received by this node
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
 //
@@ -53,68 +118,311 @@ func newAddressNonce(msg *types.SignedMessage) addressNonce {
 type MessagePool struct {
 	lk sync.RWMutex
 
-	api           MessagePoolAPI
-	cfg           *config.MessagePoolConfig
-	validator     MessagePoolValidator
-	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	apiLk sync.RWMutex // guards api, which may be replaced while messages are being added
+	api   MessagePoolAPI
+
+	cfg            *config.MessagePoolConfig
+	validator      MessagePoolValidator
+	store          PoolStore                             // may be nil, in which case the pool is in-memory only
+	rejections     map[string]uint64                     // count of rejected adds by reason
+	reservations   map[address.Address]map[uint64]uint64 // height at which each nonce was reserved by AssignNonce, by sender
+	pending        map[cid.Cid]*timedmessage             // all pending messages
+	addressNonces  map[addressNonce]cid.Cid              // cid of the pending message for each address nonce pair, used to efficiently validate duplicate nonces
+	reserved       map[cid.Cid]struct{}                  // pending messages selected for a block being produced
+	mined          map[addressNonce]uint64               // height of recently mined messages, by address nonce pair
+	future         map[addressNonce]*timedmessage        // messages waiting for the nonces before theirs, if FutureQueueSize is set
+	syncMode       bool                                  // true while the node is syncing, relaxing checks against lagging state
+	score          ScoreFunc                             // orders messages for selection, or nil to order by gas price
+	methodLimits   map[string]*rate.Limiter              // limiters for the methods in MethodRateLimits, created on first use
+	senderProgress map[address.Address]*senderProgress   // subscribers to the progress of each sender
+
+	requeueFailures map[cid.Cid]requeueFailure // failed revalidations of messages from reverted blocks
+	quarantine      map[cid.Cid]uint64         // height until which each quarantined message is not requeued
+
+	onHighWater    func(count, max int) // called when the pool reaches its high-water mark
+	aboveHighWater bool                 // true from reaching the high-water mark until dropping below the low-water mark
+
+	onAccepted    func(context.Context, *types.SignedMessage) // called with each newly admitted message
+	acceptedCalls chan struct{}                               // bounds the asynchronous onAccepted calls in flight
+
+	admissions     []AdmissionRecord // ring buffer of the most recent add attempts
+	admissionsNext int               // index in admissions of the next record
+
+	flushLk          sync.Mutex           // serializes writes of the pending set to the store
+	flushInterval    time.Duration        // interval between background flushes, or zero to write to the store as messages change
+	dirty            bool                 // true if the store is missing changes to the pending set
+	unflushedDeletes map[cid.Cid]struct{} // removed messages not yet deleted from the store
+	stopFlushing     chan struct{}        // closed by Close to stop background flushing
+	closeOnce        sync.Once            // closes stopFlushing once
 }
 
 // Add adds a message to the pool.
 func (pool *MessagePool) Add(ctx context.Context, msg *types.SignedMessage) (cid.Cid, error) {
-	blockTime, err := pool.api.BlockHeight()
+	receipt, err := pool.AddWithReceipt(ctx, msg)
 	if err != nil {
 		return cid.Undef, err
 	}
+	return receipt.Cid, nil
+}
+
+// AddWithReceipt adds a message to the pool and returns a receipt describing how it was admitted.
+func (pool *MessagePool) AddWithReceipt(ctx context.Context, msg *types.SignedMessage) (AddReceipt, error) {
+	return pool.addWithTTL(ctx, msg, 0)
+}
+
+// AddWithTTL adds a message to the pool that expires ttlBlocks block heights after it
+// arrives, rather than after the default MessageTimeOut tip sets. A zero ttlBlocks
+// uses the default.
+func (pool *MessagePool) AddWithTTL(ctx context.Context, msg *types.SignedMessage, ttlBlocks uint64) (cid.Cid, error) {
+	receipt, err := pool.addWithTTL(ctx, msg, ttlBlocks)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return receipt.Cid, nil
+}
+
+// AddMany adds msgs to the pool, holding the pool lock once for all of them. Each message
+// is admitted or rejected independently: cids[i] is the CID of msgs[i] if errs[i] is nil.
+// If ctx is done partway through, the messages already added stay in the pool and each of
+// the remaining messages is not attempted and has ctx's error, such as context.Canceled.
+func (pool *MessagePool) AddMany(ctx context.Context, msgs []*types.SignedMessage) (cids []cid.Cid, errs []error) {
+	cids = make([]cid.Cid, len(msgs))
+	errs = make([]error, len(msgs))
+
+	blockTime, err := pool.getAPI().BlockHeight()
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return cids, errs
+	}
 
-	return pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: blockTime})
+	var accepted []*types.SignedMessage
+	pool.lk.Lock()
+	for i, msg := range msgs {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		receipt, err := pool.addTimedMessageLocked(ctx, &timedmessage{message: msg, addedAt: blockTime, incoming: true})
+		pool.recordAdmissionLocked(msg, err)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		cids[i] = receipt.Cid
+		if !receipt.Duplicate {
+			accepted = append(accepted, msg)
+		}
+	}
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	pool.lk.Unlock()
+
+	pool.accepted(ctx, accepted...)
+	return cids, errs
+}
+
+func (pool *MessagePool) addWithTTL(ctx context.Context, msg *types.SignedMessage, ttlBlocks uint64) (AddReceipt, error) {
+	blockTime, err := pool.getAPI().BlockHeight()
+	if err != nil {
+		return AddReceipt{}, err
+	}
+
+	return pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: blockTime, ttl: ttlBlocks, incoming: true})
 }
 
 // An error coming out of addTimedMessage probably means the message failed to validate,
 // but it could indicate a more serious problem with the system.
-func (pool *MessagePool) addTimedMessage(ctx context.Context, msg *timedmessage) (cid.Cid, error) {
+func (pool *MessagePool) addTimedMessage(ctx context.Context, msg *timedmessage) (AddReceipt, error) {
 	pool.lk.Lock()
-	defer pool.lk.Unlock()
-
-	c, err := msg.message.Cid()
+	receipt, err := pool.addTimedMessageLocked(ctx, msg)
+	pool.recordAdmissionLocked(msg.message, err)
 	if err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to create CID")
+		pool.lk.Unlock()
+		return AddReceipt{}, err
 	}
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	pool.lk.Unlock()
+
+	if !receipt.Duplicate {
+		pool.accepted(ctx, msg.message)
+	}
+	return receipt, nil
+}
+
+// addTimedMessageLocked adds the message. The caller must hold the write lock.
+func (pool *MessagePool) addTimedMessageLocked(ctx context.Context, msg *timedmessage) (AddReceipt, error) {
+	if !msg.cid.Defined() {
+		c, err := msg.message.Cid()
+		if err != nil {
+			return AddReceipt{}, errors.Wrap(err, "failed to create CID")
+		}
+		msg.cid = c
+	}
+	c := msg.cid
 
 	// ignore message prior to validation if it is already in pool
 	_, found := pool.pending[c]
-	if found {
-		return c, nil
+	if found || pool.isFutureLocked(c, msg.message) {
+		return AddReceipt{Cid: c, Duplicate: true}, nil
+	}
+
+	if msg.incoming {
+		if err := pool.checkMethodRateLocked(msg.message); err != nil {
+			return AddReceipt{}, errors.Wrap(err, "validation error adding message to pool")
+		}
 	}
 
-	if err = pool.validateMessage(ctx, msg.message); err != nil {
-		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
+	replaced, future, flagged, err := pool.validateMessage(ctx, msg.message, !msg.trusted)
+	if err != nil {
+		return AddReceipt{}, errors.Wrap(err, "validation error adding message to pool")
+	}
+	msg.flagged = flagged
+	if future {
+		return pool.addFutureLocked(c, msg), nil
 	}
 
+	if err := pool.persistLocked(c, msg.message); err != nil {
+		return AddReceipt{}, err
+	}
+
+	if replaced.Defined() {
+		pool.removeLocked(replaced)
+	}
+	pool.insertPendingLocked(ctx, c, msg)
+	promoted := pool.promoteFromLocked(ctx, msg.message.From, uint64(msg.message.Nonce)+1)
+	return AddReceipt{Cid: c, Replaced: replaced, Promoted: promoted}, nil
+}
+
+// persistLocked writes msg to the pool's store, if it has one. The caller must hold the
+// write lock.
+func (pool *MessagePool) persistLocked(c cid.Cid, msg *types.SignedMessage) error {
+	if pool.store != nil && pool.flushInterval > 0 {
+		pool.markDirtyLocked(c, false)
+	} else if pool.store != nil {
+		if err := pool.store.Put(c, msg); err != nil {
+			return errors.Wrap(err, "failed to persist message")
+		}
+	}
+	return nil
+}
+
+// insertPendingLocked makes msg, whose CID is c, pending. The caller must hold the write lock.
+func (pool *MessagePool) insertPendingLocked(ctx context.Context, c cid.Cid, msg *timedmessage) {
+	an := newAddressNonce(msg.message)
 	pool.pending[c] = msg
-	pool.addressNonces[newAddressNonce(msg.message)] = true
-	mpSize.Set(ctx, int64(len(pool.pending)))
-	return c, nil
+	pool.addressNonces[an] = c
+	delete(pool.future, an)
+	pool.releaseReservationLocked(an)
+	pool.checkHighWaterLocked(ctx)
 }
 
-// Pending returns all pending messages.
+// Pending returns all pending messages that are not reserved for a block, in canonical
+// order (see types.SortSignedMessages).
 func (pool *MessagePool) Pending() []*types.SignedMessage {
-	pool.lk.Lock()
-	defer pool.lk.Unlock()
+	return pool.messages(false)
+}
+
+// PendingCount returns the number of messages Pending would return, without building the
+// slice.
+func (pool *MessagePool) PendingCount() int {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	return len(pool.pending) - len(pool.reserved)
+}
+
+// PendingSortedByCID returns the same messages as Pending, in ascending order of CID bytes.
+// Unlike canonical order, which groups each sender's messages by nonce, this order ignores
+// senders and nonces, so consecutive messages from one sender are spread through it.
+func (pool *MessagePool) PendingSortedByCID() []*types.SignedMessage {
+	pool.lk.RLock()
+	cids := make([]cid.Cid, 0, len(pool.pending))
+	out := make(map[cid.Cid]*types.SignedMessage, len(pool.pending))
+	for c, msg := range pool.pending {
+		if _, reserved := pool.reserved[c]; reserved {
+			continue
+		}
+		cids = append(cids, c)
+		out[c] = msg.message
+	}
+	pool.lk.RUnlock()
+
+	sort.Slice(cids, func(i, j int) bool {
+		return bytes.Compare(cids[i].Bytes(), cids[j].Bytes()) < 0
+	})
+	msgs := make([]*types.SignedMessage, len(cids))
+	for i, c := range cids {
+		msgs[i] = out[c]
+	}
+	return msgs
+}
+
+// Stream returns a channel on which it sends the messages Pending would return at the time
+// of the call, in the same order. The pool is not locked while the messages are consumed.
+// The channel is closed once all messages are sent or ctx is done, whichever is first.
+func (pool *MessagePool) Stream(ctx context.Context) <-chan *types.SignedMessage {
+	msgs := pool.Pending()
+	out := make(chan *types.SignedMessage)
+	go func() {
+		defer close(out)
+		for _, msg := range msgs {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// messages returns the pending messages in canonical order, including those reserved for
+// a block if includeReserved is true.
+func (pool *MessagePool) messages(includeReserved bool) []*types.SignedMessage {
+	pool.lk.RLock()
 	out := make([]*types.SignedMessage, 0, len(pool.pending))
-	for _, msg := range pool.pending {
+	for c, msg := range pool.pending {
+		if _, reserved := pool.reserved[c]; reserved && !includeReserved {
+			continue
+		}
 		out = append(out, msg.message)
 	}
+	pool.lk.RUnlock()
 
+	types.SortSignedMessages(out)
 	return out
 }
 
-// Get retrieves a message from the pool by CID.
+// Senders returns the distinct senders of pending messages, sorted by address bytes.
+func (pool *MessagePool) Senders() []address.Address {
+	pool.lk.RLock()
+	seen := make(map[address.Address]struct{})
+	for an := range pool.addressNonces {
+		seen[an.addr] = struct{}{}
+	}
+	pool.lk.RUnlock()
+
+	out := make([]address.Address, 0, len(seen))
+	for addr := range seen {
+		out = append(out, addr)
+	}
+	sort.Slice(out, func(i, j int) bool { return bytes.Compare(out[i].Bytes(), out[j].Bytes()) < 0 })
+	return out
+}
+
+// Get retrieves a message from the pool by CID, whether it is pending or held in the future
+// queue.
 func (pool *MessagePool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 	pool.lk.RLock()
 	defer pool.lk.RUnlock()
 	value, ok := pool.pending[c]
 	if !ok {
+		if _, queued, ok := pool.futureByCidLocked(c); ok {
+			return queued.message, true
+		}
 		return nil, ok
 	} else if value == nil {
 		panic("Found nil message for CID " + c.String())
@@ -122,52 +430,204 @@ func (pool *MessagePool) Get(c cid.Cid) (*types.SignedMessage, bool) {
 	return value.message, ok
 }
 
-// Remove removes the message by CID from the pending pool.
+// GetMany retrieves the messages with the given CIDs from the pool. It returns the
+// pending messages in the order of cids, along with the CIDs that are not pending.
+func (pool *MessagePool) GetMany(cids []cid.Cid) ([]*types.SignedMessage, []cid.Cid) {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	var found []*types.SignedMessage
+	var missing []cid.Cid
+	for _, c := range cids {
+		if value, ok := pool.pending[c]; ok {
+			found = append(found, value.message)
+		} else {
+			missing = append(missing, c)
+		}
+	}
+	return found, missing
+}
+
+// BumpGasPrice replaces the pending message with CID c by the same message paying newPrice,
+// signed with signer, and returns the CID of the replacement. newPrice must meet the
+// replace-by-fee threshold. The replacement keeps the expiry of the message it replaces.
+func (pool *MessagePool) BumpGasPrice(ctx context.Context, c cid.Cid, newPrice types.AttoFIL, signer types.Signer) (cid.Cid, error) {
+	pool.lk.RLock()
+	existing, ok := pool.pending[c]
+	pool.lk.RUnlock()
+	if !ok {
+		return cid.Undef, errNotPending
+	}
+
+	replacement, err := types.NewSignedMessage(existing.message.Message, signer, newPrice, existing.message.GasLimit)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to sign replacement message")
+	}
+	if !canReplace(existing.message, replacement) {
+		return cid.Undef, errFeeBumpTooLow
+	}
+
+	receipt, err := pool.addTimedMessage(ctx, &timedmessage{message: replacement, addedAt: existing.addedAt, ttl: existing.ttl})
+	if err != nil {
+		return cid.Undef, err
+	}
+	return receipt.Cid, nil
+}
+
+// Remove removes the message by CID from the pool, whether it is pending or held in the
+// future queue.
 func (pool *MessagePool) Remove(c cid.Cid) {
 	pool.lk.Lock()
 	defer pool.lk.Unlock()
 
+	pool.removeLocked(c)
+	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+}
+
+// removeLocked removes the message by CID. The caller must hold the write lock.
+func (pool *MessagePool) removeLocked(c cid.Cid) {
 	msg, ok := pool.pending[c]
-	if ok {
-		delete(pool.addressNonces, newAddressNonce(msg.message))
-		delete(pool.pending, c)
+	if !ok {
+		if an, _, queued := pool.futureByCidLocked(c); queued {
+			delete(pool.future, an)
+		}
+		return
 	}
-	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+	delete(pool.addressNonces, newAddressNonce(msg.message))
+	delete(pool.pending, c)
+	delete(pool.reserved, c)
+	if pool.store != nil && pool.flushInterval > 0 {
+		pool.markDirtyLocked(c, true)
+	} else if pool.store != nil {
+		if err := pool.store.Delete(c); err != nil {
+			log.Warningf("failed to delete message %s from pool store: %s", c, err)
+		}
+	}
+	pool.checkLowWaterLocked()
+}
+
+// SetAPI replaces the source of chain state the pool uses. Pending messages are kept;
+// only messages added afterwards are checked against the new source.
+func (pool *MessagePool) SetAPI(api MessagePoolAPI) {
+	pool.apiLk.Lock()
+	defer pool.apiLk.Unlock()
+	pool.api = api
+}
+
+func (pool *MessagePool) getAPI() MessagePoolAPI {
+	pool.apiLk.RLock()
+	defer pool.apiLk.RUnlock()
+	return pool.api
 }
 
-// NewMessagePool constructs a new MessagePool.
+// NewMessagePool constructs a new in-memory MessagePool.
 func NewMessagePool(api MessagePoolAPI, cfg *config.MessagePoolConfig, validator MessagePoolValidator) *MessagePool {
 	return &MessagePool{
 		api:           api,
 		cfg:           cfg,
 		validator:     validator,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
+		rejections:    make(map[string]uint64),
+		reservations:  make(map[address.Address]map[uint64]uint64),
+
+		requeueFailures: make(map[cid.Cid]requeueFailure),
+		quarantine:      make(map[cid.Cid]uint64),
+		reserved:        make(map[cid.Cid]struct{}),
+		mined:           make(map[addressNonce]uint64),
+		future:          make(map[addressNonce]*timedmessage),
+		methodLimits:    make(map[string]*rate.Limiter),
+		senderProgress:  make(map[address.Address]*senderProgress),
+
+		unflushedDeletes: make(map[cid.Cid]struct{}),
+
+		acceptedCalls: make(chan struct{}, maxAcceptedHookCalls),
 	}
 }
 
+// NewMessagePoolWithConfig constructs a new MessagePool that persists its messages to store.
+// Messages already in store are loaded into the pool; those that no longer validate are
+// dropped from the store. If cfg.TrustLoaded is set and validator is a StagedValidator,
+// loaded messages skip signature verification, which they passed when they were first
+// added, but are otherwise validated as usual. A nil store gives an in-memory pool, like
+// NewMessagePool.
+//
+// If cfg.FlushInterval is set, changes are written to store in the background at that
+// interval rather than as they happen, and the pool must be closed with Close.
+func NewMessagePoolWithConfig(ctx context.Context, api MessagePoolAPI, cfg *config.MessagePoolConfig, validator MessagePoolValidator, store PoolStore) (*MessagePool, error) {
+	pool := NewMessagePool(api, cfg, validator)
+	if store == nil {
+		return pool, nil
+	}
+
+	var flushInterval time.Duration
+	if cfg.FlushInterval != "" {
+		var err error
+		if flushInterval, err = time.ParseDuration(cfg.FlushInterval); err != nil {
+			return nil, errors.Wrap(err, "invalid message pool flush interval")
+		}
+	}
+
+	msgs, err := store.LoadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load messages from pool store")
+	}
+
+	blockTime, err := api.BlockHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	// The pool has no store while loading, so messages read from it are not written back.
+	for _, msg := range msgs {
+		loaded := &timedmessage{message: msg, addedAt: blockTime, trusted: cfg.TrustLoaded}
+		receipt, err := pool.addTimedMessage(ctx, loaded)
+		if err != nil {
+			log.Infof("dropping stored message: %s", err)
+			c, err := msg.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := store.Delete(c); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if receipt.Replaced.Defined() {
+			if err := store.Delete(receipt.Replaced); err != nil {
+				return nil, err
+			}
+		}
+	}
+	pool.store = store
+
+	if flushInterval > 0 {
+		pool.startFlushing(flushInterval)
+	}
+	return pool, nil
+}
+
 // UpdateMessagePool brings the message pool into the correct state after
 // we accept a new block. It removes messages from the pool that are
 // found in the newly adopted chain and adds back those from the removed
 // chain (if any) that do not appear in the new chain. We think
 // that the right model for keeping the message pool up to date is
 // to think about it like a garbage collector.
+//
+// The chain is walked before the pool is touched, and the resulting changes are
+// applied under a single lock acquisition so that concurrent callers never observe
+// a partially updated pool. If VerifyBlockMessages is set, a block from store that does
+// not hash to the CID it was fetched by fails the update with ErrBlockMessagesMismatch
+// before the pool is touched.
 func (pool *MessagePool) UpdateMessagePool(ctx context.Context, store chain.BlockProvider, oldHead, newHead types.TipSet) error {
-	oldBlocks, newBlocks, err := CollectBlocksToCommonAncestor(ctx, store, oldHead, newHead)
+	if pool.cfg.VerifyBlockMessages {
+		store = verifyingBlockProvider{store}
+	}
+	oldBlocks, newBlocks, err := CollectBlocksToCommonAncestorWithLimit(ctx, store, oldHead, newHead, uint64(pool.cfg.MaxReorgDepth))
 	if err != nil {
 		return err
 	}
 
-	// Add all message from the old blocks to the message pool, so they can be mined again.
-	for _, blk := range oldBlocks {
-		for _, msg := range blk.Messages {
-			_, err = pool.addTimedMessage(ctx, &timedmessage{message: msg, addedAt: uint64(blk.Height)})
-			if err != nil {
-				log.Info(err)
-			}
-		}
-	}
-
 	// Remove all messages in the new blocks from the pool, now mined.
 	// Cid() can error, so collect all the CIDs up front.
 	var removeCids []cid.Cid
@@ -180,66 +640,113 @@ func (pool *MessagePool) UpdateMessagePool(ctx context.Context, store chain.Bloc
 			removeCids = append(removeCids, cid)
 		}
 	}
+
+	// prune all messages that have been in the pool too long
+	headHeight, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+	minimumHeight, err := timeoutHeight(ctx, store, newHead)
+	if err != nil {
+		return err
+	}
+
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	pool.releaseQuarantineLocked(headHeight)
+	pool.forgetMinedLocked(oldBlocks)
+
+	// Add all message from the old blocks to the message pool, so they can be mined again.
+	for _, blk := range oldBlocks {
+		for _, msg := range blk.Messages {
+			pool.requeueLocked(ctx, &timedmessage{message: msg, addedAt: uint64(blk.Height)}, headHeight)
+		}
+	}
+
 	for _, c := range removeCids {
-		pool.Remove(c)
+		pool.removeLocked(c)
 	}
+	pool.recordMinedLocked(newBlocks)
+	pool.notifySenderProgressLocked(newBlocks)
 
-	// prune all messages that have been in the pool too long
-	return pool.timeoutMessages(ctx, store, newHead)
+	pool.expireLocked(headHeight, minimumHeight)
+	pool.expireMinedLocked(minimumHeight)
+	pool.expireFutureLocked(headHeight, minimumHeight)
+	pool.promoteAllLocked(ctx)
+
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	return nil
+}
+
+// SweepExpired removes all messages that have timed out as of head, without otherwise
+// updating the pool for a new head. It returns the CIDs of the removed messages.
+func (pool *MessagePool) SweepExpired(ctx context.Context, store chain.BlockProvider, head types.TipSet) ([]cid.Cid, error) {
+	headHeight, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	minimumHeight, err := timeoutHeight(ctx, store, head)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	removed := pool.expireLocked(headHeight, minimumHeight)
+	pool.expireFutureLocked(headHeight, minimumHeight)
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	return removed, nil
+}
+
+// expireLocked removes messages whose own ttl has run out by headHeight and messages
+// without a ttl that were added before minimumHeight. The caller must hold the write lock.
+func (pool *MessagePool) expireLocked(headHeight, minimumHeight uint64) []cid.Cid {
+	var removed []cid.Cid
+	for c, msg := range pool.pending {
+		if msg.expired(headHeight) || (msg.ttl == 0 && msg.addedAt < minimumHeight) {
+			pool.removeLocked(c)
+			removed = append(removed, c)
+		}
+	}
+	return removed
 }
 
-// timeoutMessages removes all messages from the pool that arrived more than MessageTimeout tip sets ago.
+// timeoutHeight returns the height below which messages that arrived more than MessageTimeout tip sets
+// ago are removed from the pool.
 // Note that we measure the timeout in the number of tip sets we have received rather than a fixed block
 // height. This prevents us from prematurely timing messages that arrive during long chains of null blocks.
 // Also when blocks fill, the rate of message processing will correspond more closely to rate of tip
 // sets than to the expected block time over short timescales.
-func (pool *MessagePool) timeoutMessages(ctx context.Context, store chain.BlockProvider, head types.TipSet) error {
+func timeoutHeight(ctx context.Context, store chain.BlockProvider, head types.TipSet) (uint64, error) {
 	var err error
 
 	lowestTipSet := head
 	minimumHeight, err := lowestTipSet.Height()
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// walk back MessageTimeout tip sets to arrive at the lowest viable block height
 	for i := 0; minimumHeight > 0 && i < MessageTimeOut; i++ {
 		lowestTipSet, err = chain.GetParentTipSet(ctx, store, lowestTipSet)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		minimumHeight, err = lowestTipSet.Height()
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
-	// remove all messages added before minimumHeight
-	for _, cid := range pool.messagesToTimeOut(minimumHeight) {
-		pool.Remove(cid)
-	}
-
-	return nil
+	return minimumHeight, nil
 }
 
-// identify all messages that need to be timed out
-func (pool *MessagePool) messagesToTimeOut(minimumHeight uint64) []cid.Cid {
-	pool.lk.RLock()
-	defer pool.lk.RUnlock()
-
-	cids := []cid.Cid{}
-	for cid, msg := range pool.pending {
-		if msg.addedAt < minimumHeight {
-			cids = append(cids, cid)
-		}
-	}
-	return cids
-}
-
-// LargestNonce returns the largest nonce used by a message from address in the pool.
-// If no messages from address are found, found will be false.
+// LargestNonce returns the largest nonce used by a message from address in the pool,
+// including the future queue. If no messages from address are found, found will be false.
 func (pool *MessagePool) LargestNonce(address address.Address) (largest uint64, found bool) {
-	for _, m := range pool.Pending() {
+	for _, m := range pool.messages(true) {
 		if m.From == address {
 			found = true
 			if uint64(m.Nonce) > largest {
@@ -247,22 +754,169 @@ func (pool *MessagePool) LargestNonce(address address.Address) (largest uint64,
 			}
 		}
 	}
+
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	for an := range pool.future {
+		if an.addr == address && (!found || an.nonce > largest) {
+			largest, found = an.nonce, true
+		}
+	}
 	return
 }
 
+// lowestNonceLocked returns the smallest nonce of a pending message from addr. If there
+// is none, found is false.
+func (pool *MessagePool) lowestNonceLocked(addr address.Address) (lowest uint64, found bool) {
+	for an := range pool.addressNonces {
+		if an.addr == addr && (!found || an.nonce < lowest) {
+			lowest, found = an.nonce, true
+		}
+	}
+	return lowest, found
+}
+
+// actorNonce returns the nonce expected on the next message from addr according to the latest
+// state. An actor that does not exist yet expects nonce zero.
+func (pool *MessagePool) actorNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	act, err := pool.actor(ctx, addr)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(act.Nonce), nil
+}
+
+// actor returns the actor at addr in the latest state, or an empty actor if there is none.
+func (pool *MessagePool) actor(ctx context.Context, addr address.Address) (*actor.Actor, error) {
+	act, err := pool.getAPI().ActorFromLatestState(ctx, addr)
+	if err != nil {
+		if state.IsActorNotFoundError(err) {
+			return &actor.Actor{}, nil
+		}
+		return nil, err
+	}
+	return act, nil
+}
+
 // validateMessage validates that too many messages aren't added to the pool and the ones that are
-// have a high probability of making it through processing.
-func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if len(pool.pending) >= pool.cfg.MaxPoolSize {
-		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
+// have a high probability of making it through processing. If the message is a valid replacement
+// for a pending message with the same actor and nonce, the CID of that message is returned.
+// future is true if the message belongs in the future queue. flagged is true if the message
+// failed a check against the actor's state that is relaxed in sync mode.
+// The message's signature is only verified if verifySignature is true and the pool's validator
+// is a StagedValidator.
+func (pool *MessagePool) validateMessage(ctx context.Context, message *types.SignedMessage, verifySignature bool) (replaced cid.Cid, future bool, flagged bool, err error) {
+	// check that the params are not too large to process cheaply
+	if max := pool.cfg.MaxParamsSize; max > 0 && len(message.Params) > max {
+		return cid.Undef, false, false, types.NewValidationError(types.ValidationParamsTooLarge, errors.Wrapf(ErrParamsTooLarge, "params are %d bytes, more than the maximum of %d", len(message.Params), max))
+	}
+
+	// check that the message carries enough gas for its method to have a chance of succeeding
+	if min, ok := pool.cfg.MinGasForMethod[message.Method]; ok && message.GasLimit < min {
+		return cid.Undef, false, false, types.NewValidationError(types.ValidationGasBelowMethodMin, errors.Wrapf(ErrGasLimitBelowMethodMin, "method %s requires at least %d gas", message.Method, min))
 	}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
+	// check that a message with this nonce was not just mined, before the actor nonce catches up
+	if _, mined := pool.mined[newAddressNonce(message)]; mined {
+		return cid.Undef, false, false, types.NewValidationError(types.ValidationNonceTooLow, ErrAlreadyMined)
+	}
+
+	// check that message with this nonce does not already exist, unless this message replaces it
+	existing, found := pool.addressNonces[newAddressNonce(message)]
 	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+		if !canReplace(pool.pending[existing].message, message) {
+			return cid.Undef, false, false, types.NewValidationError(types.ValidationDuplicateNonce, errDuplicateNonce)
+		}
+		if pool.cfg.ReplaceOnlyHead {
+			if head, _ := pool.lowestNonceLocked(message.From); uint64(message.Nonce) != head {
+				return cid.Undef, false, false, types.NewValidationError(types.ValidationDuplicateNonce, ErrCannotReplaceNonHead)
+			}
+		}
+	}
+
+	// check the message's structure before the more expensive actor lookup and validator
+	if sv, ok := pool.validator.(StagedValidator); ok {
+		if err := sv.ValidateStructure(message); err != nil {
+			return cid.Undef, false, false, err
+		}
+	}
+
+	// check that the message has not already been superseded on chain
+	act, err := pool.actor(ctx, message.From)
+	if err != nil {
+		return cid.Undef, false, false, err
+	}
+	actorNonce := uint64(act.Nonce)
+	if uint64(message.Nonce) < actorNonce {
+		err := types.NewValidationError(types.ValidationNonceTooLow, errors.Errorf("nonce %d too low, actor nonce is %d", message.Nonce, actorNonce))
+		if !pool.relaxedInSyncLocked(err) {
+			return cid.Undef, false, false, err
+		}
+		log.Warningf("admitting message from %s during sync: %s", message.From, err)
+		flagged = true
+	}
+
+	// check that the sender can pay for this message together with its other pending messages
+	if err := pool.checkPendingFundsLocked(message, act, existing); err != nil {
+		if !pool.relaxedInSyncLocked(err) {
+			return cid.Undef, false, false, err
+		}
+		log.Warningf("admitting message from %s during sync: %s", message.From, err)
+		flagged = true
+	}
+
+	// check that there is room for the message, apart from pending messages if its predecessors are missing
+	future = !found && pool.cfg.FutureQueueSize > 0 && uint64(message.Nonce) > pool.nextNonceLocked(message.From, actorNonce)
+	if future {
+		if queued, ok := pool.future[newAddressNonce(message)]; ok {
+			if !canReplace(queued.message, message) {
+				return cid.Undef, false, false, types.NewValidationError(types.ValidationDuplicateNonce, errDuplicateNonce)
+			}
+		} else if len(pool.future) >= pool.cfg.FutureQueueSize {
+			return cid.Undef, false, false, types.NewValidationError(types.ValidationPoolFull, errFutureQueueFull)
+		}
+	} else if !found && len(pool.pending) >= pool.cfg.MaxPoolSize {
+		return cid.Undef, false, false, types.NewValidationError(types.ValidationPoolFull, errPoolFull)
 	}
 
 	// check that the message is likely to succeed in processing
-	return pool.validator.Validate(ctx, message)
+	if err := pool.validateRemainingLocked(ctx, message, verifySignature); err != nil {
+		if !pool.relaxedInSyncLocked(err) {
+			return cid.Undef, false, false, err
+		}
+		log.Warningf("admitting message from %s during sync: %s", message.From, err)
+		flagged = true
+	}
+	if !found {
+		return cid.Undef, future, flagged, nil
+	}
+	return existing, false, flagged, nil
+}
+
+// validateRemainingLocked runs the pool's validator on message, apart from the structural
+// checks validateMessage has already run if the validator is a StagedValidator. The
+// signature of such a validator is only verified if verifySignature is true; validators that
+// are not staged always run in full. The caller must hold the lock.
+func (pool *MessagePool) validateRemainingLocked(ctx context.Context, message *types.SignedMessage, verifySignature bool) error {
+	sv, ok := pool.validator.(StagedValidator)
+	if !ok {
+		return pool.validator.Validate(ctx, message)
+	}
+	if verifySignature {
+		if err := sv.ValidateSignature(message); err != nil {
+			return err
+		}
+	}
+	return sv.ValidateState(ctx, message)
+}
+
+// canReplace returns true if replacement pays a gas price at least ReplaceByFeePercent
+// higher than that of existing.
+func canReplace(existing, replacement *types.SignedMessage) bool {
+	if !replacement.GasPrice.GreaterThan(&existing.GasPrice) {
+		return false
+	}
+	minimum := existing.GasPrice.MulBigInt(big.NewInt(100 + ReplaceByFeePercent))
+	offered := replacement.GasPrice.MulBigInt(big.NewInt(100))
+	return offered.GreaterEqual(minimum)
 }
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// maxAcceptedHookCalls is the most OnAccepted callbacks run at once when they are run
+// asynchronously. Adding a message waits for a callback to finish beyond that.
+const maxAcceptedHookCalls = 64
+
+// OnAccepted registers cb to be called with each message newly admitted to the pool by Add,
+// AddWithTTL, AddMany or BumpGasPrice, so that it can be broadcast to peers. It is not
+// called for duplicates or rejected messages. cb is called after the pool lock is released;
+// if the AsyncAcceptedHook config option is set it is called on its own goroutine, otherwise
+// before the add returns.
+func (pool *MessagePool) OnAccepted(cb func(ctx context.Context, msg *types.SignedMessage)) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.onAccepted = cb
+}
+
+// accepted calls the OnAccepted callback for msgs. The caller must not hold the lock.
+func (pool *MessagePool) accepted(ctx context.Context, msgs ...*types.SignedMessage) {
+	pool.lk.RLock()
+	cb := pool.onAccepted
+	pool.lk.RUnlock()
+	if cb == nil {
+		return
+	}
+
+	for _, msg := range msgs {
+		if !pool.cfg.AsyncAcceptedHook {
+			cb(ctx, msg)
+			continue
+		}
+		pool.acceptedCalls <- struct{}{}
+		go func(msg *types.SignedMessage) {
+			defer func() { <-pool.acceptedCalls }()
+			cb(ctx, msg)
+		}(msg)
+	}
+}
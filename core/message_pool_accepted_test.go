@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolOnAccepted(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("called once for an accepted message", func(t *testing.T) {
+		validator := th.NewMockMessagePoolValidator()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+		var got []*types.SignedMessage
+		pool.OnAccepted(func(ctx context.Context, msg *types.SignedMessage) {
+			got = append(got, msg)
+		})
+
+		msg := mustSetGasPrice(mockSigner, newSignedMessage(), 100)
+		_, err := pool.Add(ctx, msg)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, msg)
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{msg}, got)
+
+		replacement := mustSetGasPrice(mockSigner, msg, 200)
+		_, err = pool.Add(ctx, replacement)
+		require.NoError(t, err)
+		assert.Equal(t, []*types.SignedMessage{msg, replacement}, got)
+
+		validator.Valid = false
+		_, err = pool.Add(ctx, mustSetNonce(mockSigner, newSignedMessage(), 1))
+		require.Error(t, err)
+		assert.Len(t, got, 2)
+	})
+
+	t.Run("called asynchronously when configured", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.AsyncAcceptedHook = true
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+		got := make(chan *types.SignedMessage, 1)
+		pool.OnAccepted(func(ctx context.Context, msg *types.SignedMessage) {
+			got <- msg
+		})
+
+		msg := newSignedMessage()
+		_, err := pool.Add(ctx, msg)
+		require.NoError(t, err)
+		assert.Equal(t, msg, <-got)
+	})
+}
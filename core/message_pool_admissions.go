@@ -0,0 +1,58 @@
+package core
+
+import (
+	"time"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// AdmissionRecord describes one attempt to add a message to the pool.
+type AdmissionRecord struct {
+	Time     time.Time       `json:"time"`
+	From     address.Address `json:"from"`
+	Nonce    uint64          `json:"nonce"`
+	Accepted bool            `json:"accepted"`
+	// Reason is the error the message was rejected with, empty if it was accepted.
+	Reason string `json:"reason"`
+}
+
+// RecentAdmissions returns the most recent attempts to add a message to the pool, newest
+// first. The pool remembers the last AdmissionLogSize attempts.
+func (pool *MessagePool) RecentAdmissions() []AdmissionRecord {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	n := len(pool.admissions)
+	out := make([]AdmissionRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, pool.admissions[(pool.admissionsNext-i+n)%n])
+	}
+	return out
+}
+
+// recordAdmissionLocked notes the outcome of adding msg, which was rejected if err is not
+// nil. The caller must hold the write lock.
+func (pool *MessagePool) recordAdmissionLocked(msg *types.SignedMessage, err error) {
+	record := AdmissionRecord{
+		Time:     time.Now(),
+		From:     msg.From,
+		Nonce:    uint64(msg.Nonce),
+		Accepted: err == nil,
+	}
+	if err != nil {
+		pool.rejections[types.ValidationCodeOf(err).String()]++
+		record.Reason = err.Error()
+	}
+
+	size := pool.cfg.AdmissionLogSize
+	if size <= 0 {
+		return
+	}
+	if len(pool.admissions) < size {
+		pool.admissions = append(pool.admissions, record)
+	} else {
+		pool.admissions[pool.admissionsNext] = record
+	}
+	pool.admissionsNext = (pool.admissionsNext + 1) % size
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolRecentAdmissions(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mpoolCfg := config.NewDefaultConfig().Mpool
+	mpoolCfg.AdmissionLogSize = 3
+	validator := th.NewMockMessagePoolValidator()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, validator)
+	assert.Empty(t, pool.RecentAdmissions())
+
+	smsgs := types.NewSignedMsgs(4, mockSigner)
+	_, err := pool.Add(ctx, smsgs[0])
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsgs[1])
+	require.NoError(t, err)
+
+	validator.Valid = false
+	_, err = pool.Add(ctx, smsgs[2])
+	require.Error(t, err)
+
+	records := pool.RecentAdmissions()
+	require.Len(t, records, 3)
+	assert.Equal(t, uint64(smsgs[2].Nonce), records[0].Nonce)
+	assert.False(t, records[0].Accepted)
+	assert.Equal(t, err.Error(), records[0].Reason)
+	assert.Equal(t, uint64(smsgs[1].Nonce), records[1].Nonce)
+	assert.True(t, records[1].Accepted)
+	assert.Empty(t, records[1].Reason)
+	assert.Equal(t, uint64(smsgs[0].Nonce), records[2].Nonce)
+	assert.Equal(t, smsgs[0].From, records[2].From)
+
+	t.Run("oldest records are overwritten", func(t *testing.T) {
+		validator.Valid = true
+		_, err := pool.Add(ctx, smsgs[3])
+		require.NoError(t, err)
+
+		records := pool.RecentAdmissions()
+		require.Len(t, records, 3)
+		assert.Equal(t, uint64(smsgs[3].Nonce), records[0].Nonce)
+		assert.Equal(t, uint64(smsgs[2].Nonce), records[1].Nonce)
+		assert.Equal(t, uint64(smsgs[1].Nonce), records[2].Nonce)
+		assert.False(t, records[1].Time.After(records[0].Time))
+	})
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// NonceChainInfo describes the pending messages from a single sender relative to the
+// sender's actor nonce. It is intended for debugging pools whose messages are not being mined.
+type NonceChainInfo struct {
+	// ActorNonce is the nonce expected on the sender's next message according to the latest state.
+	ActorNonce uint64 `json:"actorNonce"`
+	// Pending is the sorted nonces of the sender's pending messages.
+	Pending []uint64 `json:"pending"`
+	// Gaps is the sorted nonces between ActorNonce and the largest pending nonce that have
+	// no pending message.
+	Gaps []uint64 `json:"gaps"`
+	// HasHead is true if a pending message carries ActorNonce and so is executable now.
+	HasHead bool `json:"hasHead"`
+	// Head is the nonce of the executable head message, valid only if HasHead is true.
+	Head uint64 `json:"head"`
+	// ExecutableThrough is the last nonce of the contiguous run of pending messages starting
+	// at Head, valid only if HasHead is true.
+	ExecutableThrough uint64 `json:"executableThrough"`
+}
+
+// DumpNonceChains returns the nonce chain of every sender with pending messages, keyed
+// by the sender's address string.
+func (pool *MessagePool) DumpNonceChains(ctx context.Context) (map[string]NonceChainInfo, error) {
+	nonces := make(map[address.Address][]uint64)
+	for _, msg := range pool.messages(true) {
+		nonces[msg.From] = append(nonces[msg.From], uint64(msg.Nonce))
+	}
+
+	out := make(map[string]NonceChainInfo, len(nonces))
+	for from, pending := range nonces {
+		actorNonce, err := pool.actorNonce(ctx, from)
+		if err != nil {
+			return nil, err
+		}
+		out[from.String()] = newNonceChainInfo(actorNonce, pending)
+	}
+	return out, nil
+}
+
+func newNonceChainInfo(actorNonce uint64, pending []uint64) NonceChainInfo {
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+	info := NonceChainInfo{
+		ActorNonce: actorNonce,
+		Pending:    pending,
+		Gaps:       []uint64{},
+	}
+
+	next := actorNonce
+	for _, n := range pending {
+		if n < actorNonce {
+			// Already applied on chain; it will leave the pool when the block is processed.
+			continue
+		}
+		if n == actorNonce {
+			info.HasHead = true
+			info.Head = n
+			info.ExecutableThrough = n
+		} else if info.HasHead && len(info.Gaps) == 0 && n == info.ExecutableThrough+1 {
+			info.ExecutableThrough = n
+		}
+		for ; next < n; next++ {
+			info.Gaps = append(info.Gaps, next)
+		}
+		next = n + 1
+	}
+	return info
+}
+
+// DumpDOT writes the nonce chains of all senders to w as a GraphViz digraph with one
+// cluster per sender. Executable messages are drawn solid, blocked ones dashed and
+// gaps as red placeholders.
+func (pool *MessagePool) DumpDOT(ctx context.Context, w io.Writer) error {
+	chains, err := pool.DumpNonceChains(ctx)
+	if err != nil {
+		return err
+	}
+
+	senders := make([]string, 0, len(chains))
+	for from := range chains {
+		senders = append(senders, from)
+	}
+	sort.Strings(senders)
+
+	if _, err := fmt.Fprintln(w, "digraph noncechains {"); err != nil {
+		return err
+	}
+	for i, from := range senders {
+		info := chains[from]
+		lines := []string{
+			fmt.Sprintf("  subgraph cluster_%d {", i),
+			fmt.Sprintf("    label=%q;", fmt.Sprintf("%s (actor nonce %d)", from, info.ActorNonce)),
+		}
+
+		nodes := make(map[uint64]string)
+		for _, n := range info.Pending {
+			style := "dashed"
+			if info.HasHead && n >= info.Head && n <= info.ExecutableThrough {
+				style = "solid"
+			}
+			nodes[n] = fmt.Sprintf("[label=\"%d\", style=%s]", n, style)
+		}
+		for _, n := range info.Gaps {
+			nodes[n] = fmt.Sprintf("[label=\"%d\", style=dotted, color=red]", n)
+		}
+
+		order := make([]uint64, 0, len(nodes))
+		for n := range nodes {
+			order = append(order, n)
+		}
+		sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+		for j, n := range order {
+			lines = append(lines, fmt.Sprintf("    \"%d_%d\" %s;", i, n, nodes[n]))
+			if j > 0 {
+				lines = append(lines, fmt.Sprintf("    \"%d_%d\" -> \"%d_%d\";", i, order[j-1], i, n))
+			}
+		}
+		lines = append(lines, "  }")
+
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+// PendingSummaryRow is one pending message as shown in a tabular status listing.
+type PendingSummaryRow struct {
+	From     address.Address `json:"from"`
+	To       address.Address `json:"to"`
+	Nonce    uint64          `json:"nonce"`
+	Value    *types.AttoFIL  `json:"value"`
+	GasPrice types.AttoFIL   `json:"gasPrice"`
+	GasLimit types.GasUnits  `json:"gasLimit"`
+	// AgeBlocks is the number of blocks since the message was added to the pool.
+	AgeBlocks uint64 `json:"ageBlocks"`
+}
+
+// Summary returns a row for every pending message, sorted by sender and then nonce.
+// If the current block height is unavailable all ages are reported as zero.
+func (pool *MessagePool) Summary() []PendingSummaryRow {
+	height, err := pool.getAPI().BlockHeight()
+	if err != nil {
+		log.Warningf("failed to get block height for pending summary: %s", err)
+	}
+
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	rows := make([]PendingSummaryRow, 0, len(pool.pending))
+	for _, tm := range pool.pending {
+		msg := tm.message
+		row := PendingSummaryRow{
+			From:     msg.From,
+			To:       msg.To,
+			Nonce:    uint64(msg.Nonce),
+			Value:    msg.Value,
+			GasPrice: msg.GasPrice,
+			GasLimit: msg.GasLimit,
+		}
+		if err == nil && height > tm.addedAt {
+			row.AgeBlocks = height - tm.addedAt
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if c := bytes.Compare(rows[i].From.Bytes(), rows[j].From.Bytes()); c != 0 {
+			return c < 0
+		}
+		return rows[i].Nonce < rows[j].Nonce
+	})
+	return rows
+}
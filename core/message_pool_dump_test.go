@@ -0,0 +1,85 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolDumpNonceChains(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := th.NewTestMessagePoolAPI(0)
+	api.Actor.Nonce = 3
+	pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	for _, n := range []types.Uint64{6, 3, 4} {
+		MustAdd(pool, mustSetNonce(mockSigner, newSignedMessage(), n))
+	}
+
+	chains, err := pool.DumpNonceChains(ctx)
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+
+	info := chains[mockSigner.Addresses[0].String()]
+	assert.Equal(t, uint64(3), info.ActorNonce)
+	assert.Equal(t, []uint64{3, 4, 6}, info.Pending)
+	assert.Equal(t, []uint64{5}, info.Gaps)
+	assert.True(t, info.HasHead)
+	assert.Equal(t, uint64(3), info.Head)
+	assert.Equal(t, uint64(4), info.ExecutableThrough)
+
+	t.Run("no head when actor nonce is missing", func(t *testing.T) {
+		api.Actor.Nonce = 2
+		chains, err := pool.DumpNonceChains(ctx)
+		require.NoError(t, err)
+
+		info := chains[mockSigner.Addresses[0].String()]
+		assert.False(t, info.HasHead)
+		assert.Equal(t, []uint64{2, 5}, info.Gaps)
+	})
+
+	t.Run("renders dot", func(t *testing.T) {
+		api.Actor.Nonce = 3
+		var buf bytes.Buffer
+		require.NoError(t, pool.DumpDOT(ctx, &buf))
+
+		dot := buf.String()
+		assert.Contains(t, dot, "digraph noncechains {")
+		assert.Contains(t, dot, `"0_3" [label="3", style=solid];`)
+		assert.Contains(t, dot, `"0_5" [label="5", style=dotted, color=red];`)
+		assert.Contains(t, dot, `"0_6" [label="6", style=dashed];`)
+		assert.Contains(t, dot, `"0_5" -> "0_6";`)
+	})
+}
+
+func TestMessagePoolSummary(t *testing.T) {
+	tf.UnitTest(t)
+
+	api := th.NewTestMessagePoolAPI(10)
+	pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	api.Height = 12
+	MustAdd(pool, mustSetNonce(mockSigner, newSignedMessage(), 1))
+	api.Height = 10
+	MustAdd(pool, mustSetNonce(mockSigner, newSignedMessage(), 0))
+	api.Height = 15
+
+	rows := pool.Summary()
+	require.Len(t, rows, 2)
+	assert.Equal(t, mockSigner.Addresses[0], rows[0].From)
+	assert.Equal(t, uint64(0), rows[0].Nonce)
+	assert.Equal(t, uint64(5), rows[0].AgeBlocks)
+	assert.Equal(t, mockSigner.Addresses[0], rows[1].From)
+	assert.Equal(t, uint64(1), rows[1].Nonce)
+	assert.Equal(t, uint64(3), rows[1].AgeBlocks)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// permanentCodes are the validation failures that resubmitting the same message cannot fix.
+// Other failures may pass later: a full pool drains, a nonce gap fills as its predecessors
+// arrive and a sender's balance may be topped up.
+var permanentCodes = map[types.ValidationCode]bool{
+	types.ValidationDuplicateNonce:    true,
+	types.ValidationBadSignature:      true,
+	types.ValidationSelfSend:          true,
+	types.ValidationBadSender:         true,
+	types.ValidationBadRecipient:      true,
+	types.ValidationGasPriceZero:      true,
+	types.ValidationNonAccountActor:   true,
+	types.ValidationNegativeValue:     true,
+	types.ValidationGasLimit:          true,
+	types.ValidationNonceTooLow:       true,
+	types.ValidationGasBelowMethodMin: true,
+	types.ValidationParamsTooLarge:    true,
+}
+
+// permanentErrors are the pool's errors outside message validation that resubmitting
+// cannot fix.
+var permanentErrors = map[error]bool{
+	errNotPending:    true,
+	errFeeBumpTooLow: true,
+}
+
+// IsPermanent returns true if err, returned by adding a message to the pool, means the
+// message will never be accepted as it is.
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	cause := errors.Cause(err)
+	if cause == context.Canceled || cause == context.DeadlineExceeded {
+		return false
+	}
+	return permanentErrors[cause] || permanentCodes[types.ValidationCodeOf(err)]
+}
+
+// IsTransient returns true if err, returned by adding a message to the pool, may not recur
+// if the message is resubmitted later. This includes failures to look up the sender's actor
+// and cancelled or timed out contexts.
+func IsTransient(err error) bool {
+	return err != nil && !IsPermanent(err)
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolErrorClassification(t *testing.T) {
+	tf.UnitTest(t)
+
+	validationErr := func(code types.ValidationCode) error {
+		return errors.Wrap(types.NewValidationError(code, errors.New(code.String())), "validation error adding message to pool")
+	}
+
+	permanent := map[string]error{
+		"bad signature":      validationErr(types.ValidationBadSignature),
+		"self send":          validationErr(types.ValidationSelfSend),
+		"negative value":     validationErr(types.ValidationNegativeValue),
+		"duplicate nonce":    validationErr(types.ValidationDuplicateNonce),
+		"gas limit exceeded": validationErr(types.ValidationGasLimit),
+		"nonce too low":      validationErr(types.ValidationNonceTooLow),
+		"non-head replace":   types.NewValidationError(types.ValidationDuplicateNonce, ErrCannotReplaceNonHead),
+		"fee bump too low":   errFeeBumpTooLow,
+		"not pending":        errNotPending,
+	}
+	for name, err := range permanent {
+		assert.True(t, IsPermanent(err), name)
+		assert.False(t, IsTransient(err), name)
+	}
+
+	transient := map[string]error{
+		"nonce gap":            validationErr(types.ValidationNonceGap),
+		"pool full":            validationErr(types.ValidationPoolFull),
+		"actor lookup failure": errors.Wrap(errors.New("state unavailable"), "failed to get actor"),
+		"cancelled":            errors.Wrap(context.Canceled, "validating"),
+		"timeout":              context.DeadlineExceeded,
+	}
+	for name, err := range transient {
+		assert.True(t, IsTransient(err), name)
+		assert.False(t, IsPermanent(err), name)
+	}
+
+	assert.False(t, IsTransient(nil))
+	assert.False(t, IsPermanent(nil))
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// markDirtyLocked notes that the message with CID c was added, or removed if removed is
+// true, since the pending set was last flushed. The caller must hold the write lock.
+func (pool *MessagePool) markDirtyLocked(c cid.Cid, removed bool) {
+	pool.dirty = true
+	if removed {
+		pool.unflushedDeletes[c] = struct{}{}
+	} else {
+		delete(pool.unflushedDeletes, c)
+	}
+}
+
+// Flush writes the full pending set to the pool's store and deletes messages removed since
+// the last flush. It does nothing if the pool has no store or no changes to write. Pools
+// without a FlushInterval write changes as they happen, so need not be flushed.
+func (pool *MessagePool) Flush(ctx context.Context) error {
+	pool.flushLk.Lock()
+	defer pool.flushLk.Unlock()
+
+	pool.lk.Lock()
+	if pool.store == nil || !pool.dirty {
+		pool.lk.Unlock()
+		return nil
+	}
+	msgs := make(map[cid.Cid]*types.SignedMessage, len(pool.pending))
+	for c, msg := range pool.pending {
+		msgs[c] = msg.message
+	}
+	deletes := pool.unflushedDeletes
+	pool.unflushedDeletes = make(map[cid.Cid]struct{})
+	pool.dirty = false
+	pool.lk.Unlock()
+
+	err := pool.writeToStore(ctx, msgs, deletes)
+	if err != nil {
+		// Leave the changes to be written by the next flush.
+		pool.lk.Lock()
+		pool.dirty = true
+		for c := range deletes {
+			if _, ok := pool.pending[c]; !ok {
+				pool.unflushedDeletes[c] = struct{}{}
+			}
+		}
+		pool.lk.Unlock()
+	}
+	return err
+}
+
+func (pool *MessagePool) writeToStore(ctx context.Context, msgs map[cid.Cid]*types.SignedMessage, deletes map[cid.Cid]struct{}) error {
+	for c := range deletes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := pool.store.Delete(c); err != nil {
+			return errors.Wrapf(err, "failed to delete message %s from pool store", c)
+		}
+	}
+	for c, msg := range msgs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := pool.store.Put(c, msg); err != nil {
+			return errors.Wrapf(err, "failed to persist message %s", c)
+		}
+	}
+	return nil
+}
+
+// startFlushing flushes the pool every interval until Close is called.
+func (pool *MessagePool) startFlushing(interval time.Duration) {
+	pool.lk.Lock()
+	pool.flushInterval = interval
+	pool.stopFlushing = make(chan struct{})
+	pool.lk.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := pool.Flush(context.Background()); err != nil {
+					log.Warningf("failed to flush message pool: %s", err)
+				}
+			case <-pool.stopFlushing:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops background flushing, if any, and flushes the pool a final time.
+func (pool *MessagePool) Close() error {
+	pool.closeOnce.Do(func() {
+		if pool.stopFlushing != nil {
+			close(pool.stopFlushing)
+		}
+	})
+	return pool.Flush(context.Background())
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrInsufficientPendingFunds is returned when a sender's balance cannot cover the funds
+// required by all its pending messages times the configured RequiredBalanceMultiple.
+var ErrInsufficientPendingFunds = errors.New("balance does not cover the sender's pending messages")
+
+// checkPendingFundsLocked checks, if RequiredBalanceMultiple is set, that the balance of
+// act, the sender of msg, covers the funds required by msg and the sender's other pending
+// messages, apart from the one with CID replaced, times the multiple. The caller must hold
+// the lock.
+func (pool *MessagePool) checkPendingFundsLocked(msg *types.SignedMessage, act *actor.Actor, replaced cid.Cid) error {
+	multiple := pool.cfg.RequiredBalanceMultiple
+	if multiple <= 0 {
+		return nil
+	}
+
+	msgs := []*types.SignedMessage{msg}
+	for c, pending := range pool.pending {
+		if pending.message.From == msg.From && c != replaced {
+			msgs = append(msgs, pending.message)
+		}
+	}
+	total, err := types.TotalRequiredFunds(msgs)
+	if err != nil {
+		return types.NewValidationError(types.ValidationInsufficientBalance, errors.Wrap(ErrInsufficientPendingFunds, err.Error()))
+	}
+	required := total.MulBigInt(big.NewInt(int64(multiple)))
+
+	balance := types.ZeroAttoFIL
+	if act.Balance != nil {
+		balance = act.Balance
+	}
+	if balance.LessThan(required) {
+		return types.NewValidationError(types.ValidationInsufficientBalance, errors.Wrapf(ErrInsufficientPendingFunds, "%d pending messages require %s, balance is %s", len(msgs), required, balance))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolRequiredBalanceMultiple(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	newValueMessage := func(nonce uint64) *types.SignedMessage {
+		msg := types.NewMessage(mockSigner.Addresses[0], address.TestAddress, nonce, types.NewAttoFILFromFIL(10), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(1), types.NewGasUnits(100))
+		require.NoError(t, err)
+		return smsg
+	}
+	msg1 := newValueMessage(0)
+	msg2 := newValueMessage(1)
+
+	// Enough for either message alone, but not both.
+	newPool := func(multiple int) *MessagePool {
+		api := th.NewTestMessagePoolAPI(0)
+		api.Actor.Balance = msg1.RequiredFunds().Add(msg2.RequiredFunds()).Sub(types.NewAttoFIL(big.NewInt(1)))
+		require.True(t, msg1.RequiredFunds().LessEqual(api.Actor.Balance))
+		require.True(t, msg2.RequiredFunds().LessEqual(api.Actor.Balance))
+
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.RequiredBalanceMultiple = multiple
+		return NewMessagePool(api, cfg, th.NewMockMessagePoolValidator())
+	}
+
+	t.Run("messages are checked one at a time by default", func(t *testing.T) {
+		pool := newPool(config.NewDefaultConfig().Mpool.RequiredBalanceMultiple)
+		MustAdd(pool, msg1, msg2)
+		assertPoolEquals(t, pool, msg1, msg2)
+	})
+
+	t.Run("pending messages are checked together", func(t *testing.T) {
+		pool := newPool(1)
+		MustAdd(pool, msg1)
+		_, err := pool.Add(ctx, msg2)
+		require.Error(t, err)
+		assert.Equal(t, types.ValidationInsufficientBalance, types.ValidationCodeOf(err))
+		assert.Equal(t, ErrInsufficientPendingFunds, errors.Cause(err))
+		assertPoolEquals(t, pool, msg1)
+	})
+
+	t.Run("the multiple scales the requirement", func(t *testing.T) {
+		require.True(t, msg1.RequiredFunds().Equal(msg2.RequiredFunds()))
+		pool := newPool(2)
+		_, err := pool.Add(ctx, msg1)
+		assert.Equal(t, ErrInsufficientPendingFunds, errors.Cause(err))
+		assertPoolEquals(t, pool)
+	})
+}
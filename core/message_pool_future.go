@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+var errFutureQueueFull = errors.New("message pool future queue is full")
+
+// FutureCount returns the number of messages held until the nonces before theirs arrive.
+// Such messages are only held apart from the pending messages if FutureQueueSize is set.
+func (pool *MessagePool) FutureCount() int {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	return len(pool.future)
+}
+
+// PromoteReady makes pending the future messages from addr whose nonces now follow on from
+// the actor's nonce and the pending messages, and drops those whose nonces the actor has
+// already used. It returns the CIDs of the promoted messages. The pool calls it itself as
+// messages arrive and blocks are applied.
+func (pool *MessagePool) PromoteReady(ctx context.Context, addr address.Address) ([]cid.Cid, error) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	promoted, err := pool.promoteReadyLocked(ctx, addr)
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	return promoted, err
+}
+
+// promoteReadyLocked is PromoteReady. The caller must hold the write lock.
+func (pool *MessagePool) promoteReadyLocked(ctx context.Context, addr address.Address) ([]cid.Cid, error) {
+	actorNonce, err := pool.actorNonce(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	for an := range pool.future {
+		if an.addr == addr && an.nonce < actorNonce {
+			delete(pool.future, an)
+		}
+	}
+	return pool.promoteFromLocked(ctx, addr, pool.nextNonceLocked(addr, actorNonce)), nil
+}
+
+// promoteAllLocked promotes the ready future messages of every sender. The caller must hold
+// the write lock.
+func (pool *MessagePool) promoteAllLocked(ctx context.Context) {
+	senders := make(map[address.Address]struct{})
+	for an := range pool.future {
+		senders[an.addr] = struct{}{}
+	}
+	for addr := range senders {
+		if _, err := pool.promoteReadyLocked(ctx, addr); err != nil {
+			log.Warningf("failed to promote future messages from %s: %s", addr, err)
+		}
+	}
+}
+
+// promoteFromLocked makes pending the future messages from addr with consecutive nonces
+// starting at nonce, for as long as the pool has room. The caller must hold the write lock.
+func (pool *MessagePool) promoteFromLocked(ctx context.Context, addr address.Address, nonce uint64) []cid.Cid {
+	var promoted []cid.Cid
+	for len(pool.pending) < pool.cfg.MaxPoolSize {
+		msg, ok := pool.future[addressNonce{addr: addr, nonce: nonce}]
+		if !ok {
+			break
+		}
+		c := msg.cid
+		if err := pool.persistLocked(c, msg.message); err != nil {
+			log.Warningf("failed to promote future message %s: %s", c, err)
+			break
+		}
+		pool.insertPendingLocked(ctx, c, msg)
+		promoted = append(promoted, c)
+		nonce++
+	}
+	return promoted
+}
+
+// nextNonceLocked returns the first nonce from actorNonce on that no pending message from
+// addr has. The caller must hold the lock.
+func (pool *MessagePool) nextNonceLocked(addr address.Address, actorNonce uint64) uint64 {
+	next := actorNonce
+	for {
+		if _, ok := pool.addressNonces[addressNonce{addr: addr, nonce: next}]; !ok {
+			return next
+		}
+		next++
+	}
+}
+
+// addFutureLocked queues msg, whose CID is c, until the nonces before its own arrive. It
+// replaces any future message with the same sender and nonce. The caller must hold the
+// write lock.
+func (pool *MessagePool) addFutureLocked(c cid.Cid, msg *timedmessage) AddReceipt {
+	an := newAddressNonce(msg.message)
+	receipt := AddReceipt{Cid: c}
+	if queued, ok := pool.future[an]; ok {
+		receipt.Replaced = queued.cid
+	}
+	pool.future[an] = msg
+	return receipt
+}
+
+// futureByCidLocked returns the future message with CID c and its sender and nonce, if the
+// future queue holds it. The caller must hold the lock.
+func (pool *MessagePool) futureByCidLocked(c cid.Cid) (addressNonce, *timedmessage, bool) {
+	for an, queued := range pool.future {
+		if queued.cid == c {
+			return an, queued, true
+		}
+	}
+	return addressNonce{}, nil, false
+}
+
+// isFutureLocked returns true if msg, whose CID is c, is in the future queue. The caller
+// must hold the lock.
+func (pool *MessagePool) isFutureLocked(c cid.Cid, msg *types.SignedMessage) bool {
+	queued, ok := pool.future[newAddressNonce(msg)]
+	if !ok {
+		return false
+	}
+	return queued.cid == c
+}
+
+// expireFutureLocked drops future messages that have waited as long as a pending message
+// may. The caller must hold the write lock.
+func (pool *MessagePool) expireFutureLocked(headHeight, minimumHeight uint64) {
+	for an, msg := range pool.future {
+		if msg.expired(headHeight) || (msg.ttl == 0 && msg.addedAt < minimumHeight) {
+			delete(pool.future, an)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolFutureQueue(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	newPool := func(maxPoolSize, futureQueueSize int) (*MessagePool, *th.TestMessagePoolAPI) {
+		api := th.NewTestMessagePoolAPI(0)
+		api.Actor.Nonce = 3
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxPoolSize = maxPoolSize
+		cfg.FutureQueueSize = futureQueueSize
+		return NewMessagePool(api, cfg, th.NewMockMessagePoolValidator()), api
+	}
+	msgs := make([]*types.SignedMessage, 8)
+	for i := range msgs {
+		msgs[i] = mustSetNonce(mockSigner, newSignedMessage(), types.Uint64(i))
+	}
+
+	t.Run("gapped messages wait for their predecessors", func(t *testing.T) {
+		pool, _ := newPool(10, 10)
+
+		MustAdd(pool, msgs[5])
+		assert.Equal(t, 1, pool.FutureCount())
+		assert.Empty(t, pool.Pending())
+
+		t.Log("adding it again is a no-op")
+		receipt, err := pool.AddWithReceipt(ctx, msgs[5])
+		require.NoError(t, err)
+		assert.True(t, receipt.Duplicate)
+
+		MustAdd(pool, msgs[3])
+		assert.Equal(t, 1, pool.FutureCount())
+		assertPoolEquals(t, pool, msgs[3])
+
+		MustAdd(pool, msgs[4])
+		assert.Equal(t, 0, pool.FutureCount())
+		assertPoolEquals(t, pool, msgs[3], msgs[4], msgs[5])
+	})
+
+	t.Run("future messages can be got and removed by CID", func(t *testing.T) {
+		pool, _ := newPool(10, 10)
+
+		c, err := pool.Add(ctx, msgs[5])
+		require.NoError(t, err)
+		got, ok := pool.Get(c)
+		require.True(t, ok)
+		assert.True(t, msgs[5].Equals(got))
+
+		pool.Remove(c)
+		assert.Equal(t, 0, pool.FutureCount())
+		_, ok = pool.Get(c)
+		assert.False(t, ok)
+
+		t.Log("filling the gap no longer promotes it")
+		MustAdd(pool, msgs[3], msgs[4])
+		assertPoolEquals(t, pool, msgs[3], msgs[4])
+	})
+
+	t.Run("filling a gap reports the promoted messages", func(t *testing.T) {
+		pool, _ := newPool(10, 10)
+
+		MustAdd(pool, msgs[4], msgs[5], msgs[7])
+		assert.Equal(t, 3, pool.FutureCount())
+
+		receipt, err := pool.AddWithReceipt(ctx, msgs[3])
+		require.NoError(t, err)
+		c4, err := msgs[4].Cid()
+		require.NoError(t, err)
+		c5, err := msgs[5].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{c4, c5}, receipt.Promoted)
+		assert.Equal(t, 1, pool.FutureCount())
+		assertPoolEquals(t, pool, msgs[3], msgs[4], msgs[5])
+
+		receipt, err = pool.AddWithReceipt(ctx, msgs[6])
+		require.NoError(t, err)
+		assert.Len(t, receipt.Promoted, 1)
+	})
+
+	t.Run("future messages do not count against the pool size", func(t *testing.T) {
+		pool, _ := newPool(1, 2)
+
+		MustAdd(pool, msgs[3], msgs[5], msgs[6])
+		_, err := pool.Add(ctx, msgs[7])
+		assert.Equal(t, errFutureQueueFull, errors.Cause(err))
+		assert.Equal(t, types.ValidationPoolFull, types.ValidationCodeOf(err))
+
+		largest, found := pool.LargestNonce(mockSigner.Addresses[0])
+		assert.True(t, found)
+		assert.Equal(t, uint64(6), largest)
+	})
+
+	t.Run("advancing the actor nonce promotes future messages", func(t *testing.T) {
+		pool, api := newPool(10, 10)
+
+		MustAdd(pool, msgs[5], msgs[6])
+		api.Actor.Nonce = 5
+		promoted, err := pool.PromoteReady(ctx, mockSigner.Addresses[0])
+		require.NoError(t, err)
+		assert.Len(t, promoted, 2)
+		assert.Equal(t, 0, pool.FutureCount())
+		assertPoolEquals(t, pool, msgs[5], msgs[6])
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		pool, _ := newPool(10, 0)
+
+		MustAdd(pool, msgs[5])
+		assert.Equal(t, 0, pool.FutureCount())
+		assertPoolEquals(t, pool, msgs[5])
+	})
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// GasBucket counts the pending messages whose gas price lies in the inclusive range
+// [Min, Max].
+type GasBucket struct {
+	Min      types.AttoFIL  `json:"min"`
+	Max      types.AttoFIL  `json:"max"`
+	Count    int            `json:"count"`
+	TotalGas types.GasUnits `json:"totalGas"`
+}
+
+// GasPriceHistogram divides the range from the lowest to the highest gas price of the
+// pending messages into the given number of equal width buckets, and returns the number of
+// messages and their total gas limit in each, ordered by price. It returns nil if the pool
+// is empty or buckets is not positive.
+func (pool *MessagePool) GasPriceHistogram(buckets int) []GasBucket {
+	if buckets <= 0 {
+		return nil
+	}
+
+	pool.lk.RLock()
+	msgs := make([]*types.SignedMessage, 0, len(pool.pending))
+	for _, msg := range pool.pending {
+		msgs = append(msgs, msg.message)
+	}
+	pool.lk.RUnlock()
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	min, max := msgs[0].GasPrice, msgs[0].GasPrice
+	for _, msg := range msgs[1:] {
+		if msg.GasPrice.LessThan(&min) {
+			min = msg.GasPrice
+		}
+		if msg.GasPrice.GreaterThan(&max) {
+			max = msg.GasPrice
+		}
+	}
+
+	// Widths are whole attoFIL, so the last bucket may extend past the highest price.
+	one := types.NewAttoFIL(big.NewInt(1))
+	span := max.Sub(&min).Add(one)
+	width := span.DivCeil(types.NewAttoFIL(big.NewInt(int64(buckets))))
+
+	out := make([]GasBucket, buckets)
+	for i := range out {
+		lower := min.Add(width.MulBigInt(big.NewInt(int64(i))))
+		out[i].Min = *lower
+		out[i].Max = *lower.Add(width).Sub(one)
+	}
+
+	for _, msg := range msgs {
+		i := sort.Search(buckets, func(i int) bool { return msg.GasPrice.LessEqual(&out[i].Max) })
+		out[i].Count++
+		out[i].TotalGas += msg.GasLimit
+	}
+	return out
+}
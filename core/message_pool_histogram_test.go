@@ -0,0 +1,52 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolGasPriceHistogram(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	assert.Nil(t, pool.GasPriceHistogram(3))
+
+	for i, price := range []int64{1, 2, 2, 5, 9, 10} {
+		msg := newSignedMessage().Message
+		msg.Nonce = types.Uint64(i)
+		smsg, err := types.NewSignedMessage(msg, mockSigner, types.NewGasPrice(price), types.NewGasUnits(100))
+		require.NoError(t, err)
+		MustAdd(pool, smsg)
+	}
+
+	buckets := pool.GasPriceHistogram(3)
+	require.Len(t, buckets, 3)
+
+	// Prices 1 to 10 span 10 attoFIL, so each bucket is 4 wide.
+	expected := []struct {
+		min, max int64
+		count    int
+	}{
+		{1, 4, 3},
+		{5, 8, 1},
+		{9, 12, 2},
+	}
+	for i, e := range expected {
+		assert.Equal(t, types.NewGasPrice(e.min), buckets[i].Min, "bucket %d", i)
+		assert.Equal(t, types.NewGasPrice(e.max), buckets[i].Max, "bucket %d", i)
+		assert.Equal(t, e.count, buckets[i].Count, "bucket %d", i)
+		assert.Equal(t, types.NewGasUnits(uint64(100*e.count)), buckets[i].TotalGas, "bucket %d", i)
+	}
+
+	assert.Nil(t, pool.GasPriceHistogram(0))
+	single := pool.GasPriceHistogram(1)
+	require.Len(t, single, 1)
+	assert.Equal(t, 6, single[0].Count)
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// InclusionRank returns the position of the message with CID c in the order a miner would
+// select pending messages: by decreasing gas price, or score if the pool has a ScoreFunc,
+// with each sender's messages in nonce order. A rank of 0 means no message is ahead of it.
+// total is the number of pending messages. ok is false if the message is not in the pool.
+func (pool *MessagePool) InclusionRank(c cid.Cid) (rank int, total int, ok bool) {
+	ordered, target, ok := pool.inclusionOrder(c)
+	if !ok {
+		return 0, 0, false
+	}
+	for i, msg := range ordered {
+		if msg == target {
+			return i, len(ordered), true
+		}
+	}
+	return 0, 0, false
+}
+
+// LikelyInNextBlock returns true if the message with CID c, together with all messages
+// ranked ahead of it, fits under the pool's configured block gas limit. ok is false if
+// the message is not in the pool.
+func (pool *MessagePool) LikelyInNextBlock(c cid.Cid) (likely bool, ok bool) {
+	ordered, target, ok := pool.inclusionOrder(c)
+	if !ok {
+		return false, false
+	}
+
+	var gas types.GasUnits
+	for _, msg := range ordered {
+		gas += msg.GasLimit
+		if msg == target {
+			return gas <= pool.cfg.BlockGasLimit, true
+		}
+	}
+	return false, false
+}
+
+// inclusionOrder returns the pending messages in mining order and the message with CID c.
+func (pool *MessagePool) inclusionOrder(c cid.Cid) ([]*types.SignedMessage, *types.SignedMessage, bool) {
+	pool.lk.RLock()
+	target, ok := pool.pending[c]
+	msgs := make([]*types.SignedMessage, 0, len(pool.pending))
+	for _, msg := range pool.pending {
+		msgs = append(msgs, msg.message)
+	}
+	pool.lk.RUnlock()
+	if !ok {
+		return nil, nil, false
+	}
+
+	queue := pool.selectionQueue(msgs, nil)
+	return queue.Drain(), target.message, true
+}
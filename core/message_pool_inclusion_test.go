@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolInclusionRank(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.BlockGasLimit = types.NewGasUnits(100)
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+	// One message from each of three senders, paying 1, 3 and 2 per unit of gas.
+	prices := []int64{1, 3, 2}
+	cids := make([]cid.Cid, len(prices))
+	for i, price := range prices {
+		msg := newSignedMessage().Message
+		msg.From = mockSigner.Addresses[i]
+		smsg, err := types.NewSignedMessage(msg, mockSigner, types.NewGasPrice(price), types.NewGasUnits(40))
+		require.NoError(t, err)
+		cids[i], err = pool.Add(ctx, smsg)
+		require.NoError(t, err)
+	}
+
+	t.Run("ranks by gas price", func(t *testing.T) {
+		for i, expected := range []int{2, 0, 1} {
+			rank, total, ok := pool.InclusionRank(cids[i])
+			require.True(t, ok)
+			assert.Equal(t, expected, rank)
+			assert.Equal(t, 3, total)
+		}
+	})
+
+	t.Run("predicts the next block against the gas limit", func(t *testing.T) {
+		for i, expected := range []bool{false, true, true} {
+			likely, ok := pool.LikelyInNextBlock(cids[i])
+			require.True(t, ok)
+			assert.Equal(t, expected, likely)
+		}
+	})
+
+	t.Run("unknown message", func(t *testing.T) {
+		c, err := newSignedMessage().Cid()
+		require.NoError(t, err)
+		_, _, ok := pool.InclusionRank(c)
+		assert.False(t, ok)
+		_, ok = pool.LikelyInNextBlock(c)
+		assert.False(t, ok)
+	})
+}
+
+func TestMessagePoolSelectForBlock(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	alice, bob, carol := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+
+	newMsg := func(from address.Address, nonce uint64, price int64) *types.SignedMessage {
+		msg := newSignedMessage().Message
+		msg.From = from
+		msg.Nonce = types.Uint64(nonce)
+		smsg, err := types.NewSignedMessage(msg, mockSigner, types.NewGasPrice(price), types.NewGasUnits(100))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	alice0 := newMsg(alice, 0, 2)
+	alice1 := newMsg(alice, 1, 5)
+	bob0 := newMsg(bob, 0, 3)
+	carol0 := newMsg(carol, 0, 1)
+	carol2 := newMsg(carol, 2, 10) // after a gap, so not ready
+	MustAdd(pool, alice0, alice1, bob0, carol0, carol2)
+
+	t.Run("selects the highest paying ready messages that fit", func(t *testing.T) {
+		selected := pool.SelectForBlock(types.NewGasUnits(200))
+		require.Len(t, selected, 2)
+		assert.True(t, bob0.Equals(selected[0]))
+		assert.True(t, alice0.Equals(selected[1]))
+	})
+
+	t.Run("preserves nonce order within a sender", func(t *testing.T) {
+		selected := pool.SelectForBlock(types.NewGasUnits(300))
+		require.Len(t, selected, 3)
+		assert.True(t, bob0.Equals(selected[0]))
+		assert.True(t, alice0.Equals(selected[1]))
+		assert.True(t, alice1.Equals(selected[2]))
+	})
+
+	t.Run("never selects past a nonce gap", func(t *testing.T) {
+		selected := pool.SelectForBlock(types.BlockGasLimit)
+		assert.Len(t, selected, 4)
+		for _, msg := range selected {
+			assert.False(t, carol2.Equals(msg))
+		}
+	})
+
+	t.Run("limits messages per sender to the quota", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.PerSenderBlockQuota = 2
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+		var aliceMsgs []*types.SignedMessage
+		for nonce := uint64(0); nonce < 5; nonce++ {
+			aliceMsgs = append(aliceMsgs, newMsg(alice, nonce, 10))
+		}
+		MustAdd(pool, aliceMsgs...)
+		MustAdd(pool, bob0)
+
+		selected := pool.SelectForBlock(types.BlockGasLimit)
+		require.Len(t, selected, 3)
+		assert.True(t, aliceMsgs[0].Equals(selected[0]))
+		assert.True(t, aliceMsgs[1].Equals(selected[1]))
+		assert.True(t, bob0.Equals(selected[2]))
+	})
+
+	t.Run("breaks gas price ties by seed", func(t *testing.T) {
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		for _, addr := range mockSigner.Addresses[:8] {
+			MustAdd(pool, newMsg(addr, 0, 7))
+		}
+
+		senders := func(msgs []*types.SignedMessage) []address.Address {
+			var out []address.Address
+			for _, msg := range msgs {
+				out = append(out, msg.From)
+			}
+			return out
+		}
+		first := senders(pool.SelectForBlockWithSeed(types.BlockGasLimit, []byte("ticket-1")))
+		second := senders(pool.SelectForBlockWithSeed(types.BlockGasLimit, []byte("ticket-2")))
+		require.Len(t, first, 8)
+		require.Len(t, second, 8)
+		assert.ElementsMatch(t, first, second)
+		assert.NotEqual(t, first, second)
+
+		assert.Equal(t, first, senders(pool.SelectForBlockWithSeed(types.BlockGasLimit, []byte("ticket-1"))))
+		assert.Equal(t, senders(pool.SelectForBlock(types.BlockGasLimit)), senders(pool.SelectForBlockWithSeed(types.BlockGasLimit, nil)))
+	})
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"github.com/ipfs/go-cid"
+)
+
+// RebuildIndexes rebuilds the pool's secondary indexes from its pending messages, which are
+// authoritative, and returns the number of messages reindexed. The indexes rebuilt are the
+// pending message for each sender and nonce, and the set of messages reserved for a block,
+// from which messages that are no longer pending are dropped. The pool's indexes are kept
+// up to date as messages come and go, so this is a way to recover from a bug that lets them
+// drift without restarting the node.
+func (pool *MessagePool) RebuildIndexes() int {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	addressNonces := make(map[addressNonce]cid.Cid, len(pool.pending))
+	for c, msg := range pool.pending {
+		addressNonces[newAddressNonce(msg.message)] = c
+	}
+	pool.addressNonces = addressNonces
+
+	for c := range pool.reserved {
+		if _, ok := pool.pending[c]; !ok {
+			delete(pool.reserved, c)
+		}
+	}
+	return len(pool.pending)
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolRebuildIndexes(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	msgs := types.NewSignedMsgs(3, mockSigner)
+	MustAdd(pool, msgs...)
+	expected := make(map[addressNonce]cid.Cid)
+	for an, c := range pool.addressNonces {
+		expected[an] = c
+	}
+
+	t.Log("corrupt the index by losing an entry and keeping a stale one")
+	sender := msgs[0].From
+	delete(pool.addressNonces, newAddressNonce(msgs[0]))
+	stale, err := mustSetNonce(mockSigner, newSignedMessage(), 7).Cid()
+	require.NoError(t, err)
+	pool.addressNonces[addressNonce{addr: sender, nonce: 7}] = stale
+	pool.reserved[stale] = struct{}{}
+
+	assert.Equal(t, 3, pool.RebuildIndexes())
+	actual := make(map[addressNonce]cid.Cid)
+	for an, c := range pool.addressNonces {
+		actual[an] = c
+	}
+	assert.Equal(t, expected, actual)
+	assert.Empty(t, pool.reserved)
+	assert.Equal(t, 3, pool.PendingCount())
+
+	largest, found := pool.LargestNonce(sender)
+	assert.True(t, found)
+	assert.Equal(t, uint64(msgs[2].Nonce), largest)
+
+	t.Log("a duplicate nonce is detected again")
+	duplicate := types.NewMessage(sender, msgs[0].To, uint64(msgs[0].Nonce), types.NewAttoFILFromFIL(1), "", nil)
+	smsg, err := types.NewSignedMessage(*duplicate, mockSigner, msgs[0].GasPrice, msgs[0].GasLimit)
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsg)
+	assert.Equal(t, types.ValidationDuplicateNonce, types.ValidationCodeOf(err))
+
+	t.Log("the stale nonce can be used")
+	_, err = pool.Add(ctx, mustSetNonce(mockSigner, newSignedMessage(), 7))
+	assert.NoError(t, err)
+}
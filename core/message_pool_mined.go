@@ -0,0 +1,42 @@
+package core
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrAlreadyMined is returned when a message has the sender and nonce of a message mined
+// in a recent block. The sender's actor may not reflect that block yet, so without this a
+// late re-broadcast of the mined message would be admitted again.
+var ErrAlreadyMined = errors.New("a message with this sender and nonce was recently mined")
+
+// recordMinedLocked remembers the sender and nonce of each message in blocks as mined at
+// the block's height. The caller must hold the write lock.
+func (pool *MessagePool) recordMinedLocked(blocks []*types.Block) {
+	for _, blk := range blocks {
+		for _, msg := range blk.Messages {
+			pool.mined[newAddressNonce(msg)] = uint64(blk.Height)
+		}
+	}
+}
+
+// forgetMinedLocked forgets the messages in blocks, which have been reverted, so that they
+// may be added again. The caller must hold the write lock.
+func (pool *MessagePool) forgetMinedLocked(blocks []*types.Block) {
+	for _, blk := range blocks {
+		for _, msg := range blk.Messages {
+			delete(pool.mined, newAddressNonce(msg))
+		}
+	}
+}
+
+// expireMinedLocked forgets messages mined below minimumHeight, by which time the sender's
+// actor nonce rejects them anyway. The caller must hold the write lock.
+func (pool *MessagePool) expireMinedLocked(minimumHeight uint64) {
+	for an, height := range pool.mined {
+		if height < minimumHeight {
+			delete(pool.mined, an)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolRejectsRecentlyMined(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	store := hamt.NewCborStore()
+	provider := &storeBlockProvider{store}
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	m := types.NewSignedMsgs(2, mockSigner)
+	MustAdd(pool, m[0], m[1])
+
+	base := NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}})
+	mined := NewChainWithMessages(store, base[0], msgsSet{msgs{m[0]}})
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(base), headOf(mined)))
+	assertPoolEquals(t, pool, m[1])
+
+	_, err := pool.Add(ctx, m[0])
+	assert.Equal(t, ErrAlreadyMined, errors.Cause(err))
+	assert.True(t, IsPermanent(err))
+	assertPoolEquals(t, pool, m[1])
+
+	t.Log("a reverted message may be added again")
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(mined), headOf(base)))
+	assertPoolEquals(t, pool, m[0], m[1])
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(base), headOf(mined)))
+	assertPoolEquals(t, pool, m[1])
+
+	t.Log("mined messages are forgotten after MessageTimeOut tip sets")
+	var empty [][][]*types.SignedMessage
+	for i := 0; i <= MessageTimeOut; i++ {
+		empty = append(empty, msgsSet{msgs{}})
+	}
+	later := NewChainWithMessages(store, headOf(mined), empty...)
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(mined), headOf(later)))
+	_, err = pool.Add(ctx, m[0])
+	assert.NoError(t, err)
+}
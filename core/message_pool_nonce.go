@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/address"
+)
+
+// NonceReservationTimeout is the number of blocks after which a nonce reserved by
+// AssignNonce is released if no message with that nonce has been added.
+const NonceReservationTimeout = 3
+
+// AssignNonce reserves and returns the next nonce for a message from addr. It is the
+// greater of the actor's nonce and one more than the largest nonce pending or reserved
+// for addr, so concurrent callers get distinct nonces even before their messages are
+// added. A reservation ends when a message with the nonce is added or after
+// NonceReservationTimeout blocks.
+func (pool *MessagePool) AssignNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	height, err := pool.getAPI().BlockHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	next, err := pool.actorNonce(ctx, addr)
+	if err != nil {
+		return 0, err
+	}
+
+	for an := range pool.addressNonces {
+		if an.addr == addr && an.nonce >= next {
+			next = an.nonce + 1
+		}
+	}
+	for an := range pool.future {
+		if an.addr == addr && an.nonce >= next {
+			next = an.nonce + 1
+		}
+	}
+
+	reserved := pool.reservations[addr]
+	for nonce, reservedAt := range reserved {
+		if height >= reservedAt+NonceReservationTimeout {
+			delete(reserved, nonce)
+			continue
+		}
+		if nonce >= next {
+			next = nonce + 1
+		}
+	}
+
+	if reserved == nil {
+		reserved = make(map[uint64]uint64)
+		pool.reservations[addr] = reserved
+	}
+	reserved[next] = height
+	return next, nil
+}
+
+// releaseReservationLocked ends the reservation of the nonce of a message that has
+// been added. The caller must hold the write lock.
+func (pool *MessagePool) releaseReservationLocked(an addressNonce) {
+	reserved, ok := pool.reservations[an.addr]
+	if !ok {
+		return
+	}
+	delete(reserved, an.nonce)
+	if len(reserved) == 0 {
+		delete(pool.reservations, an.addr)
+	}
+}
+
+// Reconcile removes pending messages whose nonce is below their sender's actor nonce in
+// the latest state, which have already been mined, and returns their CIDs. Messages
+// loaded from a PoolStore are checked against the state at startup; Reconcile should be
+// called once the node has caught up with the chain so the pool reflects it. Outside sync
+// mode it also revalidates the messages flagged while syncing (see SetSyncMode), removing
+// those that fail and clearing the flag on the rest; in sync mode it leaves them alone.
+func (pool *MessagePool) Reconcile(ctx context.Context) ([]cid.Cid, error) {
+	actorNonces := make(map[address.Address]uint64)
+	var stale []cid.Cid
+	for _, msg := range pool.messages(true) {
+		actorNonce, ok := actorNonces[msg.From]
+		if !ok {
+			var err error
+			if actorNonce, err = pool.actorNonce(ctx, msg.From); err != nil {
+				return nil, err
+			}
+			actorNonces[msg.From] = actorNonce
+		}
+		if uint64(msg.Nonce) >= actorNonce {
+			continue
+		}
+		c, err := msg.Cid()
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, c)
+	}
+
+	failed, passed := pool.revalidateFlagged(ctx)
+	stale = append(stale, failed...)
+
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	var dropped []cid.Cid
+	for _, c := range stale {
+		if msg, ok := pool.pending[c]; ok && !(msg.flagged && pool.syncMode) {
+			pool.removeLocked(c)
+			dropped = append(dropped, c)
+		}
+	}
+	for _, c := range passed {
+		if msg, ok := pool.pending[c]; ok {
+			msg.flagged = false
+		}
+	}
+	mpSize.Set(ctx, int64(len(pool.pending)))
+	return dropped, nil
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolAssignNonce(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	sender := mockSigner.Addresses[0]
+
+	t.Run("concurrent callers get consecutive distinct nonces", func(t *testing.T) {
+		api := th.NewTestMessagePoolAPI(0)
+		api.Actor.Nonce = 5
+		pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		var wg sync.WaitGroup
+		nonces := make([]uint64, 2)
+		for i := range nonces {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				nonce, err := pool.AssignNonce(ctx, sender)
+				assert.NoError(t, err)
+				nonces[i] = nonce
+			}(i)
+		}
+		wg.Wait()
+
+		assert.ElementsMatch(t, []uint64{5, 6}, nonces)
+	})
+
+	t.Run("follows pending messages", func(t *testing.T) {
+		api := th.NewTestMessagePoolAPI(0)
+		pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		MustAdd(pool, types.NewSignedMsgs(3, mockSigner)...)
+
+		nonce, err := pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), nonce)
+
+		// Another sender is unaffected.
+		nonce, err = pool.AssignNonce(ctx, mockSigner.Addresses[1])
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), nonce)
+	})
+
+	t.Run("reservations expire", func(t *testing.T) {
+		api := th.NewTestMessagePoolAPI(10)
+		pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		nonce, err := pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), nonce)
+
+		api.Height = 10 + NonceReservationTimeout - 1
+		nonce, err = pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), nonce)
+
+		// The first reservation has expired but the second has not.
+		api.Height = 10 + NonceReservationTimeout
+		nonce, err = pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), nonce)
+	})
+
+	t.Run("adding a message ends its reservation", func(t *testing.T) {
+		api := th.NewTestMessagePoolAPI(0)
+		pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		nonce, err := pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), nonce)
+
+		MustAdd(pool, types.NewSignedMsgs(1, mockSigner)...)
+		assert.Empty(t, pool.reservations)
+
+		nonce, err = pool.AssignNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), nonce)
+	})
+}
+
+func TestMessagePoolReconcile(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := th.NewTestMessagePoolAPI(0)
+	cfg := config.NewDefaultConfig().Mpool
+	store := newFakePoolStore()
+
+	msgs := types.NewSignedMsgs(3, mockSigner)
+	for _, msg := range msgs {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		require.NoError(t, store.Put(c, msg))
+	}
+
+	pool, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), store)
+	require.NoError(t, err)
+	assert.Len(t, pool.Pending(), 3)
+
+	t.Log("nothing is dropped while the actor nonce is behind the pool")
+	dropped, err := pool.Reconcile(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, dropped)
+
+	t.Log("messages the chain has caught up with are dropped")
+	api.Actor.Nonce = 2
+	dropped, err = pool.Reconcile(ctx)
+	require.NoError(t, err)
+	c0, err := msgs[0].Cid()
+	require.NoError(t, err)
+	c1, err := msgs[1].Cid()
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{c0, c1}, dropped)
+	assertPoolEquals(t, pool, msgs[2])
+	assert.Len(t, store.msgs, 1)
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// senderProgressBuffer is the number of head advances buffered for each subscriber to a
+// sender's progress. When a subscriber's buffer is full the oldest advance is dropped.
+const senderProgressBuffer = 16
+
+// senderProgress holds the subscribers to one sender's progress.
+type senderProgress struct {
+	subs map[chan uint64]struct{}
+	head uint64 // the last head sent to subscribers
+}
+
+// SubscribeSenderProgress returns a channel that receives the nonce of the next message
+// addr can have executed each time UpdateMessagePool sees one of its messages mined at a
+// higher nonce than before, and a function that cancels the subscription and closes the
+// channel. Sends do not block the pool: a subscriber that falls more than a few advances
+// behind misses the older ones, so the latest value received is always the most current.
+// Reorgs that revert addr's messages do not move its head back.
+func (pool *MessagePool) SubscribeSenderProgress(addr address.Address) (<-chan uint64, func()) {
+	ch := make(chan uint64, senderProgressBuffer)
+
+	pool.lk.Lock()
+	progress, ok := pool.senderProgress[addr]
+	if !ok {
+		progress = &senderProgress{subs: make(map[chan uint64]struct{})}
+		pool.senderProgress[addr] = progress
+	}
+	progress.subs[ch] = struct{}{}
+	pool.lk.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			pool.lk.Lock()
+			defer pool.lk.Unlock()
+			delete(progress.subs, ch)
+			if len(progress.subs) == 0 && pool.senderProgress[addr] == progress {
+				delete(pool.senderProgress, addr)
+			}
+			close(ch)
+		})
+	}
+	return ch, cancel
+}
+
+// notifySenderProgressLocked sends the new head of each subscribed sender whose messages
+// in blocks advance it. The caller must hold the write lock.
+func (pool *MessagePool) notifySenderProgressLocked(blocks []*types.Block) {
+	if len(pool.senderProgress) == 0 {
+		return
+	}
+
+	heads := make(map[address.Address]uint64)
+	for _, blk := range blocks {
+		for _, msg := range blk.Messages {
+			if _, ok := pool.senderProgress[msg.From]; !ok {
+				continue
+			}
+			if next := uint64(msg.Nonce) + 1; next > heads[msg.From] {
+				heads[msg.From] = next
+			}
+		}
+	}
+
+	for addr, head := range heads {
+		progress := pool.senderProgress[addr]
+		if head <= progress.head {
+			continue
+		}
+		progress.head = head
+		for ch := range progress.subs {
+			select {
+			case ch <- head:
+			default:
+				// Drop the oldest buffered head to make room for this one. The pool is the
+				// only sender and holds the lock, so the send cannot block.
+				log.Warningf("dropping progress of sender %s for a slow subscriber", addr)
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- head
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolSenderProgress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	store := hamt.NewCborStore()
+	provider := &storeBlockProvider{store}
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	m := types.NewSignedMsgs(3, mockSigner)
+	MustAdd(pool, m...)
+
+	progress, cancel := pool.SubscribeSenderProgress(m[0].From)
+	unrelated, cancelUnrelated := pool.SubscribeSenderProgress(mockSigner.Addresses[1])
+	defer cancelUnrelated()
+
+	base := NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}})
+	mined := NewChainWithMessages(store, base[0], msgsSet{msgs{m[0]}})
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(base), headOf(mined)))
+	assert.Equal(t, uint64(1), <-progress)
+	assert.Empty(t, unrelated)
+
+	t.Log("a block without the sender's messages does not advance it")
+	empty := NewChainWithMessages(store, headOf(mined), msgsSet{msgs{}})
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(mined), headOf(empty)))
+	assert.Empty(t, progress)
+
+	more := NewChainWithMessages(store, headOf(empty), msgsSet{msgs{m[1], m[2]}})
+	require.NoError(t, pool.UpdateMessagePool(ctx, provider, headOf(empty), headOf(more)))
+	assert.Equal(t, uint64(3), <-progress)
+
+	t.Log("cancelling closes the channel")
+	cancel()
+	cancel()
+	_, open := <-progress
+	assert.False(t, open)
+}
+
+func TestMessagePoolSenderProgressSlowSubscriber(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	store := hamt.NewCborStore()
+	provider := &storeBlockProvider{store}
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	n := senderProgressBuffer + 4
+	m := types.NewSignedMsgs(n, mockSigner)
+	progress, cancel := pool.SubscribeSenderProgress(m[0].From)
+	defer cancel()
+
+	head := headOf(NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}}))
+	for _, msg := range m {
+		next := headOf(NewChainWithMessages(store, head, msgsSet{msgs{msg}}))
+		require.NoError(t, pool.UpdateMessagePool(ctx, provider, head, next))
+		head = next
+	}
+
+	require.Len(t, progress, senderProgressBuffer)
+	var last uint64
+	for len(progress) > 0 {
+		last = <-progress
+	}
+	assert.Equal(t, uint64(n), last)
+}
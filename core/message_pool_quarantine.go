@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+)
+
+// RequeueFailureLimit is the number of times a message from a reverted block may fail
+// revalidation before it is quarantined.
+const RequeueFailureLimit = 2
+
+// QuarantineBlocks is the number of block heights for which a quarantined message is not
+// requeued. Failure counts for messages that are not seen again are forgotten after the
+// same interval.
+const QuarantineBlocks = 20
+
+type requeueFailure struct {
+	count  int
+	height uint64 // head height of the most recent failure
+}
+
+// QuarantinedCIDs returns the CIDs of messages that repeatedly failed revalidation when
+// their blocks were reverted and so are not currently requeued.
+func (pool *MessagePool) QuarantinedCIDs() []cid.Cid {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	out := make([]cid.Cid, 0, len(pool.quarantine))
+	for c := range pool.quarantine {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyString() < out[j].KeyString() })
+	return out
+}
+
+// requeueLocked adds a message from a reverted block back to the pool unless it is
+// quarantined, quarantining it once it has failed revalidation RequeueFailureLimit times.
+// The caller must hold the write lock.
+func (pool *MessagePool) requeueLocked(ctx context.Context, msg *timedmessage, headHeight uint64) {
+	c, err := msg.message.Cid()
+	if err != nil {
+		log.Info(err)
+		return
+	}
+	if _, quarantined := pool.quarantine[c]; quarantined {
+		return
+	}
+	msg.cid = c
+
+	_, err = pool.addTimedMessageLocked(ctx, msg)
+	if err == nil {
+		delete(pool.requeueFailures, c)
+		return
+	}
+	log.Info(err)
+
+	failure := pool.requeueFailures[c]
+	failure.count++
+	failure.height = headHeight
+	if failure.count < RequeueFailureLimit {
+		pool.requeueFailures[c] = failure
+		return
+	}
+	delete(pool.requeueFailures, c)
+	pool.quarantine[c] = headHeight + QuarantineBlocks
+}
+
+// releaseQuarantineLocked ends quarantines and forgets failures that are QuarantineBlocks
+// old as of headHeight. The caller must hold the write lock.
+func (pool *MessagePool) releaseQuarantineLocked(headHeight uint64) {
+	for c, until := range pool.quarantine {
+		if headHeight >= until {
+			delete(pool.quarantine, c)
+		}
+	}
+	for c, failure := range pool.requeueFailures {
+		if headHeight >= failure.height+QuarantineBlocks {
+			delete(pool.requeueFailures, c)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolQuarantine(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	store := hamt.NewCborStore()
+	validator := th.NewMockMessagePoolValidator()
+	p := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+
+	m := types.NewSignedMsgs(1, mockSigner)
+	c, err := m[0].Cid()
+	require.NoError(t, err)
+
+	parent := types.TipSet{}
+	blk := types.Block{Height: 0}
+	parent[blk.Cid()] = &blk
+
+	// A reorg that reverts the block containing m0, which keeps failing revalidation.
+	oldTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{{m[0]}}))
+	newTipSet := headOf(NewChainWithMessages(store, parent, [][]*types.SignedMessage{{}}))
+	validator.Valid = false
+
+	require.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+	assert.Empty(t, p.QuarantinedCIDs())
+
+	require.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+	assert.Equal(t, []cid.Cid{c}, p.QuarantinedCIDs())
+
+	t.Log("quarantined messages are not requeued, even once they would validate")
+	validator.Valid = true
+	require.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+	assertPoolEquals(t, p)
+	assert.Equal(t, []cid.Cid{c}, p.QuarantinedCIDs())
+
+	t.Log("the quarantine ends after QuarantineBlocks")
+	p.lk.Lock()
+	p.releaseQuarantineLocked(p.quarantine[c])
+	p.lk.Unlock()
+	require.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+	assertPoolEquals(t, p, m[0])
+	assert.Empty(t, p.QuarantinedCIDs())
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrMethodRateLimited is returned when too many messages invoking the same method have been
+// added to the pool recently.
+var ErrMethodRateLimited = errors.New("too many messages invoking method")
+
+// checkMethodRateLocked counts msg against the rate limit configured for its method in
+// MethodRateLimits, returning ErrMethodRateLimited if the limit is exceeded. The caller must
+// hold the write lock.
+func (pool *MessagePool) checkMethodRateLocked(msg *types.SignedMessage) error {
+	limit, ok := pool.cfg.MethodRateLimits[msg.Method]
+	if !ok {
+		return nil
+	}
+
+	limiter, ok := pool.methodLimits[msg.Method]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(limit.PerSecond), limit.Burst)
+		pool.methodLimits[msg.Method] = limiter
+	}
+	if !limiter.Allow() {
+		return types.NewValidationError(types.ValidationRateLimited, errors.Wrapf(ErrMethodRateLimited, "method %q", msg.Method))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolMethodRateLimits(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MethodRateLimits["noop"] = config.MethodRateLimit{PerSecond: 0.001, Burst: 3}
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+	nonce := uint64(0)
+	newMsg := func(method string) *types.SignedMessage {
+		msg := types.NewMessage(mockSigner.Addresses[0], address.TestAddress, nonce, types.ZeroAttoFIL, method, nil)
+		nonce++
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := pool.Add(ctx, newMsg("noop"))
+		require.NoError(t, err)
+	}
+	_, err := pool.Add(ctx, newMsg("noop"))
+	require.Error(t, err)
+	assert.Equal(t, ErrMethodRateLimited, errors.Cause(err))
+	assert.Equal(t, types.ValidationRateLimited, types.ValidationCodeOf(err))
+	assert.False(t, IsPermanent(err))
+
+	t.Log("other methods are not limited")
+	nonce--
+	for i := 0; i < 5; i++ {
+		_, err := pool.Add(ctx, newMsg("transfer"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 8, pool.PendingCount())
+
+	t.Log("AddMany counts against the limit too")
+	_, errs := pool.AddMany(ctx, []*types.SignedMessage{newMsg("noop")})
+	assert.Equal(t, ErrMethodRateLimited, errors.Cause(errs[0]))
+}
@@ -0,0 +1,14 @@
+package core
+
+// RejectionCounts returns the number of messages the pool has rejected since it was
+// created, keyed by the name of the types.ValidationCode of the rejection.
+func (pool *MessagePool) RejectionCounts() map[string]uint64 {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	out := make(map[string]uint64, len(pool.rejections))
+	for reason, count := range pool.rejections {
+		out[reason] = count
+	}
+	return out
+}
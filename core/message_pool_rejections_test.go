@@ -0,0 +1,44 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolRejectionCounts(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	mpoolCfg := config.NewDefaultConfig().Mpool
+	mpoolCfg.MaxPoolSize = 2
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, th.NewMockMessagePoolValidator())
+	assert.Empty(t, pool.RejectionCounts())
+
+	smsgs := types.NewSignedMsgs(3, mockSigner)
+	_, err := pool.Add(ctx, smsgs[0])
+	require.NoError(t, err)
+
+	sameNonce := mustResignMessage(mockSigner, smsgs[0], func(m *types.Message) {
+		m.Method = "other"
+	})
+	_, err = pool.Add(ctx, sameNonce)
+	require.Error(t, err)
+
+	_, err = pool.Add(ctx, smsgs[1])
+	require.NoError(t, err)
+	_, err = pool.Add(ctx, smsgs[2])
+	require.Error(t, err)
+
+	assert.Equal(t, map[string]uint64{
+		types.ValidationDuplicateNonce.String(): 1,
+		types.ValidationPoolFull.String():       1,
+	}, pool.RejectionCounts())
+}
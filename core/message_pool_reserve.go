@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ReserveForBlock marks the pending messages with the given CIDs as selected for a block
+// being produced. Reserved messages stay in the pool but are left out of Pending and so are
+// not selected again. Once the block is committed, Commit removes them; if producing it
+// fails, Unreserve returns them to Pending. CIDs of messages not in the pool are ignored.
+func (pool *MessagePool) ReserveForBlock(cids []cid.Cid) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	for _, c := range cids {
+		if _, ok := pool.pending[c]; ok {
+			pool.reserved[c] = struct{}{}
+		}
+	}
+}
+
+// Commit removes the messages with the given CIDs, which were reserved for a block that has
+// now been committed.
+func (pool *MessagePool) Commit(cids []cid.Cid) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	for _, c := range cids {
+		pool.removeLocked(c)
+	}
+	mpSize.Set(context.TODO(), int64(len(pool.pending)))
+}
+
+// Unreserve releases the messages with the given CIDs from their reservation, so they are
+// available to be selected again.
+func (pool *MessagePool) Unreserve(cids []cid.Cid) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+
+	for _, c := range cids {
+		delete(pool.reserved, c)
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolReserveForBlock(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	msg1 := mustSetGasPrice(mockSigner, newSignedMessage(), 1)
+	msg2 := mustSetGasPrice(mockSigner, mustSetNonce(mockSigner, newSignedMessage(), 1), 1)
+	c1, err := pool.Add(ctx, msg1)
+	require.NoError(t, err)
+	c2, err := pool.Add(ctx, msg2)
+	require.NoError(t, err)
+
+	pool.ReserveForBlock([]cid.Cid{c1})
+	assertPoolEquals(t, pool, msg2)
+	assert.NotContains(t, pool.SelectForBlock(types.BlockGasLimit), msg1)
+	_, ok := pool.Get(c1)
+	assert.True(t, ok)
+
+	t.Log("reservations survive other adds")
+	msg3 := mustSetGasPrice(mockSigner, mustSetNonce(mockSigner, newSignedMessage(), 2), 1)
+	MustAdd(pool, msg3)
+	assertPoolEquals(t, pool, msg2, msg3)
+	largest, found := pool.LargestNonce(msg1.From)
+	assert.True(t, found)
+	assert.Equal(t, uint64(2), largest)
+
+	pool.Unreserve([]cid.Cid{c1})
+	assertPoolEquals(t, pool, msg1, msg2, msg3)
+	assert.Len(t, pool.SelectForBlock(types.BlockGasLimit), 3)
+
+	t.Log("committing removes reserved messages")
+	pool.ReserveForBlock([]cid.Cid{c1, c2})
+	pool.Commit([]cid.Cid{c1, c2})
+	assertPoolEquals(t, pool, msg3)
+	_, ok = pool.Get(c1)
+	assert.False(t, ok)
+}
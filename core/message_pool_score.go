@@ -0,0 +1,73 @@
+package core
+
+import (
+	"math/big"
+
+	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ScoreFunc scores a pending message for selection into a block given the number of blocks
+// since it was added to the pool. Messages with higher scores are selected first, subject to
+// each sender's nonce order.
+type ScoreFunc func(msg *types.SignedMessage, ageBlocks uint64) float64
+
+// GasPriceScore scores messages by their gas price in FIL, regardless of age. The score is
+// rounded to a float64, so prices a few attoFIL apart may score the same, but since messages
+// with equal scores are ordered by exact gas price, ordering by it is the same as the pool's
+// default ordering by gas price.
+func GasPriceScore(msg *types.SignedMessage, ageBlocks uint64) float64 {
+	price, _ := new(big.Float).SetString(msg.GasPrice.String())
+	f, _ := price.Float64()
+	return f
+}
+
+// AgeBoostScore returns a ScoreFunc that raises a message's gas price score by the fraction
+// boostPerBlock of itself for each block the message has waited, so that cheap messages
+// are not starved forever by more expensive ones arriving after them.
+func AgeBoostScore(boostPerBlock float64) ScoreFunc {
+	return func(msg *types.SignedMessage, ageBlocks uint64) float64 {
+		return GasPriceScore(msg, ageBlocks) * (1 + boostPerBlock*float64(ageBlocks))
+	}
+}
+
+// SetScoreFunc sets the order in which SelectForBlock considers messages, which is also the
+// order InclusionRank and LikelyInNextBlock predict. A nil score, the default, orders by gas
+// price.
+func (pool *MessagePool) SetScoreFunc(score ScoreFunc) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.score = score
+}
+
+// selectionQueue returns a queue of msgs, which must be pending, in the order they are
+// considered for a block whose tiebreak seed is seed (see SelectForBlockWithSeed).
+func (pool *MessagePool) selectionQueue(msgs []*types.SignedMessage, seed []byte) mining.MessageQueue {
+	pool.lk.RLock()
+	score := pool.score
+	var addedAt map[*types.SignedMessage]uint64
+	if score != nil {
+		addedAt = make(map[*types.SignedMessage]uint64, len(pool.pending))
+		for _, msg := range pool.pending {
+			addedAt[msg.message] = msg.addedAt
+		}
+	}
+	pool.lk.RUnlock()
+	if score == nil {
+		return mining.NewSeededMessageQueue(msgs, nil, seed)
+	}
+
+	height, err := pool.getAPI().BlockHeight()
+	if err != nil {
+		log.Warningf("scoring messages without their ages: %s", err)
+	}
+	scores := make(map[*types.SignedMessage]float64, len(msgs))
+	for _, msg := range msgs {
+		var age uint64
+		if added := addedAt[msg]; err == nil && height > added {
+			age = height - added
+		}
+		scores[msg] = score(msg, age)
+	}
+	return mining.NewSeededMessageQueue(msgs, func(msg *types.SignedMessage) float64 { return scores[msg] }, seed)
+}
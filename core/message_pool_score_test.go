@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolScoreFunc(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	newMsg := func(from address.Address, gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(from, address.TestAddress, 0, types.ZeroAttoFIL, "", nil)
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	api := th.NewTestMessagePoolAPI(0)
+	pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	old := newMsg(mockSigner.Addresses[0], 10)
+	oldCid, err := pool.Add(ctx, old)
+	require.NoError(t, err)
+	api.Height = 20
+	recent := newMsg(mockSigner.Addresses[1], 11)
+	MustAdd(pool, recent)
+
+	t.Log("by default the more expensive message is selected first")
+	assert.Equal(t, []*types.SignedMessage{recent, old}, pool.SelectForBlock(types.BlockGasLimit))
+	rank, _, ok := pool.InclusionRank(oldCid)
+	require.True(t, ok)
+	assert.Equal(t, 1, rank)
+
+	t.Log("gas price scores keep that order")
+	pool.SetScoreFunc(GasPriceScore)
+	assert.Equal(t, []*types.SignedMessage{recent, old}, pool.SelectForBlock(types.BlockGasLimit))
+
+	t.Log("boosting by age lets the old cheap message go first")
+	pool.SetScoreFunc(AgeBoostScore(0.01))
+	assert.Equal(t, []*types.SignedMessage{old, recent}, pool.SelectForBlock(types.BlockGasLimit))
+	rank, _, ok = pool.InclusionRank(oldCid)
+	require.True(t, ok)
+	assert.Equal(t, 0, rank)
+
+	t.Log("a small boost is not enough")
+	pool.SetScoreFunc(AgeBoostScore(0.001))
+	assert.Equal(t, []*types.SignedMessage{recent, old}, pool.SelectForBlock(types.BlockGasLimit))
+}
+
+func TestMessagePoolGasPriceScorePrecision(t *testing.T) {
+	tf.UnitTest(t)
+
+	newMsg := func(from address.Address, gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(from, address.TestAddress, 0, types.ZeroAttoFIL, "", nil)
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	// Give the higher price to the sender that loses ties by address, so that only the
+	// exact price puts its message first.
+	first, second := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	if bytes.Compare(first.Bytes(), second.Bytes()) < 0 {
+		first, second = second, first
+	}
+	dear := newMsg(first, 1000000000000000001)
+	cheap := newMsg(second, 1000000000000000000)
+	require.Equal(t, GasPriceScore(cheap, 0), GasPriceScore(dear, 0))
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	MustAdd(pool, cheap, dear)
+	assert.Equal(t, []*types.SignedMessage{dear, cheap}, pool.SelectForBlock(types.BlockGasLimit))
+	pool.SetScoreFunc(GasPriceScore)
+	assert.Equal(t, []*types.SignedMessage{dear, cheap}, pool.SelectForBlock(types.BlockGasLimit))
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// SelectForBlock greedily selects pending messages for a block whose messages may use at
+// most gasLimit gas. Messages are considered in order of decreasing gas price, or score if
+// the pool has a ScoreFunc; a message is selected if its GasLimit fits in the remaining
+// budget. Since a sender's nonces cannot be skipped, only each sender's run of consecutive
+// nonces from its lowest pending nonce is considered, and once a sender's message does not
+// fit none of its later messages are selected. If the PerSenderBlockQuota config option is
+// set, at most that many messages are selected from any one sender. The result is in
+// execution order: each sender's messages by ascending nonce.
+func (pool *MessagePool) SelectForBlock(gasLimit types.GasUnits) []*types.SignedMessage {
+	return pool.SelectForBlockWithSeed(gasLimit, nil)
+}
+
+// SelectForBlockWithSeed selects messages like SelectForBlock, but orders senders whose next
+// messages have equal gas price (or score) by the hash of seed and the sender's address
+// rather than by address, so that no sender wins every tie across the network. Callers
+// should pass a value that changes from block to block, such as the block's ticket. The
+// selection is reproducible for a given seed; a nil seed behaves like SelectForBlock.
+func (pool *MessagePool) SelectForBlockWithSeed(gasLimit types.GasUnits, seed []byte) []*types.SignedMessage {
+	queue := pool.selectionQueue(readyMessages(pool.Pending()), seed)
+
+	var selected []*types.SignedMessage
+	blocked := make(map[address.Address]struct{})
+	counts := make(map[address.Address]int)
+	remaining := gasLimit
+	for msg, ok := queue.Pop(); ok; msg, ok = queue.Pop() {
+		if _, isBlocked := blocked[msg.From]; isBlocked {
+			continue
+		}
+		if msg.GasLimit > remaining {
+			blocked[msg.From] = struct{}{}
+			continue
+		}
+		remaining -= msg.GasLimit
+		selected = append(selected, msg)
+
+		counts[msg.From]++
+		if quota := pool.cfg.PerSenderBlockQuota; quota > 0 && counts[msg.From] >= quota {
+			blocked[msg.From] = struct{}{}
+		}
+	}
+	return selected
+}
+
+// readyMessages returns each sender's messages with consecutive nonces starting from its
+// lowest nonce in msgs.
+func readyMessages(msgs []*types.SignedMessage) []*types.SignedMessage {
+	bySender := make(map[address.Address][]*types.SignedMessage)
+	for _, msg := range msgs {
+		bySender[msg.From] = append(bySender[msg.From], msg)
+	}
+
+	var ready []*types.SignedMessage
+	for _, senderMsgs := range bySender {
+		sort.Slice(senderMsgs, func(i, j int) bool { return senderMsgs[i].Nonce < senderMsgs[j].Nonce })
+		ready = append(ready, senderMsgs[0])
+		for i := 1; i < len(senderMsgs) && senderMsgs[i].Nonce == senderMsgs[i-1].Nonce.Next(); i++ {
+			ready = append(ready, senderMsgs[i])
+		}
+	}
+	return ready
+}
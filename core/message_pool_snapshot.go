@@ -0,0 +1,57 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/ipfs/go-cid"
+)
+
+// PoolSnapshot records which messages were pending in a MessagePool at one moment.
+type PoolSnapshot struct {
+	cids map[cid.Cid]struct{}
+}
+
+// Snapshot returns the set of pending messages, including those reserved for a block.
+func (pool *MessagePool) Snapshot() *PoolSnapshot {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	cids := make(map[cid.Cid]struct{}, len(pool.pending))
+	for c := range pool.pending {
+		cids[c] = struct{}{}
+	}
+	return &PoolSnapshot{cids: cids}
+}
+
+// Len returns the number of messages in the snapshot.
+func (s *PoolSnapshot) Len() int {
+	return len(s.cids)
+}
+
+// Contains returns true if the message with CID c was pending when the snapshot was taken.
+func (s *PoolSnapshot) Contains(c cid.Cid) bool {
+	_, ok := s.cids[c]
+	return ok
+}
+
+// PoolDiff returns the CIDs of the messages pending in after but not before, and of those
+// pending in before but not after, each sorted.
+func PoolDiff(before, after *PoolSnapshot) (added, removed []cid.Cid) {
+	for c := range after.cids {
+		if !before.Contains(c) {
+			added = append(added, c)
+		}
+	}
+	for c := range before.cids {
+		if !after.Contains(c) {
+			removed = append(removed, c)
+		}
+	}
+	sortCids(added)
+	sortCids(removed)
+	return added, removed
+}
+
+func sortCids(cids []cid.Cid) {
+	sort.Slice(cids, func(i, j int) bool { return cids[i].KeyString() < cids[j].KeyString() })
+}
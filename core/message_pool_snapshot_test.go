@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestPoolDiff(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("reports an added message", func(t *testing.T) {
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		msgs := types.NewSignedMsgs(2, mockSigner)
+		MustAdd(pool, msgs[0])
+
+		before := pool.Snapshot()
+		c, err := pool.Add(ctx, msgs[1])
+		require.NoError(t, err)
+		after := pool.Snapshot()
+
+		added, removed := PoolDiff(before, after)
+		assert.Equal(t, []cid.Cid{c}, added)
+		assert.Empty(t, removed)
+		assert.Equal(t, 1, before.Len())
+		assert.Equal(t, 2, after.Len())
+		assert.True(t, after.Contains(c))
+		assert.False(t, before.Contains(c))
+	})
+
+	t.Run("reports messages mined by a new head", func(t *testing.T) {
+		store := hamt.NewCborStore()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+		msgs := types.NewSignedMsgs(3, mockSigner)
+		MustAdd(pool, msgs[0], msgs[1])
+
+		parent := types.TipSet{}
+		blk := types.Block{Height: 0}
+		parent[blk.Cid()] = &blk
+		oldChain := NewChainWithMessages(store, parent, [][]*types.SignedMessage{{msgs[2]}})
+		newChain := NewChainWithMessages(store, parent, [][]*types.SignedMessage{{msgs[1]}})
+
+		before := pool.Snapshot()
+		require.NoError(t, pool.UpdateMessagePool(ctx, &storeBlockProvider{store}, headOf(oldChain), headOf(newChain)))
+		added, removed := PoolDiff(before, pool.Snapshot())
+
+		c1, err := msgs[1].Cid()
+		require.NoError(t, err)
+		c2, err := msgs[2].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{c2}, added)
+		assert.Equal(t, []cid.Cid{c1}, removed)
+	})
+}
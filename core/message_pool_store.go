@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// PoolStore persists the pending messages of a MessagePool so they survive a restart.
+type PoolStore interface {
+	// Put stores msg under its CID c.
+	Put(c cid.Cid, msg *types.SignedMessage) error
+	// Delete removes the message stored under c. Deleting a missing message is not an error.
+	Delete(c cid.Cid) error
+	// LoadAll returns all stored messages.
+	LoadAll() ([]*types.SignedMessage, error)
+}
+
+// MessagePoolPrefix is the datastore prefix for pending messages.
+const MessagePoolPrefix = "mpool"
+
+// DatastorePoolStore is a PoolStore backed by a datastore.
+type DatastorePoolStore struct {
+	ds repo.Datastore
+}
+
+var _ PoolStore = (*DatastorePoolStore)(nil)
+
+// NewDatastorePoolStore returns a new DatastorePoolStore.
+func NewDatastorePoolStore(ds repo.Datastore) *DatastorePoolStore {
+	return &DatastorePoolStore{ds: ds}
+}
+
+// Put stores the message in the datastore.
+func (store *DatastorePoolStore) Put(c cid.Cid, msg *types.SignedMessage) error {
+	datum, err := msg.Marshal()
+	if err != nil {
+		return errors.Wrap(err, "could not marshal message")
+	}
+	if err := store.ds.Put(messagePoolKey(c), datum); err != nil {
+		return errors.Wrap(err, "could not save message to disk")
+	}
+	return nil
+}
+
+// Delete removes the message from the datastore.
+func (store *DatastorePoolStore) Delete(c cid.Cid) error {
+	if err := store.ds.Delete(messagePoolKey(c)); err != nil && err != datastore.ErrNotFound {
+		return errors.Wrap(err, "could not delete message from disk")
+	}
+	return nil
+}
+
+// LoadAll reads all messages from the datastore.
+func (store *DatastorePoolStore) LoadAll() ([]*types.SignedMessage, error) {
+	results, err := store.ds.Query(query.Query{Prefix: "/" + MessagePoolPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query messages from datastore")
+	}
+
+	var msgs []*types.SignedMessage
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, errors.Wrap(entry.Error, "failed to read messages from datastore")
+		}
+		var msg types.SignedMessage
+		if err := msg.Unmarshal(entry.Value); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal message from datastore")
+		}
+		msgs = append(msgs, &msg)
+	}
+	return msgs, nil
+}
+
+func messagePoolKey(c cid.Cid) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{MessagePoolPrefix, c.String()})
+}
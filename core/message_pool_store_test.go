@@ -0,0 +1,253 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+type fakePoolStore struct {
+	msgs map[cid.Cid]*types.SignedMessage
+	puts int
+}
+
+func newFakePoolStore() *fakePoolStore {
+	return &fakePoolStore{msgs: make(map[cid.Cid]*types.SignedMessage)}
+}
+
+func (s *fakePoolStore) Put(c cid.Cid, msg *types.SignedMessage) error {
+	s.msgs[c] = msg
+	s.puts++
+	return nil
+}
+
+func (s *fakePoolStore) Delete(c cid.Cid) error {
+	delete(s.msgs, c)
+	return nil
+}
+
+func (s *fakePoolStore) LoadAll() ([]*types.SignedMessage, error) {
+	var out []*types.SignedMessage
+	for _, msg := range s.msgs {
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// fundsValidator is a StagedValidator that accepts messages whose signatures verify and
+// whose senders, as returned by api, can pay for them.
+type fundsValidator struct {
+	api *th.TestMessagePoolAPI
+}
+
+func (v fundsValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	if err := v.ValidateStructure(msg); err != nil {
+		return err
+	}
+	if err := v.ValidateSignature(msg); err != nil {
+		return err
+	}
+	return v.ValidateState(ctx, msg)
+}
+
+func (fundsValidator) ValidateStructure(msg *types.SignedMessage) error {
+	return nil
+}
+
+func (fundsValidator) ValidateSignature(msg *types.SignedMessage) error {
+	if !msg.VerifySignature() {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (v fundsValidator) ValidateState(ctx context.Context, msg *types.SignedMessage) error {
+	act, err := v.api.ActorFromLatestState(ctx, msg.From)
+	if err != nil {
+		return err
+	}
+	if act.Balance.LessThan(msg.RequiredFunds()) {
+		return errors.New("insufficient funds")
+	}
+	return nil
+}
+
+func TestMessagePoolPersistence(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := th.NewTestMessagePoolAPI(0)
+	cfg := config.NewDefaultConfig().Mpool
+
+	t.Run("messages are reloaded from the store", func(t *testing.T) {
+		store := newFakePoolStore()
+		pool, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), store)
+		require.NoError(t, err)
+
+		msg1 := newSignedMessage()
+		msg2 := mustSetNonce(mockSigner, newSignedMessage(), 1)
+		c1, err := pool.Add(ctx, msg1)
+		require.NoError(t, err)
+		c2, err := pool.Add(ctx, msg2)
+		require.NoError(t, err)
+		assert.Len(t, store.msgs, 2)
+		assert.Equal(t, 2, store.puts)
+
+		reloaded, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), store)
+		require.NoError(t, err)
+		assert.Len(t, reloaded.Pending(), 2)
+		assert.Equal(t, 2, store.puts, "loading must not write messages back")
+		_, ok := reloaded.Get(c1)
+		assert.True(t, ok)
+		_, ok = reloaded.Get(c2)
+		assert.True(t, ok)
+
+		reloaded.Remove(c1)
+		assert.Len(t, store.msgs, 1)
+	})
+
+	t.Run("invalid stored messages are dropped", func(t *testing.T) {
+		store := newFakePoolStore()
+		msg := newSignedMessage()
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		require.NoError(t, store.Put(c, msg))
+
+		validator := th.NewMockMessagePoolValidator()
+		validator.Valid = false
+		pool, err := NewMessagePoolWithConfig(ctx, api, cfg, validator, store)
+		require.NoError(t, err)
+		assert.Len(t, pool.Pending(), 0)
+		assert.Len(t, store.msgs, 0)
+	})
+
+	t.Run("trusted loaded messages skip signature verification", func(t *testing.T) {
+		msg := newSignedMessage()
+		require.True(t, msg.VerifySignature())
+		tampered := *msg
+		tampered.Signature.Data = append([]byte{}, msg.Signature.Data...)
+		tampered.Signature.Data[0] ^= 0xff
+		require.False(t, tampered.VerifySignature())
+
+		store := newFakePoolStore()
+		c, err := tampered.Cid()
+		require.NoError(t, err)
+		require.NoError(t, store.Put(c, &tampered))
+
+		trusting := config.NewDefaultConfig().Mpool
+		trusting.TrustLoaded = true
+		pool, err := NewMessagePoolWithConfig(ctx, api, trusting, fundsValidator{api}, store)
+		require.NoError(t, err)
+		assertPoolEquals(t, pool, &tampered)
+
+		pool.Remove(c)
+		_, err = pool.Add(ctx, &tampered)
+		assert.Error(t, err)
+
+		t.Log("untrusted loads verify signatures")
+		require.NoError(t, store.Put(c, &tampered))
+		pool, err = NewMessagePoolWithConfig(ctx, api, cfg, fundsValidator{api}, store)
+		require.NoError(t, err)
+		assert.Empty(t, pool.Pending())
+
+		t.Log("trusted loads still check the sender can pay")
+		poor := mustResignMessage(mockSigner, newSignedMessage(), func(m *types.Message) {
+			m.From = mockSigner.Addresses[1]
+			m.Value = types.NewAttoFILFromFIL(5)
+		})
+		store = newFakePoolStore()
+		c, err = poor.Cid()
+		require.NoError(t, err)
+		require.NoError(t, store.Put(c, poor))
+		pool, err = NewMessagePoolWithConfig(ctx, api, trusting, fundsValidator{api}, store)
+		require.NoError(t, err)
+		assert.Empty(t, pool.Pending())
+		assert.Empty(t, store.msgs)
+	})
+
+	t.Run("nil store is in-memory", func(t *testing.T) {
+		pool, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), nil)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, newSignedMessage())
+		require.NoError(t, err)
+		assert.Len(t, pool.Pending(), 1)
+	})
+}
+
+func TestMessagePoolFlushInterval(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := th.NewTestMessagePoolAPI(0)
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.FlushInterval = "1h"
+
+	store := newFakePoolStore()
+	pool, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), store)
+	require.NoError(t, err)
+
+	msgs := types.NewSignedMsgs(3, mockSigner)
+	MustAdd(pool, msgs...)
+	assert.Empty(t, store.msgs)
+
+	require.NoError(t, pool.Flush(ctx))
+	assert.Len(t, store.msgs, 3)
+
+	c, err := msgs[2].Cid()
+	require.NoError(t, err)
+	pool.Remove(c)
+	assert.Len(t, store.msgs, 3)
+	require.NoError(t, pool.Close())
+	assert.Len(t, store.msgs, 2)
+
+	puts := store.puts
+	reloaded, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), store)
+	require.NoError(t, err)
+	assertPoolEquals(t, reloaded, msgs[0], msgs[1])
+	require.NoError(t, reloaded.Flush(ctx))
+	assert.Equal(t, puts, store.puts, "loading must not mark messages dirty")
+	require.NoError(t, reloaded.Close())
+
+	t.Run("invalid interval is rejected", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.FlushInterval = "often"
+		_, err := NewMessagePoolWithConfig(ctx, api, cfg, th.NewMockMessagePoolValidator(), newFakePoolStore())
+		assert.Error(t, err)
+	})
+}
+
+func TestDatastorePoolStore(t *testing.T) {
+	tf.UnitTest(t)
+
+	store := NewDatastorePoolStore(datastore.NewMapDatastore())
+	msg1 := newSignedMessage()
+	msg2 := mustSetNonce(mockSigner, newSignedMessage(), 1)
+	c1, err := msg1.Cid()
+	require.NoError(t, err)
+	c2, err := msg2.Cid()
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(c1, msg1))
+	require.NoError(t, store.Put(c2, msg2))
+
+	msgs, err := store.LoadAll()
+	require.NoError(t, err)
+	assert.Len(t, msgs, 2)
+
+	require.NoError(t, store.Delete(c1))
+	require.NoError(t, store.Delete(c1))
+	msgs, err = store.LoadAll()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.True(t, msg2.Equals(msgs[0]))
+}
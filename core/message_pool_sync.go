@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// SetSyncMode sets whether the node is syncing. While it is, the actor state the pool
+// checks messages against lags the chain, so messages whose nonce is below the actor's
+// nonce or whose sender cannot cover them are admitted and flagged rather than rejected.
+// Signature and structural checks are unchanged. Once sync completes, clear sync mode and
+// call Reconcile to revalidate the flagged messages.
+func (pool *MessagePool) SetSyncMode(syncing bool) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.syncMode = syncing
+}
+
+// FlaggedCIDs returns the CIDs of pending messages that were admitted in sync mode despite
+// failing a check against the actor's state, and have not yet been revalidated.
+func (pool *MessagePool) FlaggedCIDs() []cid.Cid {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	var out []cid.Cid
+	for c, msg := range pool.pending {
+		if msg.flagged {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyString() < out[j].KeyString() })
+	return out
+}
+
+// relaxedInSyncLocked returns true if the pool is in sync mode and err is a validation
+// failure caused by lagging actor state. The caller must hold the lock.
+func (pool *MessagePool) relaxedInSyncLocked(err error) bool {
+	if !pool.syncMode {
+		return false
+	}
+	code := types.ValidationCodeOf(err)
+	return code == types.ValidationNonceTooLow || code == types.ValidationInsufficientBalance
+}
+
+// revalidateFlagged runs the validator over the flagged pending messages, unless the pool
+// is still in sync mode. It returns the CIDs of those that fail and those that pass.
+func (pool *MessagePool) revalidateFlagged(ctx context.Context) (failed []cid.Cid, passed []cid.Cid) {
+	pool.lk.RLock()
+	flagged := make(map[cid.Cid]*types.SignedMessage)
+	if !pool.syncMode {
+		for c, msg := range pool.pending {
+			if msg.flagged {
+				flagged[c] = msg.message
+			}
+		}
+	}
+	pool.lk.RUnlock()
+
+	for c, msg := range flagged {
+		if err := pool.validator.Validate(ctx, msg); err != nil {
+			log.Infof("dropping message %s admitted during sync: %s", c, err)
+			failed = append(failed, c)
+		} else {
+			passed = append(passed, c)
+		}
+	}
+	return failed, passed
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// codeValidator fails every message with a ValidationError with code, unless code is
+// ValidationOther.
+type codeValidator struct {
+	code types.ValidationCode
+}
+
+func (v *codeValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	if v.code == types.ValidationOther {
+		return nil
+	}
+	return types.NewValidationError(v.code, errors.New(v.code.String()))
+}
+
+func TestMessagePoolSyncMode(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("low nonce messages are flagged and kept while syncing", func(t *testing.T) {
+		api := th.NewTestMessagePoolAPI(0)
+		api.Actor.Nonce = 5
+		pool := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		msg := mustSetNonce(mockSigner, newSignedMessage(), 2)
+		_, err := pool.Add(ctx, msg)
+		require.Error(t, err)
+		assert.Equal(t, types.ValidationNonceTooLow, types.ValidationCodeOf(err))
+
+		pool.SetSyncMode(true)
+		c, err := pool.Add(ctx, msg)
+		require.NoError(t, err)
+		assertPoolEquals(t, pool, msg)
+		assert.Equal(t, []cid.Cid{c}, pool.FlaggedCIDs())
+
+		t.Log("reconciling while syncing keeps flagged messages")
+		dropped, err := pool.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, dropped)
+		assertPoolEquals(t, pool, msg)
+
+		t.Log("once sync completes they are revalidated")
+		pool.SetSyncMode(false)
+		dropped, err = pool.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{c}, dropped)
+		assertPoolEquals(t, pool)
+		assert.Empty(t, pool.FlaggedCIDs())
+	})
+
+	t.Run("balance failures are flagged while syncing", func(t *testing.T) {
+		validator := &codeValidator{code: types.ValidationInsufficientBalance}
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+		pool.SetSyncMode(true)
+
+		msg := newSignedMessage()
+		c, err := pool.Add(ctx, msg)
+		require.NoError(t, err)
+		assert.Equal(t, []cid.Cid{c}, pool.FlaggedCIDs())
+
+		t.Log("messages that now validate are kept and unflagged")
+		pool.SetSyncMode(false)
+		validator.code = types.ValidationOther
+		dropped, err := pool.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, dropped)
+		assertPoolEquals(t, pool, msg)
+		assert.Empty(t, pool.FlaggedCIDs())
+	})
+
+	t.Run("signature failures are rejected while syncing", func(t *testing.T) {
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, &codeValidator{code: types.ValidationBadSignature})
+		pool.SetSyncMode(true)
+
+		_, err := pool.Add(ctx, newSignedMessage())
+		require.Error(t, err)
+		assert.Equal(t, types.ValidationBadSignature, types.ValidationCodeOf(err))
+		assertPoolEquals(t, pool)
+	})
+}
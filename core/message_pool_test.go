@@ -1,8 +1,10 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"testing"
 
@@ -12,8 +14,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -62,6 +66,29 @@ func TestMessagePoolAddRemove(t *testing.T) {
 	assert.Len(t, pool.Pending(), 0)
 }
 
+func TestMessagePoolGetMany(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	m := types.NewSignedMsgs(4, mockSigner)
+	MustAdd(pool, m[0], m[2])
+
+	cids := make([]cid.Cid, len(m))
+	for i, msg := range m {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		cids[i] = c
+	}
+
+	found, missing := pool.GetMany(cids)
+	assert.Equal(t, []*types.SignedMessage{m[0], m[2]}, found)
+	assert.Equal(t, []cid.Cid{cids[1], cids[3]}, missing)
+
+	found, missing = pool.GetMany(nil)
+	assert.Empty(t, found)
+	assert.Empty(t, missing)
+}
+
 func TestMessagePoolValidate(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -84,6 +111,7 @@ func TestMessagePoolValidate(t *testing.T) {
 		_, err := pool.Add(ctx, smsgs[maxMessagePoolSize])
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "message pool is full")
+		assert.Equal(t, types.ValidationPoolFull, types.ValidationCodeOf(err))
 
 		assert.Len(t, pool.Pending(), maxMessagePoolSize)
 	})
@@ -100,6 +128,98 @@ func TestMessagePoolValidate(t *testing.T) {
 		_, err = pool.Add(ctx, smsg2)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "message with same actor and nonce")
+		assert.Equal(t, types.ValidationDuplicateNonce, types.ValidationCodeOf(err))
+	})
+
+	t.Run("replaces message with same nonce and sufficiently higher gas price", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		smsg1 := mustSetGasPrice(mockSigner, newSignedMessage(), 100)
+		c1, err := pool.Add(ctx, smsg1)
+		require.NoError(t, err)
+
+		// a bump below ReplaceByFeePercent is rejected
+		smsg2 := mustSetGasPrice(mockSigner, smsg1, 105)
+		_, err = pool.Add(ctx, smsg2)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "message with same actor and nonce")
+
+		smsg3 := mustSetGasPrice(mockSigner, smsg1, 110)
+		receipt, err := pool.AddWithReceipt(ctx, smsg3)
+		require.NoError(t, err)
+		c3, err := smsg3.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, c3, receipt.Cid)
+		assert.Equal(t, c1, receipt.Replaced)
+		assert.False(t, receipt.Duplicate)
+
+		_, found := pool.Get(c1)
+		assert.False(t, found)
+		assertPoolEquals(t, pool, smsg3)
+	})
+
+	t.Run("replaces only the head nonce when configured", func(t *testing.T) {
+		ctx := context.Background()
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.ReplaceOnlyHead = true
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+		head := mustSetGasPrice(mockSigner, newSignedMessage(), 100)
+		next := mustSetGasPrice(mockSigner, mustSetNonce(mockSigner, newSignedMessage(), 1), 100)
+		MustAdd(pool, head, next)
+
+		_, err := pool.Add(ctx, mustSetGasPrice(mockSigner, next, 200))
+		require.Error(t, err)
+		assert.Equal(t, ErrCannotReplaceNonHead, errors.Cause(err))
+		assert.Equal(t, types.ValidationDuplicateNonce, types.ValidationCodeOf(err))
+
+		replacement := mustSetGasPrice(mockSigner, head, 200)
+		_, err = pool.Add(ctx, replacement)
+		require.NoError(t, err)
+		assertPoolEquals(t, pool, replacement, next)
+	})
+
+	t.Run("rejects messages below the minimum gas for their method", func(t *testing.T) {
+		ctx := context.Background()
+		cfg := config.NewDefaultConfig().Mpool
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+		withGas := func(nonce types.Uint64, limit uint64) *types.SignedMessage {
+			msg := newSignedMessage().Message
+			msg.Nonce = nonce
+			smsg, err := types.NewSignedMessage(msg, mockSigner, types.NewGasPrice(1), types.NewGasUnits(limit))
+			require.NoError(t, err)
+			return smsg
+		}
+		low, atMin := withGas(0, 99), withGas(1, 100)
+		cfg.MinGasForMethod[low.Method] = types.NewGasUnits(100)
+		cfg.MinGasForMethod[atMin.Method] = types.NewGasUnits(100)
+
+		_, err := pool.Add(ctx, low)
+		require.Error(t, err)
+		assert.Equal(t, ErrGasLimitBelowMethodMin, errors.Cause(err))
+		assert.Equal(t, types.ValidationGasBelowMethodMin, types.ValidationCodeOf(err))
+
+		_, err = pool.Add(ctx, atMin)
+		require.NoError(t, err)
+		_, err = pool.Add(ctx, withGas(2, 1))
+		require.NoError(t, err, "methods without a minimum are unconstrained")
+	})
+
+	t.Run("receipt reports duplicate add", func(t *testing.T) {
+		ctx := context.Background()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		smsg := newSignedMessage()
+		receipt, err := pool.AddWithReceipt(ctx, smsg)
+		require.NoError(t, err)
+		assert.False(t, receipt.Duplicate)
+		assert.False(t, receipt.Replaced.Defined())
+
+		receipt, err = pool.AddWithReceipt(ctx, smsg)
+		require.NoError(t, err)
+		assert.True(t, receipt.Duplicate)
 	})
 
 	t.Run("validates using supplied validator", func(t *testing.T) {
@@ -116,6 +236,250 @@ func TestMessagePoolValidate(t *testing.T) {
 	})
 }
 
+func TestMessagePoolSetAPI(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	m := types.NewSignedMsgs(4, mockSigner)
+	MustAdd(pool, m[0], m[1])
+
+	api := th.NewTestMessagePoolAPI(0)
+	api.Actor.Nonce = 3
+	pool.SetAPI(api)
+
+	// Pending messages are kept.
+	assertPoolEquals(t, pool, m[0], m[1])
+
+	// A nonce that was valid against the old source is now too low.
+	_, err := pool.Add(ctx, m[2])
+	require.Error(t, err)
+	assert.Equal(t, types.ValidationNonceTooLow, types.ValidationCodeOf(err))
+
+	_, err = pool.Add(ctx, m[3])
+	require.NoError(t, err)
+}
+
+func TestMessagePoolBumpGasPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	smsg := mustSetGasPrice(mockSigner, newSignedMessage(), 100)
+	c, err := pool.Add(ctx, smsg)
+	require.NoError(t, err)
+
+	t.Run("rejects a bump below the replace-by-fee threshold", func(t *testing.T) {
+		_, err := pool.BumpGasPrice(ctx, c, types.NewGasPrice(105), mockSigner)
+		assert.Equal(t, errFeeBumpTooLow, err)
+		_, found := pool.Get(c)
+		assert.True(t, found)
+	})
+
+	t.Run("rejects a message that is not pending", func(t *testing.T) {
+		other, err := newSignedMessage().Cid()
+		require.NoError(t, err)
+		_, err = pool.BumpGasPrice(ctx, other, types.NewGasPrice(200), mockSigner)
+		assert.Equal(t, errNotPending, err)
+	})
+
+	t.Run("replaces the message", func(t *testing.T) {
+		bumped, err := pool.BumpGasPrice(ctx, c, types.NewGasPrice(110), mockSigner)
+		require.NoError(t, err)
+		assert.NotEqual(t, c, bumped)
+
+		_, found := pool.Get(c)
+		assert.False(t, found)
+		msg, found := pool.Get(bumped)
+		require.True(t, found)
+		price := types.NewGasPrice(110)
+		assert.True(t, msg.GasPrice.Equal(&price))
+		assert.Equal(t, smsg.Nonce, msg.Nonce)
+		assert.Len(t, pool.Pending(), 1)
+	})
+}
+
+func TestMessagePoolAddMany(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	alice, bob, carol := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+
+	api := th.NewTestMessagePoolAPI(0)
+	api.SetActor(alice, th.NewActorState().WithBalance(types.NewAttoFILFromFIL(10)).Build())
+	api.SetActor(bob, th.NewActorState().WithNonce(3).WithBalance(types.NewAttoFILFromFIL(1)).Build())
+	api.SetActor(carol, th.NewActorState().WithBalance(types.NewAttoFILFromFIL(10)).AsMiner().Build())
+	cfg := config.NewDefaultConfig().Mpool
+	pool := NewMessagePool(api, cfg, consensus.NewIngestionValidator(api, cfg))
+
+	newMsg := func(from address.Address, nonce uint64, value *types.AttoFIL) *types.SignedMessage {
+		msg := types.NewMessage(from, address.TestAddress, nonce, value, "", nil)
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+	msgs := []*types.SignedMessage{
+		newMsg(alice, 0, types.NewAttoFILFromFIL(5)),
+		newMsg(bob, 0, types.NewAttoFILFromFIL(1)),
+		newMsg(bob, 3, types.NewAttoFILFromFIL(5)),
+		newMsg(bob, 4, types.NewAttoFILFromFIL(1)),
+		newMsg(carol, 0, types.NewAttoFILFromFIL(1)),
+	}
+
+	cids, errs := pool.AddMany(ctx, msgs)
+	require.Len(t, cids, 5)
+	require.Len(t, errs, 5)
+
+	assert.NoError(t, errs[0])
+	assert.Equal(t, types.ValidationNonceTooLow, types.ValidationCodeOf(errs[1]))
+	assert.Equal(t, types.ValidationInsufficientBalance, types.ValidationCodeOf(errs[2]))
+	assert.NoError(t, errs[3])
+	assert.Equal(t, types.ValidationNonAccountActor, types.ValidationCodeOf(errs[4]))
+	assert.False(t, cids[1].Defined())
+	assert.False(t, cids[2].Defined())
+
+	assertPoolEquals(t, pool, msgs[0], msgs[3])
+	for _, i := range []int{0, 3} {
+		c, err := msgs[i].Cid()
+		require.NoError(t, err)
+		assert.Equal(t, c, cids[i])
+	}
+}
+
+// cancelingValidator accepts every message, cancelling a context after the first.
+type cancelingValidator struct {
+	cancel context.CancelFunc
+}
+
+func (v *cancelingValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	v.cancel()
+	return nil
+}
+
+func TestMessagePoolAddManyCanceled(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, &cancelingValidator{cancel: cancel})
+
+	msgs := types.NewSignedMsgs(3, mockSigner)
+	cids, errs := pool.AddMany(ctx, msgs)
+	require.Len(t, cids, 3)
+	require.Len(t, errs, 3)
+
+	require.NoError(t, errs[0])
+	c, err := msgs[0].Cid()
+	require.NoError(t, err)
+	assert.Equal(t, c, cids[0])
+	for i := 1; i < 3; i++ {
+		assert.Equal(t, context.Canceled, errs[i])
+		assert.False(t, cids[i].Defined())
+	}
+	assertPoolEquals(t, pool, msgs[0])
+}
+
+func TestMessagePoolSenders(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	assert.Empty(t, pool.Senders())
+
+	senders := mockSigner.Addresses[:3]
+	for i, from := range senders {
+		msg := mustResignMessage(mockSigner, newSignedMessage(), func(m *types.Message) {
+			m.From = from
+		})
+		_, err := pool.Add(ctx, msg)
+		require.NoError(t, err)
+		if i == 0 {
+			_, err := pool.Add(ctx, mustSetNonce(mockSigner, msg, 1))
+			require.NoError(t, err)
+		}
+	}
+
+	expected := append([]address.Address{}, senders...)
+	sort.Slice(expected, func(i, j int) bool { return bytes.Compare(expected[i].Bytes(), expected[j].Bytes()) < 0 })
+	assert.Equal(t, expected, pool.Senders())
+}
+
+func TestMessagePoolPendingSortedByCID(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	assert.Empty(t, pool.PendingSortedByCID())
+
+	msgs := types.NewSignedMsgs(6, mockSigner)
+	MustAdd(pool, msgs...)
+
+	sorted := pool.PendingSortedByCID()
+	require.Len(t, sorted, len(msgs))
+	for i := 1; i < len(sorted); i++ {
+		prev, err := sorted[i-1].Cid()
+		require.NoError(t, err)
+		cur, err := sorted[i].Cid()
+		require.NoError(t, err)
+		assert.True(t, bytes.Compare(prev.Bytes(), cur.Bytes()) < 0)
+	}
+	assert.Equal(t, sorted, pool.PendingSortedByCID())
+}
+
+func TestMessagePoolPendingCount(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	assert.Equal(t, 0, pool.PendingCount())
+
+	msgs := types.NewSignedMsgs(5, mockSigner)
+	MustAdd(pool, msgs...)
+	assert.Equal(t, 5, pool.PendingCount())
+	assert.Equal(t, len(pool.Pending()), pool.PendingCount())
+
+	c0, err := msgs[0].Cid()
+	require.NoError(t, err)
+	c1, err := msgs[1].Cid()
+	require.NoError(t, err)
+	pool.Remove(c0)
+	assert.Equal(t, 4, pool.PendingCount())
+	assert.Equal(t, len(pool.Pending()), pool.PendingCount())
+
+	t.Log("reserved messages are not counted")
+	pool.ReserveForBlock([]cid.Cid{c1})
+	assert.Equal(t, 3, pool.PendingCount())
+	assert.Equal(t, len(pool.Pending()), pool.PendingCount())
+	pool.Unreserve([]cid.Cid{c1})
+	assert.Equal(t, 4, pool.PendingCount())
+}
+
+func TestMessagePoolStream(t *testing.T) {
+	tf.UnitTest(t)
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	msgs := types.NewSignedMsgs(5, mockSigner)
+	MustAdd(pool, msgs...)
+
+	var streamed []*types.SignedMessage
+	for msg := range pool.Stream(context.Background()) {
+		streamed = append(streamed, msg)
+	}
+	assert.Equal(t, pool.Pending(), streamed)
+
+	t.Log("cancelling the context stops the stream")
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := pool.Stream(ctx)
+	<-stream
+	cancel()
+	received := 1
+	for range stream {
+		received++
+	}
+	// A send may already have been waiting when the context was cancelled.
+	assert.True(t, received <= 2)
+}
+
 func TestMessagePoolDedup(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -162,6 +526,96 @@ func TestMessagePoolAsync(t *testing.T) {
 	assert.Len(t, pool.Pending(), count)
 }
 
+func TestUpdateMessagePoolAtomicWithAdd(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	count := 400
+	mpoolCfg := config.NewDefaultConfig().Mpool
+	mpoolCfg.MaxPoolSize = count
+	m := types.NewSignedMsgs(count, mockSigner)
+
+	// Pool: [m0..m99], Chain: b[m100..m149]
+	// to
+	// Pool: [m0..m49, m100..m399], Chain: b[m50..m99]
+	// while m150..m399 are added concurrently.
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), mpoolCfg, th.NewMockMessagePoolValidator())
+	MustAdd(pool, m[:100]...)
+
+	store := hamt.NewCborStore()
+	parent := types.TipSet{}
+	blk := types.Block{Height: 0}
+	parent[blk.Cid()] = &blk
+	oldHead := headOf(NewChainWithMessages(store, parent, msgsSet{m[100:150]}))
+	newHead := headOf(NewChainWithMessages(store, parent, msgsSet{m[50:100]}))
+
+	countIn := func(pending map[cid.Cid]bool, set msgs) int {
+		n := 0
+		for _, msg := range set {
+			c, err := msg.Cid()
+			assert.NoError(t, err)
+			if pending[c] {
+				n++
+			}
+		}
+		return n
+	}
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			pending := make(map[cid.Cid]bool)
+			for _, msg := range pool.Pending() {
+				c, err := msg.Cid()
+				assert.NoError(t, err)
+				pending[c] = true
+			}
+			// Either the whole update is visible or none of it is.
+			mined := countIn(pending, m[50:100])
+			reverted := countIn(pending, m[100:150])
+			updated := mined == 0 && reverted == 50
+			notUpdated := mined == 50 && reverted == 0
+			assert.True(t, updated || notUpdated, "observed partial update: %d mined, %d reverted pending", mined, reverted)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, msg := range m[150+50*i : 150+50*(i+1)] {
+				_, err := pool.Add(ctx, msg)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, pool.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldHead, newHead))
+	}()
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+
+	expected := append(msgs{}, m[:50]...)
+	expected = append(expected, m[100:]...)
+	assertPoolEquals(t, pool, expected...)
+}
+
 func msgAsString(msg *types.SignedMessage) string {
 	// When using NewMessageForTestGetter msg.Method is set
 	// to "msgN" so we print that (it will correspond
@@ -181,20 +635,16 @@ func msgsAsString(msgs []*types.SignedMessage) string {
 // assertPoolEquals returns true if p contains exactly the expected messages.
 func assertPoolEquals(t *testing.T, p *MessagePool, expMsgs ...*types.SignedMessage) {
 	msgs := p.Pending()
+	expMsgs = append([]*types.SignedMessage{}, expMsgs...)
+	types.SortSignedMessages(expMsgs)
 	if len(msgs) != len(expMsgs) {
 		assert.Failf(t, "wrong messages in pool", "expMsgs %v, got msgs %v", msgsAsString(expMsgs), msgsAsString(msgs))
-
+		return
 	}
-	for _, m1 := range expMsgs {
-		found := false
-		for _, m2 := range msgs {
-			if types.SmsgCidsEqual(m1, m2) {
-				found = true
-				break
-			}
-		}
-		if !found {
+	for i, m1 := range expMsgs {
+		if !types.SmsgCidsEqual(m1, msgs[i]) {
 			assert.Failf(t, "wrong messages in pool", "expMsgs %v, got msgs %v (msgs doesn't contain %v)", msgsAsString(expMsgs), msgsAsString(msgs), msgAsString(m1))
+			return
 		}
 	}
 }
@@ -554,6 +1004,95 @@ func TestUpdateMessagePool(t *testing.T) {
 		assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, head, next))
 		assertPoolEquals(t, p, m[1:]...)
 	})
+
+	t.Run("Times out messages with a ttl after ttl blocks", func(t *testing.T) {
+		store := hamt.NewCborStore()
+		p := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		m := types.NewSignedMsgs(2, mockSigner)
+		_, err := p.AddWithTTL(ctx, m[0], 2)
+		require.NoError(t, err)
+		MustAdd(p, m[1])
+
+		chain := NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}}, msgsSet{msgs{}}, msgsSet{msgs{}})
+
+		assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, chain[0], chain[1]))
+		assertPoolEquals(t, p, m[0], m[1])
+
+		assert.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, chain[1], chain[2]))
+		assertPoolEquals(t, p, m[1])
+	})
+}
+
+func TestUpdateMessagePoolMaxReorgDepth(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MaxReorgDepth = 3
+
+	store := hamt.NewCborStore()
+	m := types.NewSignedMsgs(2, mockSigner)
+	parent := types.TipSet{}
+	blk := types.Block{Height: 0}
+	parent[blk.Cid()] = &blk
+	oldTipSet := headOf(NewChainWithMessages(store, parent, msgsSet{msgs{m[0]}}))
+
+	t.Run("a reorg deeper than the limit is rejected", func(t *testing.T) {
+		p := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+		MustAdd(p, m[1])
+
+		var sets [][][]*types.SignedMessage
+		for i := 0; i < 10; i++ {
+			sets = append(sets, msgsSet{msgs{}})
+		}
+		newTipSet := headOf(NewChainWithMessages(store, parent, sets...))
+
+		err := p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet)
+		assert.Equal(t, ErrReorgTooDeep, errors.Cause(err))
+		assertPoolEquals(t, p, m[1])
+	})
+
+	t.Run("a reorg within the limit succeeds", func(t *testing.T) {
+		p := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+		MustAdd(p, m[1])
+
+		newTipSet := headOf(NewChainWithMessages(store, parent, msgsSet{msgs{}}, msgsSet{msgs{m[1]}}))
+		require.NoError(t, p.UpdateMessagePool(ctx, &storeBlockProvider{store}, oldTipSet, newTipSet))
+		assertPoolEquals(t, p, m[0])
+	})
+}
+
+func TestMessagePoolSweepExpired(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	store := hamt.NewCborStore()
+	p := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+	m := types.NewSignedMsgs(2, mockSigner)
+	_, err := p.AddWithTTL(ctx, m[0], 2)
+	require.NoError(t, err)
+	MustAdd(p, m[1])
+
+	chain := NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}}, msgsSet{msgs{}}, msgsSet{msgs{}})
+
+	removed, err := p.SweepExpired(ctx, &storeBlockProvider{store}, chain[1])
+	require.NoError(t, err)
+	assert.Len(t, removed, 0)
+
+	removed, err = p.SweepExpired(ctx, &storeBlockProvider{store}, chain[2])
+	require.NoError(t, err)
+	c0, err := m[0].Cid()
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{c0}, removed)
+	assertPoolEquals(t, p, m[1])
 }
 
 func TestLargestNonce(t *testing.T) {
@@ -622,6 +1161,14 @@ func mustSetNonce(signer types.Signer, message *types.SignedMessage, nonce types
 	})
 }
 
+func mustSetGasPrice(signer types.Signer, message *types.SignedMessage, price int64) *types.SignedMessage {
+	smsg, err := types.NewSignedMessage(message.Message, signer, types.NewGasPrice(price), message.GasLimit)
+	if err != nil {
+		panic("Error signing message")
+	}
+	return smsg
+}
+
 func mustResignMessage(signer types.Signer, message *types.SignedMessage, f func(*types.Message)) *types.SignedMessage {
 	var msg types.Message
 	msg = message.Message
@@ -636,3 +1183,92 @@ func mustResignMessage(signer types.Signer, message *types.SignedMessage, f func
 func signMessage(signer types.Signer, message types.Message) (*types.SignedMessage, error) {
 	return types.NewSignedMessage(message, signer, types.NewGasPrice(0), types.NewGasUnits(0))
 }
+
+// countingPoolAPI counts actor lookups.
+type countingPoolAPI struct {
+	*th.TestMessagePoolAPI
+	lookups int
+}
+
+func (api *countingPoolAPI) ActorFromLatestState(ctx context.Context, addr address.Address) (*actor.Actor, error) {
+	api.lookups++
+	return api.TestMessagePoolAPI.ActorFromLatestState(ctx, addr)
+}
+
+func TestMessagePoolStructuralChecksFirst(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	alice := mockSigner.Addresses[0]
+	api := &countingPoolAPI{TestMessagePoolAPI: th.NewTestMessagePoolAPI(0)}
+	api.SetActor(alice, th.NewActorState().WithBalance(types.NewAttoFILFromFIL(10)).Build())
+	cfg := config.NewDefaultConfig().Mpool
+	var validator StagedValidator = consensus.NewIngestionValidator(api, cfg)
+	pool := NewMessagePool(api, cfg, validator)
+
+	newMsg := func(to address.Address, value *types.AttoFIL, gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(alice, to, 0, value, "", nil)
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	negative, ok := types.NewAttoFILFromString("-5", 10)
+	require.True(t, ok)
+
+	testCases := []struct {
+		name string
+		msg  *types.SignedMessage
+		code types.ValidationCode
+	}{
+		{"self send", newMsg(alice, types.NewAttoFILFromFIL(1), 1), types.ValidationSelfSend},
+		{"negative value", newMsg(address.TestAddress, negative, 1), types.ValidationNegativeValue},
+		{"gas price zero", newMsg(address.TestAddress, types.NewAttoFILFromFIL(1), 0), types.ValidationGasPriceZero},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api.lookups = 0
+			_, err := pool.Add(ctx, tc.msg)
+			require.Error(t, err)
+			assert.Equal(t, tc.code, types.ValidationCodeOf(err))
+			assert.Equal(t, 0, api.lookups)
+		})
+	}
+
+	t.Run("valid messages look up the actor", func(t *testing.T) {
+		api.lookups = 0
+		_, err := pool.Add(ctx, newMsg(address.TestAddress, types.NewAttoFILFromFIL(1), 1))
+		require.NoError(t, err)
+		assert.NotZero(t, api.lookups)
+	})
+}
+
+func TestMessagePoolMaxParamsSize(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	api := &countingPoolAPI{TestMessagePoolAPI: th.NewTestMessagePoolAPI(0)}
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MaxParamsSize = 16
+	pool := NewMessagePool(api, cfg, th.NewMockMessagePoolValidator())
+
+	newMsg := func(nonce uint64, params []byte) *types.SignedMessage {
+		msg := types.NewMessage(mockSigner.Addresses[0], address.TestAddress, nonce, types.ZeroAttoFIL, "method", params)
+		smsg, err := types.NewSignedMessage(*msg, mockSigner, types.NewGasPrice(1), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	_, err := pool.Add(ctx, newMsg(0, make([]byte, 17)))
+	assert.Equal(t, ErrParamsTooLarge, errors.Cause(err))
+	assert.Equal(t, types.ValidationParamsTooLarge, types.ValidationCodeOf(err))
+	assert.Equal(t, 0, api.lookups)
+
+	_, err = pool.Add(ctx, newMsg(0, make([]byte, 16)))
+	assert.NoError(t, err)
+
+	t.Log("zero means no limit")
+	unlimited := NewMessagePool(api, config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	_, err = unlimited.Add(ctx, newMsg(0, make([]byte, 1024)))
+	assert.NoError(t, err)
+}
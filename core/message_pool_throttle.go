@@ -0,0 +1,71 @@
+package core
+
+import (
+	"time"
+)
+
+// ThrottleRejectionRate is the fraction of recent attempts to add a message that must have
+// been rejected for ShouldThrottle to ask for fewer messages.
+const ThrottleRejectionRate = 0.5
+
+// ThrottleBackoff is how long SuggestedBackoff asks callers to wait while the pool is
+// throttling. It is doubled when the pool is full.
+const ThrottleBackoff = time.Second
+
+// ThrottleWindow is how long an attempt to add a message counts towards the rejection rate,
+// so that the pool stops throttling once rejections stop arriving, even if nothing new is
+// offered to it.
+const ThrottleWindow = time.Minute
+
+// throttleMinAdmissions is the number of recent add attempts the pool must remember before
+// their rejection rate is considered, so that a few early rejections do not throttle.
+const throttleMinAdmissions = 16
+
+// ShouldThrottle returns true if whoever is feeding the pool, such as the gossip layer, should
+// slow down: either the number of pending messages has reached the configured HighWaterMark
+// fraction of MaxPoolSize, or at least ThrottleRejectionRate of the attempts to add a message
+// in the last ThrottleWindow, as recorded for RecentAdmissions, were rejected.
+func (pool *MessagePool) ShouldThrottle() bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+	return pool.shouldThrottleLocked()
+}
+
+// SuggestedBackoff returns how long whoever is feeding the pool should wait before offering it
+// more messages: zero if the pool is not throttling, ThrottleBackoff if it is, and twice that
+// if the pool is full.
+func (pool *MessagePool) SuggestedBackoff() time.Duration {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	if !pool.shouldThrottleLocked() {
+		return 0
+	}
+	if len(pool.pending) >= pool.cfg.MaxPoolSize {
+		return 2 * ThrottleBackoff
+	}
+	return ThrottleBackoff
+}
+
+// shouldThrottleLocked implements ShouldThrottle. The caller must hold the lock.
+func (pool *MessagePool) shouldThrottleLocked() bool {
+	if pool.cfg.HighWaterMark > 0 && float64(len(pool.pending)) >= pool.cfg.HighWaterMark*float64(pool.cfg.MaxPoolSize) {
+		return true
+	}
+
+	cutoff := time.Now().Add(-ThrottleWindow)
+	recent, rejected := 0, 0
+	for _, record := range pool.admissions {
+		if record.Time.Before(cutoff) {
+			continue
+		}
+		recent++
+		if !record.Accepted {
+			rejected++
+		}
+	}
+	if recent < throttleMinAdmissions {
+		return false
+	}
+	return float64(rejected) >= ThrottleRejectionRate*float64(recent)
+}
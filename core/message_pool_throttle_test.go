@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolThrottle(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+
+	t.Run("saturated pool throttles", func(t *testing.T) {
+		cfg := config.NewDefaultConfig().Mpool
+		cfg.MaxPoolSize = 10
+		cfg.HighWaterMark = 0.9
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+		msgs := types.NewSignedMsgs(10, mockSigner)
+		MustAdd(pool, msgs[:2]...)
+		assert.False(t, pool.ShouldThrottle())
+		assert.Zero(t, pool.SuggestedBackoff())
+
+		MustAdd(pool, msgs[2:9]...)
+		assert.True(t, pool.ShouldThrottle())
+		assert.Equal(t, ThrottleBackoff, pool.SuggestedBackoff())
+
+		MustAdd(pool, msgs[9])
+		assert.Equal(t, 2*ThrottleBackoff, pool.SuggestedBackoff())
+	})
+
+	t.Run("frequent rejections throttle", func(t *testing.T) {
+		validator := th.NewMockMessagePoolValidator()
+		pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, validator)
+
+		MustAdd(pool, types.NewSignedMsgs(1, mockSigner)...)
+		validator.Valid = false
+		for i := 0; i < throttleMinAdmissions-2; i++ {
+			_, err := pool.Add(ctx, mustSetNonce(mockSigner, newSignedMessage(), types.Uint64(i+1)))
+			assert.Error(t, err)
+		}
+		assert.False(t, pool.ShouldThrottle(), "too few attempts to judge")
+
+		_, err := pool.Add(ctx, newSignedMessage())
+		assert.Error(t, err)
+		assert.True(t, pool.ShouldThrottle())
+		assert.Equal(t, ThrottleBackoff, pool.SuggestedBackoff())
+
+		t.Log("rejections older than the window no longer count")
+		pool.lk.Lock()
+		for i := range pool.admissions {
+			pool.admissions[i].Time = pool.admissions[i].Time.Add(-ThrottleWindow - time.Second)
+		}
+		pool.lk.Unlock()
+		assert.False(t, pool.ShouldThrottle())
+		assert.Zero(t, pool.SuggestedBackoff())
+	})
+}
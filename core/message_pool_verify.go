@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrBlockMessagesMismatch is returned by UpdateMessagePool when a block provider returns a
+// block that does not hash to the CID it was asked for, so that the messages it carries may
+// not be the block's.
+var ErrBlockMessagesMismatch = errors.New("block does not match its CID")
+
+// verifyingBlockProvider checks that the blocks it returns hash to the CIDs they were
+// requested by. The block's CID is computed afresh rather than taken from its cache.
+type verifyingBlockProvider struct {
+	chain.BlockProvider
+}
+
+func (p verifyingBlockProvider) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	blk, err := p.BlockProvider.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if actual := blk.ToNode().Cid(); !actual.Equals(c) {
+		return nil, errors.Wrapf(ErrBlockMessagesMismatch, "requested block %s, got block %s", c, actual)
+	}
+	return blk, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-hamt-ipld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// tamperingBlockProvider replaces the messages of the blocks it returns.
+type tamperingBlockProvider struct {
+	*storeBlockProvider
+	msgs []*types.SignedMessage
+}
+
+func (p *tamperingBlockProvider) GetBlock(ctx context.Context, c cid.Cid) (*types.Block, error) {
+	blk, err := p.storeBlockProvider.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	blk.Messages = p.msgs
+	return blk, nil
+}
+
+func TestMessagePoolVerifyBlockMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	type msgs []*types.SignedMessage
+	type msgsSet [][]*types.SignedMessage
+
+	store := hamt.NewCborStore()
+	m := types.NewSignedMsgs(2, mockSigner)
+	base := NewChainWithMessages(store, types.TipSet{}, msgsSet{msgs{}})
+	mined := NewChainWithMessages(store, base[0], msgsSet{msgs{m[0]}}, msgsSet{msgs{}})
+	tampering := &tamperingBlockProvider{storeBlockProvider: &storeBlockProvider{store}, msgs: m}
+
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+	MustAdd(pool, m...)
+	err := pool.UpdateMessagePool(ctx, tampering, headOf(base), headOf(mined))
+	assert.Equal(t, ErrBlockMessagesMismatch, errors.Cause(err))
+	assertPoolEquals(t, pool, m...)
+
+	require.NoError(t, pool.UpdateMessagePool(ctx, &storeBlockProvider{store}, headOf(base), headOf(mined)))
+	assertPoolEquals(t, pool, m[1])
+
+	t.Log("without verification the tampered messages are believed")
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.VerifyBlockMessages = false
+	trusting := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+	MustAdd(trusting, m...)
+	require.NoError(t, trusting.UpdateMessagePool(ctx, tampering, headOf(base), headOf(mined)))
+	assert.Empty(t, trusting.Pending())
+}
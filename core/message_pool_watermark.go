@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-filecoin/metrics"
+)
+
+var mpHighWater = metrics.NewInt64Counter("message_pool_high_water", "The number of times the message pool crossed its high-water mark")
+
+// OnHighWater registers cb to be called when the number of pending messages first reaches
+// the configured HighWaterMark fraction of MaxPoolSize. It is not called again until the
+// pool has dropped below the LowWaterMark fraction. cb is called with the pool locked, so
+// it must not call back into the pool.
+func (pool *MessagePool) OnHighWater(cb func(count, max int)) {
+	pool.lk.Lock()
+	defer pool.lk.Unlock()
+	pool.onHighWater = cb
+}
+
+// checkHighWaterLocked notes and reports the pool reaching its high-water mark. The caller
+// must hold the write lock.
+func (pool *MessagePool) checkHighWaterLocked(ctx context.Context) {
+	if pool.aboveHighWater || pool.cfg.HighWaterMark <= 0 {
+		return
+	}
+	count, max := len(pool.pending), pool.cfg.MaxPoolSize
+	if float64(count) < pool.cfg.HighWaterMark*float64(max) {
+		return
+	}
+
+	pool.aboveHighWater = true
+	mpHighWater.Inc(ctx, 1)
+	log.Warningf("message pool holds %d of a maximum %d messages", count, max)
+	if pool.onHighWater != nil {
+		pool.onHighWater(count, max)
+	}
+}
+
+// checkLowWaterLocked notes the pool dropping below its low-water mark. The caller must
+// hold the write lock.
+func (pool *MessagePool) checkLowWaterLocked() {
+	if pool.aboveHighWater && float64(len(pool.pending)) < pool.cfg.LowWaterMark*float64(pool.cfg.MaxPoolSize) {
+		pool.aboveHighWater = false
+	}
+}
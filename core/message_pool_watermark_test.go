@@ -0,0 +1,60 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func TestMessagePoolHighWater(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := config.NewDefaultConfig().Mpool
+	cfg.MaxPoolSize = 10
+	cfg.HighWaterMark = 0.9
+	cfg.LowWaterMark = 0.5
+	pool := NewMessagePool(th.NewTestMessagePoolAPI(0), cfg, th.NewMockMessagePoolValidator())
+
+	var fired []int
+	pool.OnHighWater(func(count, max int) {
+		assert.Equal(t, 10, max)
+		fired = append(fired, count)
+	})
+
+	msgs := types.NewSignedMsgs(10, mockSigner)
+	cids := make([]cid.Cid, len(msgs))
+	for i, msg := range msgs {
+		c, err := msg.Cid()
+		require.NoError(t, err)
+		cids[i] = c
+	}
+
+	t.Log("crossing the high-water mark fires once")
+	MustAdd(pool, msgs[:8]...)
+	assert.Empty(t, fired)
+	MustAdd(pool, msgs[8])
+	assert.Equal(t, []int{9}, fired)
+	MustAdd(pool, msgs[9])
+	assert.Equal(t, []int{9}, fired)
+
+	t.Log("dropping to the low-water mark does not reset")
+	for _, c := range cids[5:] {
+		pool.Remove(c)
+	}
+	MustAdd(pool, msgs[5:]...)
+	assert.Equal(t, []int{9}, fired)
+
+	t.Log("dropping below the low-water mark resets")
+	for _, c := range cids[4:] {
+		pool.Remove(c)
+	}
+	MustAdd(pool, msgs[4:9]...)
+	assert.Equal(t, []int{9, 9}, fired)
+}
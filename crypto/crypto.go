@@ -6,6 +6,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"io"
+	"math/big"
 
 	secp256k1 "github.com/ipsn/go-secp256k1"
 )
@@ -59,6 +60,23 @@ func GenerateKeyFromSeed(seed io.Reader) ([]byte, error) {
 	return privkey, nil
 }
 
+// DeriveKeyFromSeed creates a private key from the bytes read from seed. Unlike
+// GenerateKeyFromSeed, which may consume the seed unpredictably, the same seed always
+// produces the same key. It is meant for reproducible test keys, not for keys that need
+// to be secret.
+func DeriveKeyFromSeed(seed io.Reader) ([]byte, error) {
+	n := secp256k1.S256().Params().N
+	privkey := make([]byte, PrivateKeyBytes)
+	for {
+		if _, err := io.ReadFull(seed, privkey); err != nil {
+			return nil, err
+		}
+		if k := new(big.Int).SetBytes(privkey); k.Sign() > 0 && k.Cmp(n) < 0 {
+			return privkey, nil
+		}
+	}
+}
+
 // GenerateKey creates a new key using secure randomness from crypto.rand.
 func GenerateKey() ([]byte, error) {
 	return GenerateKeyFromSeed(rand.Reader)
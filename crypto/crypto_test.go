@@ -62,3 +62,18 @@ func TestGenerateKey(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, recovered, crypto.PublicKey(sk))
 }
+
+func TestDeriveKeyFromSeed(t *testing.T) {
+	tf.UnitTest(t)
+
+	sk1, err := crypto.DeriveKeyFromSeed(rand.New(rand.NewSource(7)))
+	assert.NoError(t, err)
+	sk2, err := crypto.DeriveKeyFromSeed(rand.New(rand.NewSource(7)))
+	assert.NoError(t, err)
+	sk3, err := crypto.DeriveKeyFromSeed(rand.New(rand.NewSource(8)))
+	assert.NoError(t, err)
+
+	assert.Len(t, sk1, crypto.PrivateKeyBytes)
+	assert.Equal(t, sk1, sk2)
+	assert.NotEqual(t, sk1, sk3)
+}
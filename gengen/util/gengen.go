@@ -203,7 +203,7 @@ func setupPrealloc(st state.Tree, keys []*types.KeyInfo, prealloc []string) erro
 		}
 	}
 
-	netact, err := account.NewActor(types.NewAttoFILFromFIL(10000000000))
+	netact, err := account.NewActor(types.NewAttoFILFromFIL(types.TotalFilecoin))
 	if err != nil {
 		return err
 	}
@@ -377,6 +377,6 @@ type signer struct{}
 
 var _ types.Signer = (*signer)(nil)
 
-func (ggs *signer) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (ggs *signer) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	return nil, nil
 }
@@ -17,7 +17,7 @@ import (
 // Generate returns a new block created from the messages in the pool.
 func (w *DefaultWorker) Generate(ctx context.Context,
 	baseTipSet types.TipSet,
-	ticket types.Signature,
+	ticket types.RawSignature,
 	proof types.PoStProof,
 	nullBlockCount uint64) (*types.Block, error) {
 
@@ -5,6 +5,8 @@ import (
 	"container/heap"
 	"sort"
 
+	"github.com/minio/blake2b-simd"
+
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -25,6 +27,21 @@ type MessageQueue struct {
 
 // NewMessageQueue allocates and initializes a message queue.
 func NewMessageQueue(msgs []*types.SignedMessage) MessageQueue {
+	return NewScoredMessageQueue(msgs, nil)
+}
+
+// NewScoredMessageQueue allocates and initializes a message queue that orders messages by
+// decreasing score rather than gas price, still subject to each actor's nonce order.
+// Messages with equal scores are ordered by gas price. A nil score orders by gas price.
+func NewScoredMessageQueue(msgs []*types.SignedMessage, score func(*types.SignedMessage) float64) MessageQueue {
+	return NewSeededMessageQueue(msgs, score, nil)
+}
+
+// NewSeededMessageQueue allocates and initializes a message queue like NewScoredMessageQueue,
+// but breaks ties between senders by the hash of seed and the sender's address rather than
+// by address alone, so that no sender is favored on every block. The same seed always gives
+// the same order. A nil seed breaks ties by address.
+func NewSeededMessageQueue(msgs []*types.SignedMessage, score func(*types.SignedMessage) float64, seed []byte) MessageQueue {
 	// Group messages by sender.
 	bySender := make(map[address.Address]nonceQueue)
 	for _, m := range msgs {
@@ -32,13 +49,20 @@ func NewMessageQueue(msgs []*types.SignedMessage) MessageQueue {
 	}
 
 	// Order each sender queue by nonce and initialize heap structure.
-	addrHeap := make(queueHeap, len(bySender))
+	addrHeap := queueHeap{queues: make([]nonceQueue, len(bySender)), score: score}
 	heapIdx := 0
 	for _, nq := range bySender {
 		sort.Slice(nq, func(i, j int) bool { return nq[i].Nonce < nq[j].Nonce })
-		addrHeap[heapIdx] = nq
+		addrHeap.queues[heapIdx] = nq
 		heapIdx++
 	}
+	if seed != nil {
+		addrHeap.tiebreak = make(map[address.Address][]byte, len(bySender))
+		for addr := range bySender {
+			sum := blake2b.Sum256(append(append([]byte{}, seed...), addr.Bytes()...))
+			addrHeap.tiebreak[addr] = sum[:]
+		}
+	}
 	heap.Init(&addrHeap)
 
 	return MessageQueue{addrHeap}
@@ -46,16 +70,16 @@ func NewMessageQueue(msgs []*types.SignedMessage) MessageQueue {
 
 // Empty tests whether the queue is empty.
 func (mq *MessageQueue) Empty() bool {
-	return len(mq.senderQueues) == 0
+	return mq.senderQueues.Len() == 0
 }
 
 // Pop removes and returns the next message from the queue, returning (nil, false) if none remain.
 func (mq *MessageQueue) Pop() (*types.SignedMessage, bool) {
-	if len(mq.senderQueues) == 0 {
+	if mq.senderQueues.Len() == 0 {
 		return nil, false
 	}
 	// Select actor with best gas price.
-	bestQueue := &mq.senderQueues[0]
+	bestQueue := &mq.senderQueues.queues[0]
 
 	// Pop first message off that actor's queue
 	msg := (*bestQueue)[0]
@@ -84,36 +108,53 @@ func (mq *MessageQueue) Drain() []*types.SignedMessage {
 type nonceQueue []*types.SignedMessage
 
 // Implements heap.Interface to hold a priority queue of nonce-ordered queues, one per sender.
-// Heap priority is given by the gas price of the first message for each queue.
+// Heap priority is given by the gas price, or score if there is a score function, of the
+// first message for each queue.
 // Each sender queue is expected to be ordered by increasing nonce.
 // Implementation is simplified from https://golang.org/pkg/container/heap/#example__priorityQueue.
-type queueHeap []nonceQueue
+type queueHeap struct {
+	queues []nonceQueue
+	score  func(*types.SignedMessage) float64
+	// tiebreak, if not nil, holds the key ordering senders whose first messages are equal.
+	tiebreak map[address.Address][]byte
+}
 
-func (pq queueHeap) Len() int { return len(pq) }
+func (pq *queueHeap) Len() int { return len(pq.queues) }
 
-// Less implements Heap.Interface.Less to compare items on gas price and sender address.
-func (pq queueHeap) Less(i, j int) bool {
-	delta := pq[i][0].MeteredMessage.GasPrice.Sub(&pq[j][0].MeteredMessage.GasPrice)
+// Less implements Heap.Interface.Less to compare items on score, if any, gas price and sender address.
+func (pq *queueHeap) Less(i, j int) bool {
+	mi, mj := pq.queues[i][0], pq.queues[j][0]
+	if pq.score != nil {
+		if si, sj := pq.score(mi), pq.score(mj); si != sj {
+			return si > sj
+		}
+	}
+	delta := mi.MeteredMessage.GasPrice.Sub(&mj.MeteredMessage.GasPrice)
 	if !delta.Equal(types.ZeroAttoFIL) {
 		// We want Pop to give us the highest gas price, so use GreaterThan.
 		return delta.GreaterThan(types.ZeroAttoFIL)
 	}
-	// Secondarily order by address to give a stable ordering.
-	return bytes.Compare(pq[i][0].From.Bytes(), pq[j][0].From.Bytes()) < 0
+	// Secondarily order by seeded tiebreak key, if any, then address to give a stable ordering.
+	if pq.tiebreak != nil {
+		if c := bytes.Compare(pq.tiebreak[mi.From], pq.tiebreak[mj.From]); c != 0 {
+			return c < 0
+		}
+	}
+	return bytes.Compare(mi.From.Bytes(), mj.From.Bytes()) < 0
 }
 
-func (pq queueHeap) Swap(i, j int) {
-	pq[i], pq[j] = pq[j], pq[i]
+func (pq *queueHeap) Swap(i, j int) {
+	pq.queues[i], pq.queues[j] = pq.queues[j], pq.queues[i]
 }
 
 func (pq *queueHeap) Push(x interface{}) {
 	item := x.(nonceQueue)
-	*pq = append(*pq, item)
+	pq.queues = append(pq.queues, item)
 }
 
 func (pq *queueHeap) Pop() interface{} {
-	n := len(*pq)
-	item := (*pq)[n-1]
-	*pq = (*pq)[0 : n-1]
+	n := len(pq.queues)
+	item := pq.queues[n-1]
+	pq.queues = pq.queues[0 : n-1]
 	return item
 }
@@ -99,4 +99,19 @@ func TestMessageQueueOrder(t *testing.T) {
 		assert.Equal(t, expected, actual)
 		assert.True(t, q.Empty())
 	})
+	t.Run("orders by score", func(t *testing.T) {
+		msgs := []*types.SignedMessage{
+			sign(a0, to, 0, 0, 3),
+			sign(a0, to, 1, 0, 3),
+			sign(a1, to, 0, 0, 1),
+		}
+		// Score the cheap message above both of a0's, which stay in nonce order.
+		scores := map[*types.SignedMessage]float64{msgs[0]: 1, msgs[1]: 3, msgs[2]: 2}
+		expected := []*types.SignedMessage{msgs[2], msgs[0], msgs[1]}
+
+		q := NewScoredMessageQueue(msgs, func(msg *types.SignedMessage) float64 { return scores[msg] })
+		actual := q.Drain()
+		assert.Equal(t, expected, actual)
+		assert.True(t, q.Empty())
+	})
 }
@@ -282,7 +282,7 @@ func (api *API) NetworkPeers(ctx context.Context, verbose, latency, streams bool
 }
 
 // SignBytes uses private key information associated with the given address to sign the given bytes.
-func (api *API) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (api *API) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	return api.wallet.SignBytes(data, addr)
 }
 
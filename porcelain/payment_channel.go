@@ -53,7 +53,7 @@ func PaymentChannelLs(
 
 type pcvPlumbing interface {
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
-	SignBytes(data []byte, addr address.Address) (types.Signature, error)
+	SignBytes(data []byte, addr address.Address) (types.RawSignature, error)
 	WalletDefaultAddress() (address.Address, error)
 }
 
@@ -60,7 +60,7 @@ func (p *testPaymentChannelVoucherPlumbing) MessageQuery(ctx context.Context, op
 	return [][]byte{result}, nil
 }
 
-func (p *testPaymentChannelVoucherPlumbing) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (p *testPaymentChannelVoucherPlumbing) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	return []byte("test"), nil
 }
 
@@ -20,7 +20,7 @@ type cpPlumbing interface {
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, error)
 	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
-	SignBytes(data []byte, addr address.Address) (types.Signature, error)
+	SignBytes(data []byte, addr address.Address) (types.RawSignature, error)
 }
 
 // CreatePaymentsParams structures all the parameters for the CreatePayments command. All values are required.
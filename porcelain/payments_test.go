@@ -86,7 +86,7 @@ func (ptp *paymentsTestPlumbing) ChainBlockHeight() (*types.BlockHeight, error)
 	return ptp.height, nil
 }
 
-func (ptp *paymentsTestPlumbing) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (ptp *paymentsTestPlumbing) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	return []byte("signature"), nil
 }
 
@@ -142,7 +142,7 @@ func TestCreatePayments(t *testing.T) {
 
 			// voucher signature should be what is returned by SignBytes
 
-			sig := types.Signature([]byte("signature"))
+			sig := types.RawSignature([]byte("signature"))
 			assert.Equal(t, sig, voucher.Signature)
 		}
 
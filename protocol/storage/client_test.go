@@ -24,7 +24,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/util/convert"
 )
 
-var testSignature = types.Signature("<test signature>")
+var testSignature = types.RawSignature("<test signature>")
 
 func TestProposeDeal(t *testing.T) {
 	tf.UnitTest(t)
@@ -191,7 +191,7 @@ func (ctp *clientTestAPI) PingMinerWithTimeout(ctx context.Context, p peer.ID, t
 	return nil
 }
 
-func (ctp *clientTestAPI) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (ctp *clientTestAPI) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	return testSignature, nil
 }
 
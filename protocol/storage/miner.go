@@ -334,7 +334,7 @@ func acceptProposal(sm *Miner, p *storagedeal.Proposal) (*storagedeal.Response,
 	resp := &storagedeal.Response{
 		State:       storagedeal.Accepted,
 		ProposalCid: proposalCid,
-		Signature:   types.Signature("signaturrreee"),
+		Signature:   types.RawSignature("signaturrreee"),
 	}
 
 	storageDeal := &storagedeal.Deal{
@@ -363,7 +363,7 @@ func rejectProposal(sm *Miner, p *storagedeal.Proposal, reason string) (*storage
 		State:       storagedeal.Rejected,
 		ProposalCid: proposalCid,
 		Message:     reason,
-		Signature:   types.Signature("signaturrreee"),
+		Signature:   types.RawSignature("signaturrreee"),
 	}
 
 	storageDeal := &storagedeal.Deal{
@@ -127,7 +127,7 @@ func TestReceiveStorageProposal(t *testing.T) {
 		porcelainAPI, miner, _ := defaultMinerTestSetup(t, VoucherInterval, defaultAmountInc)
 
 		invalidSigVouchers := testPaymentVouchers(porcelainAPI, VoucherInterval, defaultAmountInc)
-		invalidSigVouchers[0].Signature = types.Signature([]byte{})
+		invalidSigVouchers[0].Signature = types.RawSignature([]byte{})
 		proposal := testSignedDealProposal(porcelainAPI, invalidSigVouchers, defaultPieceSize)
 
 		res, err := miner.receiveStorageProposal(context.Background(), proposal)
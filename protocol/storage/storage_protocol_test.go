@@ -29,7 +29,7 @@ func TestSerializeProposal(t *testing.T) {
 		Target:    ag(),
 		Amount:    *types.NewAttoFILFromFIL(3),
 		ValidAt:   *types.NewBlockHeight(3),
-		Signature: types.Signature{},
+		Signature: types.RawSignature{},
 	}
 	p.Payment.Vouchers = []*types.PaymentVoucher{voucher}
 	v, _ := cid.Decode("QmcrriCMhjb5ZWzmPNxmP53px47tSPcXBNaMtLdgcKFJYk")
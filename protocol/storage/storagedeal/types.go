@@ -94,7 +94,7 @@ func (dp *Proposal) NewSignedProposal(addr address.Address, signer types.Signer)
 type SignedDealProposal struct {
 	Proposal
 	// Signature is the signature of the client proposing the deal.
-	Signature types.Signature
+	Signature types.RawSignature
 }
 
 // Response is the information sent over the wire, when a miner responds to a client.
@@ -113,7 +113,7 @@ type Response struct {
 	ProofInfo *ProofInfo
 
 	// Signature is a signature from the miner over the response
-	Signature types.Signature
+	Signature types.RawSignature
 }
 
 // Deal is a storage deal struct
@@ -55,7 +55,24 @@ const (
 	},
 	"mpool": {
 		"maxPoolSize": 10000,
-		"maxNonceGap": "100"
+		"maxNonceGap": "100",
+		"blockGasLimit": "10000000",
+		"highWaterMark": 0.9,
+		"lowWaterMark": 0.8,
+		"maxReorgDepth": "0",
+		"replaceOnlyHead": false,
+		"asyncAcceptedHook": false,
+		"admissionLogSize": 256,
+		"flushInterval": "",
+		"perSenderBlockQuota": 0,
+		"minGasForMethod": {},
+		"trustLoaded": false,
+		"allowedSenderCodes": {},
+		"futureQueueSize": 0,
+		"requiredBalanceMultiple": 0,
+		"methodRateLimits": {},
+		"maxParamsSize": 0,
+		"verifyBlockMessages": true
 	},
 	"net": "",
 	"observability": {
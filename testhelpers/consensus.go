@@ -141,8 +141,8 @@ func NewTestProcessor() *consensus.DefaultProcessor {
 
 type testSigner struct{}
 
-func (ms testSigner) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
-	return types.Signature{}, nil
+func (ms testSigner) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
+	return types.RawSignature{}, nil
 }
 
 // ApplyTestMessage sends a message directly to the vm, bypassing message
@@ -184,7 +184,7 @@ func CreateAndApplyTestMessage(t *testing.T, st state.Tree, vms vm.StorageMap, t
 }
 
 func applyTestMessageWithAncestors(st state.Tree, store vm.StorageMap, msg *types.Message, bh *types.BlockHeight, ancestors []types.TipSet) (*consensus.ApplicationResult, error) {
-	smsg, err := types.NewSignedMessage(*msg, testSigner{}, types.NewGasPrice(1), types.NewGasUnits(300))
+	smsg, err := types.NewSignedMessageWithDefaults(*msg, testSigner{})
 	if err != nil {
 		panic(err)
 	}
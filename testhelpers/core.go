@@ -90,14 +90,28 @@ func RequireRandomPeerID(t *testing.T) peer.ID {
 	return pid
 }
 
-// TestMessagePoolAPI provides a simple BlockTimer interface implementation.
+// TestMessagePoolAPI is a MessagePoolAPI for tests, with a settable block height. Addresses
+// registered with SetActor resolve to their own actors; every other address resolves to
+// Actor, an empty account actor unless modified.
 type TestMessagePoolAPI struct {
 	Height uint64
+	// Actor is returned for any address without its own actor.
+	Actor  *actor.Actor
+	actors map[address.Address]*actor.Actor
 }
 
 // NewTestMessagePoolAPI creates a new TestMessagePoolAPI.
 func NewTestMessagePoolAPI(h uint64) *TestMessagePoolAPI {
-	return &TestMessagePoolAPI{Height: h}
+	return &TestMessagePoolAPI{
+		Height: h,
+		Actor:  actor.NewActor(types.AccountActorCodeCid, types.NewZeroAttoFIL()),
+		actors: make(map[address.Address]*actor.Actor),
+	}
+}
+
+// SetActor sets the actor the api returns for addr.
+func (tbt *TestMessagePoolAPI) SetActor(addr address.Address, act *actor.Actor) {
+	tbt.actors[addr] = act
 }
 
 // MockMessagePoolValidator is a mock validator
@@ -123,6 +137,47 @@ func (tbt *TestMessagePoolAPI) BlockHeight() (uint64, error) {
 	return tbt.Height, nil
 }
 
+// ActorFromLatestState returns the actor set for address, or the api's Actor if there is none.
+func (tbt *TestMessagePoolAPI) ActorFromLatestState(ctx context.Context, address address.Address) (*actor.Actor, error) {
+	if act, ok := tbt.actors[address]; ok {
+		return act, nil
+	}
+	return tbt.Actor, nil
+}
+
+// ActorState builds actors for tests.
+type ActorState struct {
+	act *actor.Actor
+}
+
+// NewActorState returns a builder for an account actor with zero nonce and balance.
+func NewActorState() *ActorState {
+	return &ActorState{act: actor.NewActor(types.AccountActorCodeCid, types.NewZeroAttoFIL())}
+}
+
+// WithNonce sets the actor's nonce.
+func (as *ActorState) WithNonce(nonce uint64) *ActorState {
+	as.act.Nonce = types.Uint64(nonce)
+	return as
+}
+
+// WithBalance sets the actor's balance.
+func (as *ActorState) WithBalance(balance *types.AttoFIL) *ActorState {
+	as.act.Balance = balance
+	return as
+}
+
+// AsMiner makes the actor a miner actor.
+func (as *ActorState) AsMiner() *ActorState {
+	as.act.Code = types.MinerActorCodeCid
+	return as
+}
+
+// Build returns the actor.
+func (as *ActorState) Build() *actor.Actor {
+	return as.act
+}
+
 // VMStorage creates a new storage object backed by an in memory datastore
 func VMStorage() vm.StorageMap {
 	return vm.NewStorageMap(blockstore.NewBlockstore(datastore.NewMapDatastore()))
@@ -23,6 +23,10 @@ func init() {
 var attoPower = 18
 var tenToTheEighteen = big.NewInt(10).Exp(big.NewInt(10), big.NewInt(18), nil)
 
+// TotalFilecoin is the number of FIL that will ever exist, all of which the network actor
+// holds at genesis.
+const TotalFilecoin = 10000000000
+
 // ZeroAttoFIL represents an AttoFIL quantity of 0
 var ZeroAttoFIL *AttoFIL
 
@@ -231,6 +235,50 @@ func (z *AttoFIL) String() string {
 	return strings.TrimRight(noTrailZeroStr, ".")
 }
 
+// FormatFIL returns z in FIL with trailing zeros trimmed and the unit appended, for
+// example "1.5 FIL". Unlike String it also renders negative values correctly.
+func (z *AttoFIL) FormatFIL() string {
+	ensureZeroAmounts(&z)
+	intPart, fracPart := new(big.Int).QuoRem(new(big.Int).Abs(z.val), tenToTheEighteen, new(big.Int))
+
+	str := intPart.String()
+	if fracPart.Sign() != 0 {
+		frac := fracPart.String()
+		frac = strings.Repeat("0", attoPower-len(frac)) + frac
+		str += "." + strings.TrimRight(frac, "0")
+	}
+	if z.val.Sign() < 0 {
+		str = "-" + str
+	}
+	return str + " FIL"
+}
+
+// FormatAtto returns z as a whole number of attoFIL with the unit appended, for example
+// "1500000000000000000 attoFIL".
+func (z *AttoFIL) FormatAtto() string {
+	ensureZeroAmounts(&z)
+	return z.val.String() + " attoFIL"
+}
+
+// ParseAttoFIL parses an amount written as by FormatFIL or FormatAtto, such as "1.5 FIL"
+// or "1500000000000000000 attoFIL".
+func ParseAttoFIL(s string) (*AttoFIL, error) {
+	var (
+		af *AttoFIL
+		ok bool
+	)
+	switch {
+	case strings.HasSuffix(s, " attoFIL"):
+		af, ok = NewAttoFILFromString(strings.TrimSuffix(s, " attoFIL"), 10)
+	case strings.HasSuffix(s, " FIL"):
+		af, ok = NewAttoFILFromFILString(strings.TrimSuffix(s, " FIL"))
+	}
+	if !ok {
+		return nil, fmt.Errorf("invalid FIL amount %q", s)
+	}
+	return af, nil
+}
+
 // CalculatePrice treats z as a price in AttoFIL/Byte and applies it to numBytes to calculate a total price.
 func (z *AttoFIL) CalculatePrice(numBytes *BytesAmount) *AttoFIL {
 	ensureZeroAmounts(&z)
@@ -11,6 +11,7 @@ import (
 
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func BigIntFromString(s string) *big.Int {
@@ -390,6 +391,35 @@ func TestString(t *testing.T) {
 	assert.Equal(t, "123", NewAttoFIL(attoFIL).String())
 }
 
+func TestFormatFIL(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("round trips through ParseAttoFIL", func(t *testing.T) {
+		for _, s := range []string{"1.5 FIL", "0 FIL", "123 FIL", "-0.25 FIL", "912129289198393.123456789012345678 FIL"} {
+			af, err := ParseAttoFIL(s)
+			require.NoError(t, err)
+			assert.Equal(t, s, af.FormatFIL())
+		}
+	})
+
+	t.Run("formats a single attoFIL without an exponent", func(t *testing.T) {
+		one := NewAttoFIL(big.NewInt(1))
+		assert.Equal(t, "0.000000000000000001 FIL", one.FormatFIL())
+		assert.Equal(t, "1 attoFIL", one.FormatAtto())
+
+		parsed, err := ParseAttoFIL(one.FormatAtto())
+		require.NoError(t, err)
+		assert.True(t, one.Equal(parsed))
+	})
+
+	t.Run("rejects amounts without a unit", func(t *testing.T) {
+		_, err := ParseAttoFIL("1.5")
+		assert.Error(t, err)
+		_, err = ParseAttoFIL("1.5 attoFIL")
+		assert.Error(t, err)
+	})
+}
+
 func TestNewAttoFILFromFILString(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -23,7 +23,7 @@ type Block struct {
 	Miner address.Address `json:"miner"`
 
 	// Ticket is the winning ticket that was submitted with this block.
-	Ticket Signature `json:"ticket"`
+	Ticket RawSignature `json:"ticket"`
 
 	// Parents is the set of parents this block was based on. Typically one,
 	// but can be several in the case where there were multiple winning ticket-
@@ -96,6 +96,11 @@ func (b *Block) Cid() cid.Cid {
 	return b.cachedCid
 }
 
+// MessagesRoot returns the ComputeMessagesRoot of the block's messages.
+func (b *Block) MessagesRoot() (cid.Cid, error) {
+	return ComputeMessagesRoot(b.Messages)
+}
+
 // IsParentOf returns true if the argument is a parent of the receiver.
 func (b Block) IsParentOf(c Block) bool {
 	return c.Parents.Has(b.Cid())
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"context"
+)
+
+// GasEstimator estimates the gas limit a message needs, so that it can be set before the
+// message is signed.
+type GasEstimator interface {
+	// EstimateGas returns the gas limit msg is expected to need.
+	EstimateGas(ctx context.Context, msg Message) (GasUnits, error)
+}
+
+// StaticGasEstimator is a GasEstimator that returns itself for every message.
+type StaticGasEstimator GasUnits
+
+var _ GasEstimator = StaticGasEstimator(0)
+
+// EstimateGas returns e.
+func (e StaticGasEstimator) EstimateGas(ctx context.Context, msg Message) (GasUnits, error) {
+	return GasUnits(e), nil
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"github.com/minio/blake2b-simd"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/crypto"
+)
+
+// GenesisKeyInfo is the well-known key that signs genesis messages. Its private key is derived
+// from a fixed string and is public, so a genesis message's signature shows only that it was
+// built by NewGenesisMessage, not who built it.
+var GenesisKeyInfo KeyInfo
+
+// GenesisAddress is the address of GenesisKeyInfo, the sender of genesis messages.
+var GenesisAddress address.Address
+
+func init() {
+	key := blake2b.Sum256([]byte("filecoin genesis message key"))
+	GenesisKeyInfo = KeyInfo{PrivateKey: key[:], Curve: SECP256K1}
+
+	var err error
+	if GenesisAddress, err = GenesisKeyInfo.Address(); err != nil {
+		panic(err)
+	}
+}
+
+// genesisSigner signs with GenesisKeyInfo.
+type genesisSigner struct{}
+
+func (genesisSigner) SignBytes(data []byte, addr address.Address) (RawSignature, error) {
+	hash := blake2b.Sum256(data)
+	return crypto.Sign(GenesisKeyInfo.Key(), hash[:])
+}
+
+// NewGenesisMessage returns a message from GenesisAddress invoking method on to with params,
+// signed with GenesisKeyInfo. It carries no value, has nonce zero and pays no gas. Signatures
+// are deterministic, so the same arguments always give a message with the same CID, which
+// makes it suitable for building reproducible test networks.
+func NewGenesisMessage(to address.Address, method string, params []byte) (*SignedMessage, error) {
+	msg := NewMessage(GenesisAddress, to, 0, ZeroAttoFIL, method, params)
+	return NewSignedMessage(*msg, genesisSigner{}, NewGasPrice(0), NewGasUnits(0))
+}
+
+// IsGenesisMessage returns true if msg was sent from GenesisAddress and its signature verifies.
+func IsGenesisMessage(msg *SignedMessage) bool {
+	return msg.From == GenesisAddress && msg.VerifySignature()
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestNewGenesisMessage(t *testing.T) {
+	tf.UnitTest(t)
+
+	msg1, err := NewGenesisMessage(address.TestAddress, "initialize", []byte("params"))
+	require.NoError(t, err)
+	msg2, err := NewGenesisMessage(address.TestAddress, "initialize", []byte("params"))
+	require.NoError(t, err)
+
+	c1, err := msg1.Cid()
+	require.NoError(t, err)
+	c2, err := msg2.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, c1, c2)
+
+	assert.Equal(t, GenesisAddress, msg1.From)
+	assert.True(t, IsGenesisMessage(msg1))
+
+	other, err := NewGenesisMessage(address.TestAddress2, "initialize", []byte("params"))
+	require.NoError(t, err)
+	c3, err := other.Cid()
+	require.NoError(t, err)
+	assert.NotEqual(t, c1, c3)
+
+	t.Log("messages from other senders are not genesis messages")
+	assert.False(t, IsGenesisMessage(makeMessage(t, mockSigner, 0)))
+	tampered := *msg1
+	tampered.To = address.TestAddress2
+	assert.False(t, IsGenesisMessage(&tampered))
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"math/rand"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/crypto"
 )
 
@@ -37,3 +38,20 @@ func GenerateKeyInfoSeed() io.Reader {
 	rand.Read(token)
 	return bytes.NewReader(token)
 }
+
+// NewDeterministicSigner returns a MockSigner holding n keys, along with their addresses,
+// that depend only on seed. The same seed gives the same keys in every run, so tests may
+// compare against fixed addresses.
+func NewDeterministicSigner(seed int64, n int) (MockSigner, []address.Address) {
+	r := rand.New(rand.NewSource(seed))
+	kis := make([]KeyInfo, n)
+	for i := range kis {
+		prv, err := crypto.DeriveKeyFromSeed(r)
+		if err != nil {
+			panic(err)
+		}
+		kis[i] = KeyInfo{PrivateKey: prv, Curve: SECP256K1}
+	}
+	signer := NewMockSigner(kis)
+	return signer, signer.Addresses
+}
@@ -23,6 +23,10 @@ var (
 	ErrInvalidMessageLength = errors.New("invalid message length")
 )
 
+// CreateActorMethod is the method name carried by create-actor messages. Such messages
+// have no recipient; the code cid of the actor to create is carried in the params.
+const CreateActorMethod = "createActor"
+
 // Message is an exchange of information between two actors modeled
 // as a function call.
 // Messages are the equivalent of transactions in Ethereum.
@@ -53,6 +57,25 @@ func NewMessage(from, to address.Address, nonce uint64, value *AttoFIL, method s
 	}
 }
 
+// NewCreateActorMessage creates a message that creates a new actor running `code`.
+// The recipient is left undefined, which is only valid for this form of message.
+func NewCreateActorMessage(from address.Address, nonce uint64, value *AttoFIL, code cid.Cid) *Message {
+	return &Message{
+		From:   from,
+		To:     address.Undef,
+		Nonce:  Uint64(nonce),
+		Value:  value,
+		Method: CreateActorMethod,
+		Params: code.Bytes(),
+	}
+}
+
+// IsCreateActor returns true if the message is in the canonical create-actor form,
+// i.e. it has no recipient and invokes CreateActorMethod.
+func (msg *Message) IsCreateActor() bool {
+	return msg.To.Empty() && msg.Method == CreateActorMethod
+}
+
 // Unmarshal a message from the given bytes.
 func (msg *Message) Unmarshal(b []byte) error {
 	return cbor.DecodeInto(b, msg)
@@ -0,0 +1,68 @@
+package types
+
+import (
+	"encoding/binary"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/pkg/errors"
+)
+
+// MessageBatchVersion is the version byte that starts an encoded message batch.
+const MessageBatchVersion = 1
+
+// MaxMessageBatchSize is the largest encoded message batch that will be decoded.
+const MaxMessageBatchSize = 4 << 20
+
+// EncodeMessageBatch encodes msgs as a single blob for sending to peers: a version byte,
+// the length of the rest of the blob as a uvarint, and the messages as one CBOR list.
+func EncodeMessageBatch(msgs []*SignedMessage) ([]byte, error) {
+	if msgs == nil {
+		msgs = []*SignedMessage{}
+	}
+	body, err := cbor.DumpObject(msgs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode message batch")
+	}
+
+	out := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(body))
+	out[0] = MessageBatchVersion
+	n := binary.PutUvarint(out[1:], uint64(len(body)))
+	return append(out[:1+n], body...), nil
+}
+
+// DecodeMessageBatch decodes a blob encoded by EncodeMessageBatch. Since the blob may come
+// from an untrusted peer, it is rejected if it is truncated, too large, of an unknown
+// version, or holds a message with out of bounds fields.
+func DecodeMessageBatch(b []byte) ([]*SignedMessage, error) {
+	if len(b) > MaxMessageBatchSize {
+		return nil, errors.Errorf("encoded message batch is %d bytes, larger than the maximum of %d", len(b), MaxMessageBatchSize)
+	}
+	if len(b) == 0 {
+		return nil, errors.New("message batch is empty")
+	}
+	if b[0] != MessageBatchVersion {
+		return nil, errors.Errorf("unsupported message batch version %d", b[0])
+	}
+	length, n := binary.Uvarint(b[1:])
+	if n <= 0 {
+		return nil, errors.New("message batch has an invalid length")
+	}
+	body := b[1+n:]
+	if uint64(len(body)) != length {
+		return nil, errors.Errorf("message batch body is %d bytes, expected %d", len(body), length)
+	}
+
+	var msgs []*SignedMessage
+	if err := cbor.DecodeInto(body, &msgs); err != nil {
+		return nil, errors.Wrap(err, "failed to decode message batch")
+	}
+	for i, msg := range msgs {
+		if msg == nil {
+			return nil, errors.Errorf("message %d of batch is missing", i)
+		}
+		if err := msg.checkBounds(); err != nil {
+			return nil, errors.Wrapf(err, "message %d of batch", i)
+		}
+	}
+	return msgs, nil
+}
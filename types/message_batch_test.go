@@ -0,0 +1,67 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestMessageBatch(t *testing.T) {
+	tf.UnitTest(t)
+
+	to, err := address.NewActorAddress([]byte("receiver"))
+	require.NoError(t, err)
+	transfer, err := NewSignedMessage(*NewMessage(mockSigner.Addresses[0], to, 7, NewAttoFILFromFIL(3), "", nil), &mockSigner, NewGasPrice(2), NewGasUnits(300))
+	require.NoError(t, err)
+	msgs := []*SignedMessage{
+		makeMessage(t, mockSigner, 0),
+		transfer,
+		makeMessage(t, mockSigner, 1),
+	}
+
+	t.Run("round trips", func(t *testing.T) {
+		blob, err := EncodeMessageBatch(msgs)
+		require.NoError(t, err)
+		assert.Equal(t, byte(MessageBatchVersion), blob[0])
+
+		decoded, err := DecodeMessageBatch(blob)
+		require.NoError(t, err)
+		require.Len(t, decoded, len(msgs))
+		for i, msg := range msgs {
+			assert.True(t, msg.Equals(decoded[i]))
+			expected, err := msg.Cid()
+			require.NoError(t, err)
+			actual, err := decoded[i].Cid()
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+
+		blob, err = EncodeMessageBatch(nil)
+		require.NoError(t, err)
+		decoded, err = DecodeMessageBatch(blob)
+		require.NoError(t, err)
+		assert.Empty(t, decoded)
+	})
+
+	t.Run("invalid blobs are rejected", func(t *testing.T) {
+		blob, err := EncodeMessageBatch(msgs)
+		require.NoError(t, err)
+
+		for _, n := range []int{0, 1, 2, len(blob) / 2, len(blob) - 1} {
+			_, err = DecodeMessageBatch(blob[:n])
+			assert.Error(t, err, "truncated to %d bytes", n)
+		}
+
+		versioned := append([]byte{}, blob...)
+		versioned[0] = MessageBatchVersion + 1
+		_, err = DecodeMessageBatch(versioned)
+		assert.Error(t, err)
+
+		_, err = DecodeMessageBatch(append(blob, 0))
+		assert.Error(t, err)
+	})
+}
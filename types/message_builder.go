@@ -0,0 +1,121 @@
+package types
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+)
+
+// MessageBuilder assembles a Message or SignedMessage field by field. From is required, as
+// is To unless the message creates an actor. An unset value is zero, and an unset gas price
+// is DefaultGasPrice. An unset gas limit is estimated by the builder's GasEstimator when it
+// signs, or is DefaultGasLimit if there is none.
+type MessageBuilder struct {
+	msg         Message
+	gasPrice    AttoFIL
+	gasLimit    GasUnits
+	gasLimitSet bool
+	estimator   GasEstimator
+}
+
+// NewMessageBuilder returns an empty MessageBuilder.
+func NewMessageBuilder() *MessageBuilder {
+	return &MessageBuilder{
+		gasPrice: DefaultGasPrice,
+		gasLimit: DefaultGasLimit,
+	}
+}
+
+// From sets the sender.
+func (b *MessageBuilder) From(addr address.Address) *MessageBuilder {
+	b.msg.From = addr
+	return b
+}
+
+// To sets the recipient.
+func (b *MessageBuilder) To(addr address.Address) *MessageBuilder {
+	b.msg.To = addr
+	return b
+}
+
+// Nonce sets the nonce.
+func (b *MessageBuilder) Nonce(nonce uint64) *MessageBuilder {
+	b.msg.Nonce = Uint64(nonce)
+	return b
+}
+
+// Value sets the value transferred.
+func (b *MessageBuilder) Value(value *AttoFIL) *MessageBuilder {
+	b.msg.Value = value
+	return b
+}
+
+// Method sets the method invoked on the recipient.
+func (b *MessageBuilder) Method(method string) *MessageBuilder {
+	b.msg.Method = method
+	return b
+}
+
+// Params sets the encoded method parameters.
+func (b *MessageBuilder) Params(params []byte) *MessageBuilder {
+	b.msg.Params = params
+	return b
+}
+
+// GasPrice sets the price paid per unit of gas.
+func (b *MessageBuilder) GasPrice(price AttoFIL) *MessageBuilder {
+	b.gasPrice = price
+	return b
+}
+
+// GasLimit sets the most gas the message may use.
+func (b *MessageBuilder) GasLimit(limit GasUnits) *MessageBuilder {
+	b.gasLimit = limit
+	b.gasLimitSet = true
+	return b
+}
+
+// GasEstimator sets the estimator Sign uses for the gas limit if none is set.
+func (b *MessageBuilder) GasEstimator(estimator GasEstimator) *MessageBuilder {
+	b.estimator = estimator
+	return b
+}
+
+// Build returns the message, or an error naming the required fields that were not set.
+func (b *MessageBuilder) Build() (Message, error) {
+	var missing []string
+	if b.msg.From.Empty() {
+		missing = append(missing, "from")
+	}
+	if b.msg.To.Empty() && b.msg.Method != CreateActorMethod {
+		missing = append(missing, "to")
+	}
+	if len(missing) > 0 {
+		return Message{}, errors.Errorf("message is missing %s", strings.Join(missing, ", "))
+	}
+
+	msg := b.msg
+	if msg.Value == nil {
+		msg.Value = NewZeroAttoFIL()
+	}
+	return msg, nil
+}
+
+// Sign builds the message and signs it with signer.
+func (b *MessageBuilder) Sign(signer Signer) (*SignedMessage, error) {
+	msg, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	gasLimit := b.gasLimit
+	if !b.gasLimitSet && b.estimator != nil {
+		if gasLimit, err = b.estimator.EstimateGas(context.Background(), msg); err != nil {
+			return nil, errors.Wrap(err, "failed to estimate gas limit")
+		}
+	}
+	return NewSignedMessage(msg, signer, b.gasPrice, gasLimit)
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := address.NewForTestGetter()
+	to := addrGetter()
+	from := mockSigner.Addresses[0]
+
+	t.Run("builds the same message as NewSignedMessage", func(t *testing.T) {
+		built, err := NewMessageBuilder().
+			From(from).
+			To(to).
+			Nonce(3).
+			Value(NewAttoFILFromFIL(2)).
+			Method("method").
+			Params([]byte("params")).
+			GasPrice(NewGasPrice(7)).
+			GasLimit(NewGasUnits(500)).
+			Sign(&mockSigner)
+		require.NoError(t, err)
+
+		manual, err := NewSignedMessage(*NewMessage(from, to, 3, NewAttoFILFromFIL(2), "method", []byte("params")), &mockSigner, NewGasPrice(7), NewGasUnits(500))
+		require.NoError(t, err)
+		assert.True(t, manual.Equals(built))
+	})
+
+	t.Run("defaults value and gas", func(t *testing.T) {
+		built, err := NewMessageBuilder().From(from).To(to).Sign(&mockSigner)
+		require.NoError(t, err)
+		assert.True(t, built.Value.IsZero())
+		assert.Equal(t, DefaultGasPrice, built.GasPrice)
+		assert.Equal(t, DefaultGasLimit, built.GasLimit)
+	})
+
+	t.Run("create actor messages need no recipient", func(t *testing.T) {
+		msg, err := NewMessageBuilder().From(from).Method(CreateActorMethod).Build()
+		require.NoError(t, err)
+		assert.True(t, msg.IsCreateActor())
+	})
+
+	t.Run("missing fields are reported", func(t *testing.T) {
+		_, err := NewMessageBuilder().To(to).Build()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "from")
+
+		_, err = NewMessageBuilder().Sign(&mockSigner)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing from, to")
+	})
+
+	t.Run("an unset gas limit is estimated", func(t *testing.T) {
+		estimator := StaticGasEstimator(1234)
+		msg, err := NewMessageBuilder().From(from).To(to).Build()
+		require.NoError(t, err)
+		gas, err := estimator.EstimateGas(context.Background(), msg)
+		require.NoError(t, err)
+		assert.Equal(t, NewGasUnits(1234), gas)
+
+		built, err := NewMessageBuilder().From(from).To(to).GasEstimator(estimator).Sign(&mockSigner)
+		require.NoError(t, err)
+		assert.Equal(t, NewGasUnits(1234), built.GasLimit)
+		assert.True(t, built.VerifySignature())
+
+		t.Log("an explicit gas limit is kept")
+		built, err = NewMessageBuilder().From(from).To(to).GasLimit(NewGasUnits(500)).GasEstimator(estimator).Sign(&mockSigner)
+		require.NoError(t, err)
+		assert.Equal(t, NewGasUnits(500), built.GasLimit)
+	})
+}
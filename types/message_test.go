@@ -100,3 +100,19 @@ func TestMessageString(t *testing.T) {
 	got := msg.String()
 	assert.Contains(t, got, cid.String())
 }
+
+func TestNewCreateActorMessage(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := address.NewForTestGetter()
+	from := addrGetter()
+
+	msg := NewCreateActorMessage(from, 3, NewAttoFILFromFIL(1), AccountActorCodeCid)
+	assert.True(t, msg.To.Empty())
+	assert.Equal(t, CreateActorMethod, msg.Method)
+	assert.Equal(t, AccountActorCodeCid.Bytes(), msg.Params)
+	assert.True(t, msg.IsCreateActor())
+
+	regular := NewMessage(from, addrGetter(), 3, NewAttoFILFromFIL(1), CreateActorMethod, nil)
+	assert.False(t, regular.IsCreateActor())
+}
@@ -12,6 +12,13 @@ type GasUnits = Uint64
 // BlockGasLimit is the maximum amount of gas that can be used to execute messages in a single block
 var BlockGasLimit = NewGasUnits(10000000)
 
+// DefaultGasPrice is the gas price NewSignedMessageWithDefaults pays.
+var DefaultGasPrice = NewGasPrice(1)
+
+// DefaultGasLimit is the gas limit NewSignedMessageWithDefaults sets. It is enough for
+// simple messages such as value transfers.
+var DefaultGasLimit = NewGasUnits(300)
+
 func init() {
 	cbor.RegisterCborType(MeteredMessage{})
 }
@@ -44,6 +51,14 @@ func (msg *MeteredMessage) Marshal() ([]byte, error) {
 	return cbor.DumpObject(msg)
 }
 
+// SigningBytes returns the bytes a sender signs to authorize the message: the encoding of
+// the message together with its gas price and limit. This is not the preimage of the
+// SignedMessage's CID, which also covers the signature, so the two must not be used in
+// place of each other. Every signature over a message is made and checked over these bytes.
+func (msg *MeteredMessage) SigningBytes() ([]byte, error) {
+	return msg.Marshal()
+}
+
 // NewGasPrice constructs a gas price (in AttoFIL) from the given number.
 func NewGasPrice(price int64) AttoFIL {
 	return *NewAttoFIL(big.NewInt(price))
@@ -52,3 +52,62 @@ func TestMeteredMessageMessage(t *testing.T) {
 		assert.True(t, mmsg.Equals(&msgBack))
 	})
 }
+
+func TestMeteredMessageSigningBytes(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := address.NewForTestGetter()
+	from := mockSigner.Addresses[0]
+	newMsg := func() *MeteredMessage {
+		inner := NewMessage(from, addrGetter(), 42, NewAttoFILFromFIL(17777), "send", []byte("foobar"))
+		return NewMeteredMessage(*inner, *NewAttoFILFromFIL(2), NewGasUnits(300))
+	}
+
+	t.Run("signatures over the signing bytes verify", func(t *testing.T) {
+		mmsg := newMsg()
+		data, err := mmsg.SigningBytes()
+		require.NoError(t, err)
+		sig, err := mockSigner.SignBytes(data, from)
+		require.NoError(t, err)
+
+		smsg := &SignedMessage{MeteredMessage: *mmsg, Signature: NewSecp256k1Signature(sig)}
+		assert.True(t, smsg.VerifySignature())
+
+		signed, err := NewSignedMessage(mmsg.Message, mockSigner, mmsg.GasPrice, mmsg.GasLimit)
+		require.NoError(t, err)
+		signedData, err := signed.SigningBytes()
+		require.NoError(t, err)
+		assert.Equal(t, data, signedData)
+
+		encoded, err := signed.Marshal()
+		require.NoError(t, err)
+		assert.NotEqual(t, data, encoded)
+	})
+
+	t.Run("every field changes the signing bytes", func(t *testing.T) {
+		mutations := map[string]func(*MeteredMessage){
+			"to":       func(m *MeteredMessage) { m.To = addrGetter() },
+			"from":     func(m *MeteredMessage) { m.From = addrGetter() },
+			"nonce":    func(m *MeteredMessage) { m.Nonce = 43 },
+			"value":    func(m *MeteredMessage) { m.Value = NewAttoFILFromFIL(1) },
+			"method":   func(m *MeteredMessage) { m.Method = "other" },
+			"params":   func(m *MeteredMessage) { m.Params = []byte("baz") },
+			"gasPrice": func(m *MeteredMessage) { m.GasPrice = *NewAttoFILFromFIL(3) },
+			"gasLimit": func(m *MeteredMessage) { m.GasLimit = NewGasUnits(301) },
+		}
+		require.Equal(t, 6, reflect.TypeOf(Message{}).NumField())
+		require.Equal(t, 3, reflect.TypeOf(MeteredMessage{}).NumField())
+
+		base := newMsg()
+		baseData, err := base.SigningBytes()
+		require.NoError(t, err)
+		for name, mutate := range mutations {
+			mmsg := newMsg()
+			mmsg.To = base.To
+			mutate(mmsg)
+			data, err := mmsg.SigningBytes()
+			require.NoError(t, err)
+			assert.NotEqual(t, baseData, data, name)
+		}
+	})
+}
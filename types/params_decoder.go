@@ -0,0 +1,33 @@
+package types
+
+import (
+	"sync"
+)
+
+// ParamsDecoder decodes the params of messages calling a particular method.
+type ParamsDecoder func(params []byte) (interface{}, error)
+
+var (
+	paramsDecodersLk sync.RWMutex
+	paramsDecoders   = make(map[string]ParamsDecoder)
+)
+
+// RegisterParamsDecoder makes DecodeKnownParams decode the params of messages calling method
+// with fn, replacing any decoder already registered for method.
+func RegisterParamsDecoder(method string, fn ParamsDecoder) {
+	paramsDecodersLk.Lock()
+	defer paramsDecodersLk.Unlock()
+	paramsDecoders[method] = fn
+}
+
+// DecodeKnownParams decodes msg's params with the decoder registered for its method. The
+// params of methods without a decoder are returned as they are, as a []byte.
+func DecodeKnownParams(msg Message) (interface{}, error) {
+	paramsDecodersLk.RLock()
+	fn, ok := paramsDecoders[msg.Method]
+	paramsDecodersLk.RUnlock()
+	if !ok {
+		return msg.Params, nil
+	}
+	return fn(msg.Params)
+}
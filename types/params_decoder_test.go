@@ -0,0 +1,38 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestDecodeKnownParams(t *testing.T) {
+	tf.UnitTest(t)
+
+	RegisterParamsDecoder("testUpper", func(params []byte) (interface{}, error) {
+		if len(params) == 0 {
+			return nil, errors.New("no params")
+		}
+		return strings.ToUpper(string(params)), nil
+	})
+
+	msg := NewMessage(address.TestAddress, address.TestAddress2, 0, ZeroAttoFIL, "testUpper", []byte("params"))
+	decoded, err := DecodeKnownParams(*msg)
+	require.NoError(t, err)
+	assert.Equal(t, "PARAMS", decoded)
+
+	msg.Params = nil
+	_, err = DecodeKnownParams(*msg)
+	assert.Error(t, err)
+
+	msg = NewMessage(address.TestAddress, address.TestAddress2, 0, ZeroAttoFIL, "testUnknown", []byte("params"))
+	decoded, err = DecodeKnownParams(*msg)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("params"), decoded)
+}
@@ -46,7 +46,7 @@ type PaymentVoucher struct {
 	Condition *Predicate `json:"condition"`
 
 	// Signature is the signature of all the data in this voucher.
-	Signature Signature `json:"signature"`
+	Signature RawSignature `json:"signature"`
 }
 
 // DecodeVoucher creates a *PaymentVoucher from a base58, Cbor-encoded one
@@ -2,5 +2,5 @@ package types
 
 // Recoverer is an interface for ecrecover
 type Recoverer interface {
-	Ecrecover(data []byte, sig Signature) ([]byte, error)
+	Ecrecover(data []byte, sig RawSignature) ([]byte, error)
 }
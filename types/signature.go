@@ -1,20 +1,125 @@
 package types
 
 import (
+	"bytes"
+	"fmt"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
 	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+	"github.com/polydawn/refmt/obj/atlas"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	bls "github.com/filecoin-project/go-filecoin/bls-signatures"
 	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
 var log = logging.Logger("types")
 
-// Signature is the result of a cryptographic sign operation.
-type Signature []byte
+func init() {
+	cbor.RegisterCborType(signatureAtlasEntry)
+}
+
+// SigType identifies the cryptographic scheme used to produce a signature.
+type SigType uint64
+
+const (
+	// SigTypeSecp256k1 is a recoverable secp256k1 ECDSA signature. It is the zero value.
+	SigTypeSecp256k1 SigType = iota
+	// SigTypeBLS is a BLS12-381 signature.
+	SigTypeBLS
+)
+
+func (t SigType) String() string {
+	switch t {
+	case SigTypeSecp256k1:
+		return "secp256k1"
+	case SigTypeBLS:
+		return "bls"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint64(t))
+	}
+}
+
+// RawSignature is the untagged result of a cryptographic sign operation.
+type RawSignature []byte
+
+// Signature is a signature tagged with the scheme that produced it.
+type Signature struct {
+	Type SigType `json:"type"`
+	Data []byte  `json:"data"`
+}
+
+// signatureAtlasEntry encodes secp256k1 signatures as a bare byte string, which is how
+// signatures were encoded before they were tagged with their type, so that messages signed
+// before then still decode and keep their CIDs. Signatures of other types are encoded as a
+// map of their type and data.
+var signatureAtlasEntry = atlas.BuildEntry(Signature{}).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(sig Signature) (interface{}, error) {
+			if sig.Type == SigTypeSecp256k1 {
+				return sig.Data, nil
+			}
+			return map[string]interface{}{"type": uint64(sig.Type), "data": sig.Data}, nil
+		})).
+	TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+		func(x interface{}) (Signature, error) {
+			switch x := x.(type) {
+			case nil:
+				return Signature{}, nil
+			case []byte:
+				return NewSecp256k1Signature(x), nil
+			case map[string]interface{}:
+				return signatureFromMap(x)
+			default:
+				return Signature{}, errors.Errorf("invalid signature encoding %T", x)
+			}
+		})).
+	Complete()
+
+// signatureFromMap decodes a signature encoded as a map of its type and data.
+func signatureFromMap(m map[string]interface{}) (Signature, error) {
+	var sig Signature
+	switch t := m["type"].(type) {
+	case uint64:
+		sig.Type = SigType(t)
+	case int:
+		if t < 0 {
+			return Signature{}, errors.Errorf("invalid signature type %d", t)
+		}
+		sig.Type = SigType(t)
+	default:
+		return Signature{}, errors.Errorf("invalid signature type %v", m["type"])
+	}
+	if sig.Type == SigTypeSecp256k1 {
+		return Signature{}, errors.New("secp256k1 signatures must be encoded as bytes")
+	}
+	data, ok := m["data"].([]byte)
+	if !ok {
+		return Signature{}, errors.Errorf("invalid signature data %v", m["data"])
+	}
+	sig.Data = data
+	return sig, nil
+}
+
+// NewSecp256k1Signature tags raw secp256k1 signature bytes.
+func NewSecp256k1Signature(data RawSignature) Signature {
+	return Signature{Type: SigTypeSecp256k1, Data: data}
+}
+
+// Empty returns true if the signature carries no data.
+func (sig Signature) Empty() bool {
+	return len(sig.Data) == 0
+}
+
+// Equals tests whether two signatures are equal.
+func (sig Signature) Equals(other Signature) bool {
+	return sig.Type == other.Type && bytes.Equal(sig.Data, other.Data)
+}
 
 // IsValidSignature cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key belonging to `addr`.
-func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
+func IsValidSignature(data []byte, addr address.Address, sig RawSignature) bool {
 	maybePk, err := wutil.Ecrecover(data, sig)
 	if err != nil {
 		// Any error returned from Ecrecover means this signature is not valid.
@@ -29,3 +134,42 @@ func IsValidSignature(data []byte, addr address.Address, sig Signature) bool {
 
 	return maybeAddr == addr
 }
+
+// IsValidTypedSignature verifies `sig` over `data` for `addr` using the scheme
+// named by the signature's type.
+func IsValidTypedSignature(data []byte, addr address.Address, sig Signature) bool {
+	switch sig.Type {
+	case SigTypeSecp256k1:
+		return IsValidSignature(data, addr, sig.Data)
+	case SigTypeBLS:
+		return isValidBLSSignature(data, addr, sig.Data)
+	default:
+		log.Infof("unknown signature type: %s", sig.Type)
+		return false
+	}
+}
+
+// isValidBLSSignature verifies a BLS signature. BLS addresses carry the full public
+// key as their payload, so no recovery step is needed.
+func isValidBLSSignature(data []byte, addr address.Address, sig []byte) bool {
+	pk, blsSig, ok := blsKeyAndSignature(addr, sig)
+	if !ok {
+		return false
+	}
+	return bls.Verify(blsSig, []bls.Digest{bls.Hash(data)}, []bls.PublicKey{pk})
+}
+
+// blsKeyAndSignature returns the public key of the BLS address addr and sig as a BLS
+// signature. ok is false if addr is not a BLS address or sig is the wrong length.
+func blsKeyAndSignature(addr address.Address, sig []byte) (pk bls.PublicKey, blsSig bls.Signature, ok bool) {
+	if addr.Empty() || addr.Protocol() != address.BLS {
+		return pk, blsSig, false
+	}
+	if len(sig) != bls.SignatureBytes || len(addr.Payload()) != bls.PublicKeyBytes {
+		return pk, blsSig, false
+	}
+
+	copy(blsSig[:], sig)
+	copy(pk[:], addr.Payload())
+	return pk, blsSig, true
+}
@@ -1,15 +1,18 @@
 package types
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
 
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	bls "github.com/filecoin-project/go-filecoin/bls-signatures"
 )
 
 var (
@@ -17,10 +20,33 @@ var (
 	ErrMessageSigned = errors.New("message already contains a signature")
 	// ErrMessageUnsigned is returned when `RecoverAddress` is called on a signedmessage that does not contain a signature
 	ErrMessageUnsigned = errors.New("message does not contain a signature")
+	// ErrUnrecoverableSignature is returned when `RecoverAddress` is called on a signedmessage whose signature
+	// scheme does not support public key recovery
+	ErrUnrecoverableSignature = errors.New("signature type does not support address recovery")
+	// ErrExceedsTotalSupply is returned when an amount is more than all the FIL there is
+	ErrExceedsTotalSupply = errors.New("amount exceeds the total supply of FIL")
 )
 
+const (
+	// MaxSignedMessageSize is the largest encoded SignedMessage that will be unmarshaled.
+	MaxSignedMessageSize = 32 << 10
+	// MaxMessageParamsSize is the largest Params a SignedMessage may carry.
+	MaxMessageParamsSize = 16 << 10
+	// MaxSignatureSize is the largest signature a SignedMessage may carry, that of BLS.
+	MaxSignatureSize = bls.SignatureBytes
+)
+
+// EmptyMessagesRoot is the ComputeMessagesRoot of a block with no messages.
+var EmptyMessagesRoot cid.Cid
+
 func init() {
 	cbor.RegisterCborType(SignedMessage{})
+
+	var err error
+	EmptyMessagesRoot, err = ComputeMessagesRoot(nil)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // SignedMessage contains a message and its signature
@@ -30,6 +56,18 @@ type SignedMessage struct {
 	MeteredMessage `json:"meteredMessage"`
 	Signature      Signature `json:"signature"`
 	// Pay attention to Equals() if updating this struct.
+
+	// cachedCid holds the *cidCache of the last call to Cid.
+	cachedCid atomic.Value
+}
+
+// cidCache is the CID of a SignedMessage together with the message it was computed for and
+// a copy of that message's contents, so that Cid can tell when the cache was copied along
+// with the message or the message has since been modified.
+type cidCache struct {
+	owner    *SignedMessage
+	contents *SignedMessage
+	cid      cid.Cid
 }
 
 // NewSignedMessage accepts a message `msg` and a signer `s`. NewSignedMessage returns a `SignedMessage` containing
@@ -37,25 +75,61 @@ type SignedMessage struct {
 func NewSignedMessage(msg Message, s Signer, gasPrice AttoFIL, gasLimit GasUnits) (*SignedMessage, error) {
 	meteredMsg := NewMeteredMessage(msg, gasPrice, gasLimit)
 
-	mmsg, err := meteredMsg.Marshal()
+	data, err := meteredMsg.SigningBytes()
 	if err != nil {
 		return nil, err
 	}
 
-	sig, err := s.SignBytes(mmsg, msg.From)
+	sig, err := s.SignBytes(data, msg.From)
 	if err != nil {
 		return nil, err
 	}
 
 	return &SignedMessage{
 		MeteredMessage: *meteredMsg,
-		Signature:      sig,
+		Signature:      NewSecp256k1Signature(sig),
 	}, nil
 }
 
-// Unmarshal a SignedMessage from the given bytes.
+// NewSignedMessageWithDefaults is NewSignedMessage paying DefaultGasPrice with a limit of
+// DefaultGasLimit.
+func NewSignedMessageWithDefaults(msg Message, s Signer) (*SignedMessage, error) {
+	return NewSignedMessage(msg, s, DefaultGasPrice, DefaultGasLimit)
+}
+
+// Unmarshal a SignedMessage from the given bytes. Since the bytes may come from an
+// untrusted peer, encodings that are too large or that decode to a message with
+// out of bounds fields are rejected.
 func (smsg *SignedMessage) Unmarshal(b []byte) error {
-	return cbor.DecodeInto(b, smsg)
+	if len(b) > MaxSignedMessageSize {
+		return errors.Errorf("encoded message is %d bytes, larger than the maximum of %d", len(b), MaxSignedMessageSize)
+	}
+	var decoded SignedMessage
+	if err := cbor.DecodeInto(b, &decoded); err != nil {
+		return err
+	}
+	if err := decoded.checkBounds(); err != nil {
+		return err
+	}
+	*smsg = decoded
+	return nil
+}
+
+// checkBounds checks the fields of a decoded message are within sane bounds.
+func (smsg *SignedMessage) checkBounds() error {
+	if len(smsg.Params) > MaxMessageParamsSize {
+		return errors.Errorf("message params are %d bytes, larger than the maximum of %d", len(smsg.Params), MaxMessageParamsSize)
+	}
+	if smsg.Value.IsNegative() {
+		return errors.New("message value is negative")
+	}
+	if smsg.GasPrice.IsNegative() {
+		return errors.New("message gas price is negative")
+	}
+	if len(smsg.Signature.Data) > MaxSignatureSize {
+		return errors.Errorf("message signature is %d bytes, larger than the maximum of %d", len(smsg.Signature.Data), MaxSignatureSize)
+	}
+	return nil
 }
 
 // Marshal the SignedMessage into bytes.
@@ -63,29 +137,80 @@ func (smsg *SignedMessage) Marshal() ([]byte, error) {
 	return cbor.DumpObject(smsg)
 }
 
-// Cid returns the canonical CID for the SignedMessage.
+// Cid returns the canonical CID for the SignedMessage. The CID is cached until the message
+// is modified; copies of the message do not share the cache.
 // TODO: can we avoid returning an error?
 func (smsg *SignedMessage) Cid() (cid.Cid, error) {
+	if cached, ok := smsg.cachedCid.Load().(*cidCache); ok && cached.owner == smsg && cached.contents.sameContents(smsg) {
+		return cached.cid, nil
+	}
+
 	obj, err := cbor.WrapObject(smsg, DefaultHashFunction, -1)
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to marshal to cbor")
 	}
 
-	return obj.Cid(), nil
+	// Concurrent callers may each compute the CID; they store equivalent caches.
+	c := obj.Cid()
+	smsg.cachedCid.Store(&cidCache{owner: smsg, contents: smsg.copyContents(), cid: c})
+	return c, nil
+}
+
+// copyContents returns a deep copy of the message without its CID cache.
+func (smsg *SignedMessage) copyContents() *SignedMessage {
+	out := &SignedMessage{
+		MeteredMessage: smsg.MeteredMessage,
+		Signature:      smsg.Signature,
+	}
+	if smsg.Value != nil {
+		value := copyAttoFIL(*smsg.Value)
+		out.Value = &value
+	}
+	out.Params = copyBytes(smsg.Params)
+	out.GasPrice = copyAttoFIL(smsg.GasPrice)
+	out.Signature.Data = copyBytes(smsg.Signature.Data)
+	return out
+}
+
+// sameContents returns true if smsg and other encode identically.
+func (smsg *SignedMessage) sameContents(other *SignedMessage) bool {
+	return smsg.Equals(other) &&
+		(smsg.Value == nil) == (other.Value == nil) &&
+		(smsg.Params == nil) == (other.Params == nil) &&
+		(smsg.Signature.Data == nil) == (other.Signature.Data == nil)
+}
+
+// copyBytes returns a copy of b, which is nil only if b is.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// copyAttoFIL returns a copy of a that does not share its value.
+func copyAttoFIL(a AttoFIL) AttoFIL {
+	if a.val == nil {
+		return AttoFIL{}
+	}
+	return AttoFIL{val: new(big.Int).Set(a.val)}
 }
 
 // RecoverAddress returns the address derived from the signature and message encapsulated in `SignedMessage`
 func (smsg *SignedMessage) RecoverAddress(r Recoverer) (address.Address, error) {
-	if len(smsg.Signature) < 1 {
+	if smsg.Signature.Empty() {
 		return address.Undef, ErrMessageUnsigned
 	}
+	if smsg.Signature.Type != SigTypeSecp256k1 {
+		return address.Undef, ErrUnrecoverableSignature
+	}
 
-	bmsg, err := smsg.MeteredMessage.Marshal()
+	bmsg, err := smsg.SigningBytes()
 	if err != nil {
 		return address.Undef, err
 	}
 
-	maybePk, err := r.Ecrecover(bmsg, smsg.Signature)
+	maybePk, err := r.Ecrecover(bmsg, smsg.Signature.Data)
 	if err != nil {
 		return address.Undef, err
 	}
@@ -94,15 +219,69 @@ func (smsg *SignedMessage) RecoverAddress(r Recoverer) (address.Address, error)
 
 }
 
-// VerifySignature returns true iff the signature over the message as calculated
-// from EC recover matches the message sender address.
+// VerifySignature returns true iff the signature over the message is valid for the
+// message sender address under the signature's scheme.
 func (smsg *SignedMessage) VerifySignature() bool {
-	bmsg, err := smsg.MeteredMessage.Marshal()
+	bmsg, err := smsg.SigningBytes()
 	if err != nil {
 		log.Infof("invalid signature: %s", err)
 		return false
 	}
-	return IsValidSignature(bmsg, smsg.From, smsg.Signature)
+	return IsValidTypedSignature(bmsg, smsg.From, smsg.Signature)
+}
+
+// VerifyAggregate verifies the signatures of msgs. The BLS signatures are aggregated and
+// checked with a single verification, which is much cheaper than checking each of them;
+// secp256k1 signatures are checked one at a time. ok is true if every signature is valid.
+// Otherwise failed holds, in order, the indices in msgs of the messages whose signatures are
+// invalid; if the aggregate does not verify the BLS signatures are checked one by one to
+// find them.
+func VerifyAggregate(msgs []*SignedMessage) (ok bool, failed []int) {
+	var blsIndices []int
+	var sigs []bls.Signature
+	var digests []bls.Digest
+	var pks []bls.PublicKey
+	for i, msg := range msgs {
+		if msg.Signature.Type != SigTypeBLS {
+			if !msg.VerifySignature() {
+				failed = append(failed, i)
+			}
+			continue
+		}
+
+		bmsg, err := msg.SigningBytes()
+		if err != nil {
+			log.Infof("invalid signature: %s", err)
+			failed = append(failed, i)
+			continue
+		}
+		pk, sig, ok := blsKeyAndSignature(msg.From, msg.Signature.Data)
+		if !ok {
+			failed = append(failed, i)
+			continue
+		}
+		blsIndices = append(blsIndices, i)
+		sigs = append(sigs, sig)
+		digests = append(digests, bls.Hash(bmsg))
+		pks = append(pks, pk)
+	}
+
+	if len(blsIndices) > 0 && !bls.Verify(bls.Aggregate(sigs), digests, pks) {
+		for _, i := range blsIndices {
+			if !msgs[i].VerifySignature() {
+				failed = append(failed, i)
+			}
+		}
+		sort.Ints(failed)
+	}
+	return len(failed) == 0, failed
+}
+
+// Key returns the message's sender and nonce as "from-nonce". A sender's messages with the
+// same nonce share a key, which is cheaper to compute than the CID, making it suitable for
+// logs and for indexing messages where only one message per sender and nonce is kept.
+func (smsg *SignedMessage) Key() string {
+	return fmt.Sprintf("%s-%d", smsg.From, smsg.Nonce)
 }
 
 func (smsg *SignedMessage) String() string {
@@ -118,8 +297,54 @@ func (smsg *SignedMessage) String() string {
 	return fmt.Sprintf("SignedMessage cid=[%v]: %s", cid, string(js))
 }
 
+// CostBreakdown returns what sending the message may cost its sender: the value
+// transferred, the most that can be charged for gas (gas price times gas limit) and their
+// sum, which is RequiredFunds.
+func (smsg *SignedMessage) CostBreakdown() (value AttoFIL, maxGasCost AttoFIL, total AttoFIL) {
+	value = *ZeroAttoFIL.Add(smsg.Value)
+	maxGasCost = *smsg.GasPrice.MulBigInt(new(big.Int).SetUint64(uint64(smsg.GasLimit)))
+	total = *value.Add(&maxGasCost)
+	return value, maxGasCost, total
+}
+
+// RequiredFunds returns the balance the sender needs to send the message: its value plus
+// the most it can be charged for gas.
+func (smsg *SignedMessage) RequiredFunds() *AttoFIL {
+	_, _, total := smsg.CostBreakdown()
+	return &total
+}
+
+// TotalRequiredFunds returns the balance a sender needs to send all of msgs: the sum of
+// their RequiredFunds. It returns ErrExceedsTotalSupply if the sum is more than
+// TotalFilecoin, which no sender can hold.
+func TotalRequiredFunds(msgs []*SignedMessage) (*AttoFIL, error) {
+	supply := NewAttoFILFromFIL(TotalFilecoin)
+	total := ZeroAttoFIL
+	for _, msg := range msgs {
+		total = total.Add(msg.RequiredFunds())
+		if total.GreaterThan(supply) {
+			return nil, ErrExceedsTotalSupply
+		}
+	}
+	return total, nil
+}
+
+// ComputeMessagesRoot returns the CID that commits to msgs, in order, as a block's
+// messages. It is the CID of their encoding as a list, so reordering the messages changes
+// it. No messages, whether msgs is nil or empty, give EmptyMessagesRoot.
+func ComputeMessagesRoot(msgs []*SignedMessage) (cid.Cid, error) {
+	if msgs == nil {
+		msgs = []*SignedMessage{}
+	}
+	obj, err := cbor.WrapObject(msgs, DefaultHashFunction, -1)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to marshal messages to cbor")
+	}
+	return obj.Cid(), nil
+}
+
 // Equals tests whether two signed messages are equal.
 func (smsg *SignedMessage) Equals(other *SignedMessage) bool {
 	return smsg.MeteredMessage.Equals(&other.MeteredMessage) &&
-		bytes.Equal(smsg.Signature, other.Signature)
+		smsg.Signature.Equals(other.Signature)
 }
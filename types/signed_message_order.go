@@ -0,0 +1,47 @@
+package types
+
+import (
+	"bytes"
+	"sort"
+)
+
+// SortSignedMessages sorts msgs in place into canonical order: by sender address bytes,
+// then by nonce, then by CID bytes. Messages with equal keys keep their relative order.
+func SortSignedMessages(msgs []*SignedMessage) {
+	sort.Stable(newSignedMessagesByCanonicalOrder(msgs))
+}
+
+// signedMessagesByCanonicalOrder implements sort.Interface over the canonical message order.
+// CIDs are computed once up front rather than on every comparison.
+type signedMessagesByCanonicalOrder struct {
+	msgs []*SignedMessage
+	cids [][]byte
+}
+
+func newSignedMessagesByCanonicalOrder(msgs []*SignedMessage) signedMessagesByCanonicalOrder {
+	cids := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		// A message that cannot be encoded sorts first among those with its sender and nonce.
+		if c, err := msg.Cid(); err == nil {
+			cids[i] = c.Bytes()
+		}
+	}
+	return signedMessagesByCanonicalOrder{msgs: msgs, cids: cids}
+}
+
+func (o signedMessagesByCanonicalOrder) Len() int { return len(o.msgs) }
+
+func (o signedMessagesByCanonicalOrder) Less(i, j int) bool {
+	if cmp := bytes.Compare(o.msgs[i].From.Bytes(), o.msgs[j].From.Bytes()); cmp != 0 {
+		return cmp < 0
+	}
+	if o.msgs[i].Nonce != o.msgs[j].Nonce {
+		return o.msgs[i].Nonce < o.msgs[j].Nonce
+	}
+	return bytes.Compare(o.cids[i], o.cids[j]) < 0
+}
+
+func (o signedMessagesByCanonicalOrder) Swap(i, j int) {
+	o.msgs[i], o.msgs[j] = o.msgs[j], o.msgs[i]
+	o.cids[i], o.cids[j] = o.cids[j], o.cids[i]
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestSortSignedMessages(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer, _ := NewMockSignersAndKeyInfo(2)
+	first, second := signer.Addresses[0], signer.Addresses[1]
+	if bytes.Compare(first.Bytes(), second.Bytes()) > 0 {
+		first, second = second, first
+	}
+
+	newMsg := func(from address.Address, nonce uint64, method string) *SignedMessage {
+		msg := NewMessage(from, second, nonce, NewAttoFILFromFIL(0), method, nil)
+		smsg, err := NewSignedMessage(*msg, &signer, NewGasPrice(1), NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	// Two messages from the same sender and nonce, which are ordered by CID.
+	a, b := newMsg(first, 1, "a"), newMsg(first, 1, "b")
+	ca, err := a.Cid()
+	require.NoError(t, err)
+	cb, err := b.Cid()
+	require.NoError(t, err)
+	if bytes.Compare(ca.Bytes(), cb.Bytes()) > 0 {
+		a, b = b, a
+	}
+
+	expected := []*SignedMessage{
+		newMsg(first, 0, "x"),
+		a,
+		b,
+		newMsg(first, 2, "x"),
+		newMsg(second, 0, "x"),
+		newMsg(second, 5, "x"),
+	}
+
+	t.Run("sorts into canonical order", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			msgs := append([]*SignedMessage{}, expected...)
+			rand.Shuffle(len(msgs), func(i, j int) { msgs[i], msgs[j] = msgs[j], msgs[i] })
+			SortSignedMessages(msgs)
+			assert.Equal(t, expected, msgs)
+		}
+	})
+
+	t.Run("is stable for equal keys", func(t *testing.T) {
+		dup := *expected[0]
+		msgs := []*SignedMessage{expected[4], &dup, expected[0]}
+		SortSignedMessages(msgs)
+		assert.True(t, msgs[0] == &dup)
+		assert.True(t, msgs[1] == expected[0])
+
+		msgs = []*SignedMessage{expected[4], expected[0], &dup}
+		SortSignedMessages(msgs)
+		assert.True(t, msgs[0] == expected[0])
+		assert.True(t, msgs[1] == &dup)
+	})
+}
@@ -1,13 +1,17 @@
 package types
 
 import (
+	"fmt"
+	"math/big"
 	"reflect"
 	"testing"
 
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	bls "github.com/filecoin-project/go-filecoin/bls-signatures"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 )
 
@@ -24,6 +28,21 @@ func TestSignedMessageString(t *testing.T) {
 	assert.Contains(t, got, cid.String())
 }
 
+func TestSignedMessageKey(t *testing.T) {
+	tf.UnitTest(t)
+
+	smsg := makeMessage(t, mockSigner, 42)
+	assert.Equal(t, fmt.Sprintf("%s-42", smsg.From), smsg.Key())
+
+	assert.NotEqual(t, smsg.Key(), makeMessage(t, mockSigner, 43).Key())
+
+	t.Log("messages from the same sender with the same nonce share a key")
+	other, err := NewSignedMessage(*NewMessage(smsg.From, address.TestAddress2, 42, NewAttoFILFromFIL(1), "other", nil), mockSigner, NewGasPrice(7), NewGasUnits(10))
+	require.NoError(t, err)
+	assert.False(t, smsg.Equals(other))
+	assert.Equal(t, smsg.Key(), other.Key())
+}
+
 func TestSignedMessageRecover(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -61,6 +80,185 @@ func TestSignedMessageMarshal(t *testing.T) {
 	assert.True(t, smsg.Equals(&smsgBack))
 }
 
+func TestSignedMessageSignatureType(t *testing.T) {
+	tf.UnitTest(t)
+
+	smsg := makeMessage(t, mockSigner, 42)
+	assert.Equal(t, SigTypeSecp256k1, smsg.Signature.Type)
+
+	marshalled, err := smsg.Marshal()
+	require.NoError(t, err)
+
+	smsgBack := SignedMessage{}
+	require.NoError(t, smsgBack.Unmarshal(marshalled))
+
+	assert.Equal(t, SigTypeSecp256k1, smsgBack.Signature.Type)
+	assert.Equal(t, smsg.Signature.Data, smsgBack.Signature.Data)
+	assert.True(t, smsgBack.VerifySignature())
+
+	t.Run("unknown type does not verify", func(t *testing.T) {
+		smsgBack.Signature.Type = SigType(42)
+		assert.False(t, smsgBack.VerifySignature())
+	})
+
+	t.Run("non-secp signature cannot be recovered", func(t *testing.T) {
+		smsgBack.Signature.Type = SigTypeBLS
+		assert.False(t, smsgBack.VerifySignature())
+
+		_, err := smsgBack.RecoverAddress(&MockRecoverer{})
+		assert.Equal(t, ErrUnrecoverableSignature, err)
+	})
+}
+
+// legacySignedMessage has the shape of a SignedMessage from before signatures were tagged
+// with their type.
+type legacySignedMessage struct {
+	MeteredMessage `json:"meteredMessage"`
+	Signature      []byte `json:"signature"`
+}
+
+func init() {
+	cbor.RegisterCborType(legacySignedMessage{})
+}
+
+func TestSignedMessageLegacySignature(t *testing.T) {
+	tf.UnitTest(t)
+
+	smsg := makeMessage(t, mockSigner, 42)
+	legacy := legacySignedMessage{MeteredMessage: smsg.MeteredMessage, Signature: smsg.Signature.Data}
+	encoded, err := cbor.DumpObject(legacy)
+	require.NoError(t, err)
+	obj, err := cbor.WrapObject(legacy, DefaultHashFunction, -1)
+	require.NoError(t, err)
+
+	var decoded SignedMessage
+	require.NoError(t, decoded.Unmarshal(encoded))
+	assert.Equal(t, SigTypeSecp256k1, decoded.Signature.Type)
+	assert.True(t, decoded.VerifySignature())
+
+	c, err := decoded.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, obj.Cid(), c)
+	reencoded, err := decoded.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, encoded, reencoded)
+
+	t.Run("other signature types round trip", func(t *testing.T) {
+		typed := *smsg
+		typed.Signature = Signature{Type: SigTypeBLS, Data: []byte("bls signature")}
+		encoded, err := typed.Marshal()
+		require.NoError(t, err)
+
+		var decoded SignedMessage
+		require.NoError(t, decoded.Unmarshal(encoded))
+		assert.True(t, typed.Signature.Equals(decoded.Signature))
+	})
+}
+
+func TestVerifyAggregate(t *testing.T) {
+	tf.UnitTest(t)
+
+	to, err := address.NewActorAddress([]byte("receiver"))
+	require.NoError(t, err)
+	newBLSMessage := func(nonce uint64) *SignedMessage {
+		priv := bls.PrivateKeyGenerate()
+		pk := bls.PrivateKeyPublicKey(priv)
+		from, err := address.NewBLSAddress(pk[:])
+		require.NoError(t, err)
+
+		msg := NewMeteredMessage(*NewMessage(from, to, nonce, NewAttoFILFromFIL(1), "method", nil), NewGasPrice(1), NewGasUnits(0))
+		bmsg, err := msg.SigningBytes()
+		require.NoError(t, err)
+		sig := bls.PrivateKeySign(priv, bmsg)
+		return &SignedMessage{MeteredMessage: *msg, Signature: Signature{Type: SigTypeBLS, Data: sig[:]}}
+	}
+
+	var msgs []*SignedMessage
+	for i := 0; i < 4; i++ {
+		msgs = append(msgs, newBLSMessage(uint64(i)))
+	}
+	msgs = append(msgs, makeMessage(t, mockSigner, 42))
+	for _, msg := range msgs {
+		require.True(t, msg.VerifySignature())
+	}
+
+	ok, failed := VerifyAggregate(msgs)
+	assert.True(t, ok)
+	assert.Empty(t, failed)
+
+	ok, _ = VerifyAggregate(nil)
+	assert.True(t, ok)
+
+	t.Log("tampered messages are identified")
+	tamper := func(msg *SignedMessage) *SignedMessage {
+		tampered := &SignedMessage{MeteredMessage: msg.MeteredMessage, Signature: msg.Signature}
+		tampered.Nonce = 100
+		return tampered
+	}
+	ok, failed = VerifyAggregate([]*SignedMessage{msgs[0], msgs[1], tamper(msgs[2]), msgs[3], tamper(msgs[4])})
+	assert.False(t, ok)
+	assert.Equal(t, []int{2, 4}, failed)
+}
+
+func TestSignedMessageUnmarshalBounds(t *testing.T) {
+	tf.UnitTest(t)
+
+	valid := makeMessage(t, mockSigner, 42)
+	validBytes, err := valid.Marshal()
+	require.NoError(t, err)
+
+	mustMarshal := func(f func(smsg *SignedMessage)) []byte {
+		smsg := makeMessage(t, mockSigner, 42)
+		f(smsg)
+		b, err := smsg.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+
+	testCases := []struct {
+		name   string
+		input  []byte
+		errMsg string
+	}{
+		{"empty", []byte{}, ""},
+		{"oversized encoding", make([]byte, MaxSignedMessageSize+1), "larger than the maximum"},
+		{"oversized params", mustMarshal(func(smsg *SignedMessage) {
+			smsg.Params = make([]byte, MaxMessageParamsSize+1)
+		}), "params"},
+		{"negative value", mustMarshal(func(smsg *SignedMessage) {
+			smsg.Value = NewAttoFIL(big.NewInt(-1))
+		}), "value is negative"},
+		{"negative gas price", mustMarshal(func(smsg *SignedMessage) {
+			smsg.GasPrice = *NewAttoFIL(big.NewInt(-1))
+		}), "gas price is negative"},
+		{"oversized signature", mustMarshal(func(smsg *SignedMessage) {
+			smsg.Signature.Data = make([]byte, MaxSignatureSize+1)
+		}), "signature"},
+	}
+	for i := 1; i < len(validBytes); i++ {
+		testCases = append(testCases, struct {
+			name   string
+			input  []byte
+			errMsg string
+		}{"truncated", validBytes[:i], ""})
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var smsg SignedMessage
+			err := smsg.Unmarshal(tc.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.errMsg)
+		})
+	}
+
+	t.Run("valid message unmarshals", func(t *testing.T) {
+		var smsg SignedMessage
+		require.NoError(t, smsg.Unmarshal(validBytes))
+		assert.True(t, valid.Equals(&smsg))
+	})
+}
+
 func TestSignedMessageCid(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -73,6 +271,93 @@ func TestSignedMessageCid(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.NotEqual(t, c1.String(), c2.String())
+
+	t.Run("cached cid matches a fresh computation", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 43)
+		cached, err := smsg.Cid()
+		require.NoError(t, err)
+		again, err := smsg.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, cached, again)
+
+		obj, err := cbor.WrapObject(smsg, DefaultHashFunction, -1)
+		require.NoError(t, err)
+		assert.Equal(t, obj.Cid(), cached)
+	})
+
+	t.Run("modifying a message in place changes its cid", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 43)
+		original, err := smsg.Cid()
+		require.NoError(t, err)
+
+		smsg.Params[0] ^= 0xff
+		modified, err := smsg.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, original, modified)
+
+		smsg.Params[0] ^= 0xff
+		restored, err := smsg.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, original, restored)
+
+		*smsg.Value = *smsg.Value.Add(NewAttoFILFromFIL(1))
+		modified, err = smsg.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, original, modified)
+	})
+
+	t.Run("unmarshaled message does not keep a stale cid", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 44)
+		other := makeMessage(t, mockSigner, 45)
+		_, err := smsg.Cid()
+		require.NoError(t, err)
+
+		encoded, err := other.Marshal()
+		require.NoError(t, err)
+		require.NoError(t, smsg.Unmarshal(encoded))
+
+		c, err := smsg.Cid()
+		require.NoError(t, err)
+		otherCid, err := other.Cid()
+		require.NoError(t, err)
+		assert.Equal(t, otherCid, c)
+	})
+
+	t.Run("a modified copy has its own cid", func(t *testing.T) {
+		smsg := makeMessage(t, mockSigner, 43)
+		original, err := smsg.Cid()
+		require.NoError(t, err)
+
+		cp := *smsg
+		cp.To, err = address.NewActorAddress([]byte("another receiver"))
+		require.NoError(t, err)
+		modified, err := cp.Cid()
+		require.NoError(t, err)
+		assert.NotEqual(t, original, modified)
+	})
+}
+
+func BenchmarkSignedMessageCid(b *testing.B) {
+	smsg := NewSignedMessageForTestGetter(mockSigner)()
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := smsg.Cid(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fresh := SignedMessage{MeteredMessage: smsg.MeteredMessage, Signature: smsg.Signature}
+			if _, err := fresh.Cid(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 }
 
 func makeMessage(t *testing.T, signer MockSigner, nonce uint64) *SignedMessage {
@@ -90,8 +375,111 @@ func makeMessage(t *testing.T, signer MockSigner, nonce uint64) *SignedMessage {
 	require.NoError(t, err)
 
 	// This check requests that you add a non-zero value for new fields above,
-	// then update the field count below.
-	require.Equal(t, 2, reflect.TypeOf(*smsg).NumField())
+	// then update the field count below. The count includes the unexported CID cache.
+	require.Equal(t, 3, reflect.TypeOf(*smsg).NumField())
 
 	return smsg
 }
+
+func TestSignedMessageCostBreakdown(t *testing.T) {
+	tf.UnitTest(t)
+
+	to, err := address.NewActorAddress([]byte("receiver"))
+	require.NoError(t, err)
+	msg := NewMessage(mockSigner.Addresses[0], to, 0, NewAttoFILFromFIL(2), "method", nil)
+	smsg, err := NewSignedMessage(*msg, &mockSigner, NewGasPrice(1000), NewGasUnits(300))
+	require.NoError(t, err)
+
+	value, maxGasCost, total := smsg.CostBreakdown()
+	assert.True(t, value.Equal(NewAttoFILFromFIL(2)))
+	assert.True(t, maxGasCost.Equal(NewAttoFIL(big.NewInt(300000))))
+	expectedTotal, ok := NewAttoFILFromString("2000000000000300000", 10)
+	require.True(t, ok)
+	assert.True(t, total.Equal(expectedTotal))
+	assert.True(t, total.Equal(smsg.RequiredFunds()))
+
+	t.Log("a message without a value costs only gas")
+	msg.Value = nil
+	smsg, err = NewSignedMessage(*msg, &mockSigner, NewGasPrice(1000), NewGasUnits(300))
+	require.NoError(t, err)
+	value, maxGasCost, total = smsg.CostBreakdown()
+	assert.True(t, value.Equal(ZeroAttoFIL))
+	assert.True(t, total.Equal(&maxGasCost))
+}
+
+func TestTotalRequiredFunds(t *testing.T) {
+	tf.UnitTest(t)
+
+	to, err := address.NewActorAddress([]byte("receiver"))
+	require.NoError(t, err)
+	newMsg := func(nonce uint64, fil uint64, gasPrice int64, gasLimit uint64) *SignedMessage {
+		msg := NewMessage(mockSigner.Addresses[0], to, nonce, NewAttoFILFromFIL(fil), "method", nil)
+		smsg, err := NewSignedMessage(*msg, &mockSigner, NewGasPrice(gasPrice), NewGasUnits(gasLimit))
+		require.NoError(t, err)
+		return smsg
+	}
+
+	total, err := TotalRequiredFunds(nil)
+	require.NoError(t, err)
+	assert.True(t, total.Equal(ZeroAttoFIL))
+
+	// 1 + 2 + 3 FIL of value and 100 + 400 + 900 attoFIL of gas.
+	msgs := []*SignedMessage{newMsg(0, 1, 10, 10), newMsg(1, 2, 20, 20), newMsg(2, 3, 30, 30)}
+	total, err = TotalRequiredFunds(msgs)
+	require.NoError(t, err)
+	expected, ok := NewAttoFILFromString("6000000000000001400", 10)
+	require.True(t, ok)
+	assert.True(t, total.Equal(expected))
+
+	t.Log("a total beyond the supply of FIL is an error")
+	msgs = []*SignedMessage{newMsg(0, TotalFilecoin, 0, 0), newMsg(1, 0, 1, 1)}
+	_, err = TotalRequiredFunds(msgs)
+	assert.Equal(t, ErrExceedsTotalSupply, err)
+	_, err = TotalRequiredFunds(msgs[:1])
+	assert.NoError(t, err)
+}
+
+func TestComputeMessagesRoot(t *testing.T) {
+	tf.UnitTest(t)
+
+	root, err := ComputeMessagesRoot(nil)
+	require.NoError(t, err)
+	assert.Equal(t, EmptyMessagesRoot, root)
+	root, err = ComputeMessagesRoot([]*SignedMessage{})
+	require.NoError(t, err)
+	assert.Equal(t, EmptyMessagesRoot, root)
+
+	msgs := []*SignedMessage{makeMessage(t, mockSigner, 0), makeMessage(t, mockSigner, 1)}
+	root, err = ComputeMessagesRoot(msgs)
+	require.NoError(t, err)
+	assert.NotEqual(t, EmptyMessagesRoot, root)
+	again, err := ComputeMessagesRoot([]*SignedMessage{msgs[0], msgs[1]})
+	require.NoError(t, err)
+	assert.Equal(t, root, again)
+
+	t.Log("the order of the messages is significant")
+	reordered, err := ComputeMessagesRoot([]*SignedMessage{msgs[1], msgs[0]})
+	require.NoError(t, err)
+	assert.NotEqual(t, root, reordered)
+
+	blk := &Block{Messages: msgs}
+	blockRoot, err := blk.MessagesRoot()
+	require.NoError(t, err)
+	assert.Equal(t, root, blockRoot)
+}
+
+func TestNewSignedMessageWithDefaults(t *testing.T) {
+	tf.UnitTest(t)
+
+	to, err := address.NewActorAddress([]byte("receiver"))
+	require.NoError(t, err)
+	msg := NewMessage(mockSigner.Addresses[0], to, 0, NewAttoFILFromFIL(1), "method", nil)
+
+	smsg, err := NewSignedMessageWithDefaults(*msg, &mockSigner)
+	require.NoError(t, err)
+	price := NewGasPrice(1)
+	assert.True(t, smsg.GasPrice.Equal(&price))
+	assert.Equal(t, NewGasUnits(300), smsg.GasLimit)
+	assert.True(t, smsg.VerifySignature())
+	assert.True(t, msg.Equals(&smsg.Message))
+}
@@ -27,7 +27,7 @@ type MockRecoverer struct{}
 // signature from data.
 // Note: The returned public key should not be used to verify `data` is valid
 // since a public key may have N private key pairs
-func (mr *MockRecoverer) Ecrecover(data []byte, sig Signature) ([]byte, error) {
+func (mr *MockRecoverer) Ecrecover(data []byte, sig RawSignature) ([]byte, error) {
 	return wutil.Ecrecover(data, sig)
 }
 
@@ -66,7 +66,7 @@ func NewMockSignersAndKeyInfo(numSigners int) (MockSigner, []KeyInfo) {
 }
 
 // SignBytes cryptographically signs `data` using the Address `addr`.
-func (ms MockSigner) SignBytes(data []byte, addr address.Address) (Signature, error) {
+func (ms MockSigner) SignBytes(data []byte, addr address.Address) (RawSignature, error) {
 	ki, ok := ms.AddrKeyInfo[addr]
 	if !ok {
 		panic("unknown address")
@@ -95,8 +95,8 @@ func (ms MockSigner) GetAddressForPubKey(pk []byte) (address.Address, error) {
 }
 
 // CreateTicket is effectively a duplicate of Wallet CreateTicket for testing purposes.
-func (ms MockSigner) CreateTicket(proof PoStProof, signerPubKey []byte) (Signature, error) {
-	var ticket Signature
+func (ms MockSigner) CreateTicket(proof PoStProof, signerPubKey []byte) (RawSignature, error) {
+	var ticket RawSignature
 
 	signerAddr, err := ms.GetAddressForPubKey(signerPubKey)
 	if err != nil {
@@ -28,3 +28,20 @@ func TestNewMessageForTestGetter(t *testing.T) {
 	c2, _ := m2.Cid()
 	assert.False(t, c1.Equals(c2))
 }
+
+func TestNewDeterministicSigner(t *testing.T) {
+	tf.UnitTest(t)
+
+	signer, addrs := NewDeterministicSigner(42, 3)
+	_, again := NewDeterministicSigner(42, 3)
+	_, other := NewDeterministicSigner(43, 3)
+
+	assert.Len(t, addrs, 3)
+	assert.Equal(t, addrs, again)
+	assert.Equal(t, signer.Addresses, addrs)
+	assert.NotEqual(t, addrs, other)
+	assert.NotEqual(t, addrs[0], addrs[1])
+
+	_, err := signer.SignBytes([]byte("data"), addrs[2])
+	assert.NoError(t, err)
+}
@@ -117,7 +117,7 @@ func (ts TipSet) ToSlice() []*Block {
 }
 
 // MinTicket returns the smallest ticket of all blocks in the tipset.
-func (ts TipSet) MinTicket() (Signature, error) {
+func (ts TipSet) MinTicket() (RawSignature, error) {
 	if len(ts) == 0 {
 		return nil, ErrEmptyTipSet
 	}
@@ -185,7 +185,7 @@ func TestTipSetMinTicket(t *testing.T) {
 	ts := RequireTestTipSet(t)
 	mt, err := ts.MinTicket()
 	assert.NoError(t, err)
-	assert.Equal(t, Signature([]byte{0}), mt)
+	assert.Equal(t, RawSignature([]byte{0}), mt)
 }
 
 func TestTipSetHeight(t *testing.T) {
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"math"
 	"strconv"
 	"strings"
 
@@ -42,3 +43,25 @@ func (u *Uint64) UnmarshalJSON(b []byte) error {
 	*u = Uint64(val)
 	return nil
 }
+
+// Next returns u+1, the nonce that follows u. It saturates rather than wrapping, so the
+// next of math.MaxUint64 is math.MaxUint64.
+func (u Uint64) Next() Uint64 {
+	return u.AddGap(1)
+}
+
+// AddGap returns u+gap, or math.MaxUint64 if the sum does not fit.
+func (u Uint64) AddGap(gap uint64) Uint64 {
+	if uint64(u) > math.MaxUint64-gap {
+		return math.MaxUint64
+	}
+	return u + Uint64(gap)
+}
+
+// GapFrom returns how far u is above base, or zero if it is not above base.
+func (u Uint64) GapFrom(base Uint64) uint64 {
+	if u <= base {
+		return 0
+	}
+	return uint64(u - base)
+}
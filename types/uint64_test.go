@@ -2,6 +2,7 @@ package types
 
 import (
 	"encoding/json"
+	"math"
 	"testing"
 
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -34,3 +35,21 @@ func TestUint64Json(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, v, got)
 }
+
+func TestUint64NonceArithmetic(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Equal(t, Uint64(6), Uint64(5).Next())
+	assert.Equal(t, Uint64(math.MaxUint64), Uint64(math.MaxUint64).Next())
+
+	assert.Equal(t, Uint64(105), Uint64(5).AddGap(100))
+	assert.Equal(t, Uint64(math.MaxUint64), Uint64(math.MaxUint64-1).AddGap(1))
+	assert.Equal(t, Uint64(math.MaxUint64), Uint64(math.MaxUint64-1).AddGap(2))
+	assert.Equal(t, Uint64(math.MaxUint64), Uint64(1).AddGap(math.MaxUint64))
+
+	assert.Equal(t, uint64(100), Uint64(105).GapFrom(5))
+	assert.Equal(t, uint64(0), Uint64(5).GapFrom(5))
+	assert.Equal(t, uint64(0), Uint64(4).GapFrom(5))
+	assert.Equal(t, uint64(math.MaxUint64), Uint64(math.MaxUint64).GapFrom(0))
+	assert.Equal(t, uint64(0), Uint64(0).GapFrom(math.MaxUint64))
+}
@@ -0,0 +1,118 @@
+package types
+
+import (
+	"fmt"
+)
+
+// ValidationCode identifies why a message failed validation.
+type ValidationCode int
+
+const (
+	// ValidationOther is any failure without a more specific code.
+	ValidationOther ValidationCode = iota
+	// ValidationPoolFull means the message pool had no room for the message.
+	ValidationPoolFull
+	// ValidationDuplicateNonce means a message with the same sender and nonce was pending
+	// and the message did not pay enough to replace it.
+	ValidationDuplicateNonce
+	// ValidationNonceGap means the nonce was too far beyond the sender's actor nonce.
+	ValidationNonceGap
+	// ValidationBadSignature means the signature did not verify.
+	ValidationBadSignature
+	// ValidationSelfSend means the sender and recipient were the same.
+	ValidationSelfSend
+	// ValidationBadSender means the message had no sender.
+	ValidationBadSender
+	// ValidationBadRecipient means the message had no recipient and was not a well formed
+	// create actor message.
+	ValidationBadRecipient
+	// ValidationGasPriceZero means the gas price was not positive.
+	ValidationGasPriceZero
+	// ValidationNonAccountActor means the sender was not an account actor.
+	ValidationNonAccountActor
+	// ValidationNegativeValue means the value was negative.
+	ValidationNegativeValue
+	// ValidationGasLimit means the gas limit was above the block gas limit.
+	ValidationGasLimit
+	// ValidationInsufficientBalance means the sender could not cover the value and gas.
+	ValidationInsufficientBalance
+	// ValidationNonceTooLow means the nonce was below the sender's actor nonce.
+	ValidationNonceTooLow
+	// ValidationNonceTooHigh means the nonce was above the sender's actor nonce.
+	ValidationNonceTooHigh
+	// ValidationGasBelowMethodMin means the gas limit was below the minimum for the method.
+	ValidationGasBelowMethodMin
+	// ValidationRateLimited means too many messages invoking the same method arrived recently.
+	ValidationRateLimited
+	// ValidationParamsTooLarge means the params were longer than the pool accepts.
+	ValidationParamsTooLarge
+)
+
+var validationCodeNames = map[ValidationCode]string{
+	ValidationOther:               "other",
+	ValidationPoolFull:            "pool full",
+	ValidationDuplicateNonce:      "duplicate nonce",
+	ValidationNonceGap:            "nonce gap",
+	ValidationBadSignature:        "bad signature",
+	ValidationSelfSend:            "self send",
+	ValidationBadSender:           "bad sender",
+	ValidationBadRecipient:        "bad recipient",
+	ValidationGasPriceZero:        "gas price zero",
+	ValidationNonAccountActor:     "non-account actor",
+	ValidationNegativeValue:       "negative value",
+	ValidationGasLimit:            "gas limit",
+	ValidationInsufficientBalance: "insufficient balance",
+	ValidationNonceTooLow:         "nonce too low",
+	ValidationNonceTooHigh:        "nonce too high",
+	ValidationGasBelowMethodMin:   "gas below method minimum",
+	ValidationRateLimited:         "rate limited",
+	ValidationParamsTooLarge:      "params too large",
+}
+
+func (c ValidationCode) String() string {
+	if name, ok := validationCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(c))
+}
+
+// ValidationError is a message validation failure with a code callers can switch on.
+// Its text is that of the underlying error.
+type ValidationError struct {
+	Code ValidationCode
+	Err  error
+}
+
+// NewValidationError returns a ValidationError with code for err.
+func NewValidationError(code ValidationCode, err error) error {
+	return &ValidationError{Code: code, Err: err}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error, so that errors.Cause sees through a ValidationError.
+func (e *ValidationError) Cause() error {
+	return e.Err
+}
+
+// ValidationCodeOf returns the code of the first ValidationError in err's chain of
+// causes, or ValidationOther if there is none.
+func ValidationCodeOf(err error) ValidationCode {
+	type causer interface {
+		Cause() error
+	}
+
+	for err != nil {
+		if verr, ok := err.(*ValidationError); ok {
+			return verr.Code
+		}
+		cause, ok := err.(causer)
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+	return ValidationOther
+}
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestValidationError(t *testing.T) {
+	tf.UnitTest(t)
+
+	cause := errors.New("nonce too low")
+	err := NewValidationError(ValidationNonceTooLow, cause)
+	assert.Equal(t, "nonce too low", err.Error())
+	assert.Equal(t, cause, errors.Cause(err))
+
+	wrapped := errors.Wrap(err, "validation error adding message to pool")
+	assert.Equal(t, ValidationNonceTooLow, ValidationCodeOf(wrapped))
+	assert.Contains(t, wrapped.Error(), "nonce too low")
+
+	assert.Equal(t, ValidationOther, ValidationCodeOf(cause))
+	assert.Equal(t, ValidationOther, ValidationCodeOf(nil))
+
+	assert.Equal(t, "nonce too low", ValidationNonceTooLow.String())
+	assert.Equal(t, "unknown(1000)", ValidationCode(1000).String())
+}
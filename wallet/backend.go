@@ -14,12 +14,16 @@ type Backend interface {
 	// Contains returns true if this backend stores the passed in address.
 	HasAddress(addr address.Address) bool
 
+	// CanSign returns true if this backend holds usable private key material for
+	// the passed in address.
+	CanSign(addr address.Address) bool
+
 	// Sign cryptographically signs `data` using the private key `priv`.
-	SignBytes(data []byte, addr address.Address) (types.Signature, error)
+	SignBytes(data []byte, addr address.Address) (types.RawSignature, error)
 
 	// Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
 	// the public key `pk`.
-	Verify(data, pk []byte, sig types.Signature) bool
+	Verify(data, pk []byte, sig types.RawSignature) bool
 
 	// GetKeyInfo will return the keyinfo associated with address `addr`
 	// iff backend contains the addr.
@@ -34,3 +38,21 @@ type Importer interface {
 	// into the backend
 	ImportKey(ki *types.KeyInfo) error
 }
+
+// KeyProvider holds keys outside the wallet, such as in a key management service, and
+// lists the addresses they belong to so that the wallet can present them alongside its own.
+type KeyProvider interface {
+	// List returns the addresses of the keys the provider holds.
+	List() []address.Address
+	// CanSign returns true if the provider can sign for addr.
+	CanSign(addr address.Address) bool
+	// Sign signs `data` with the private key of `addr`.
+	Sign(addr address.Address, data []byte) (types.Signature, error)
+}
+
+// RemoteSigner signs on behalf of addresses whose private keys are held outside the
+// wallet, for instance in a hardware security module or a remote signing service.
+type RemoteSigner interface {
+	// RemoteSign signs `data` with the private key of `addr`.
+	RemoteSign(addr address.Address, data []byte) (types.Signature, error)
+}
@@ -1,12 +1,18 @@
 package wallet
 
 import (
+	"bytes"
+	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/minio/blake2b-simd"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -24,24 +30,202 @@ const (
 // DSBackendType is the reflect type of the DSBackend.
 var DSBackendType = reflect.TypeOf(&DSBackend{})
 
+// ErrNoLocalKey is returned when a private key is needed for an address the backend only
+// watches or signs for remotely.
+var ErrNoLocalKey = errors.New("backend does not hold the private key for address")
+
+// ErrAddressLimitReached is returned when creating addresses would take the backend past
+// its maximum number of addresses.
+var ErrAddressLimitReached = errors.New("backend holds the maximum number of addresses")
+
+// ErrUnsupportedKeyType is returned when importing a key of a type the backend cannot sign with.
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+// ErrDuplicateKey is returned when a newly generated key is one the backend already holds,
+// which means the source of entropy is repeating itself.
+var ErrDuplicateKey = errors.New("generated key is already held by the backend")
+
+// ErrAlreadyRotated is returned when rotating an address that already has a successor.
+var ErrAlreadyRotated = errors.New("address has already been rotated")
+
+// ErrSelfTestFailed is returned by SelfTest when the crypto stack does not behave as expected.
+var ErrSelfTestFailed = errors.New("wallet self-test failed")
+
+// ErrBulkAccessDisabled is returned by GetAllKeyPairs unless the backend was constructed
+// with AllowBulkKeyAccess.
+var ErrBulkAccessDisabled = errors.New("bulk key access is disabled")
+
+// ErrReservedNamespace is returned by NewDSBackend when the backend's namespace is named
+// after one of the prefixes under which backends store records about their addresses.
+var ErrReservedNamespace = errors.New("namespace is reserved for address records")
+
+// ErrNoDefaultAddress is returned by GetDefaultAddress when the backend holds no addresses.
+var ErrNoDefaultAddress = errors.New("backend contains no addresses")
+
+// DefaultAddressPolicy controls what GetDefaultAddress does when the backend holds no addresses.
+type DefaultAddressPolicy int
+
+const (
+	// ErrorIfMissing makes GetDefaultAddress return ErrNoDefaultAddress from an empty backend.
+	ErrorIfMissing DefaultAddressPolicy = iota
+	// CreateIfMissing makes GetDefaultAddress create and store a new address in an empty backend.
+	CreateIfMissing
+)
+
+// DuplicateKeyPolicy controls what NewAddress and NewAddresses do when they generate a key
+// the backend already holds.
+type DuplicateKeyPolicy int
+
+const (
+	// RetryOnDuplicate makes the backend generate another key, up to maxDuplicateKeyRetries
+	// times, before giving up with ErrDuplicateKey. It suits random entropy, where a repeat
+	// is a fluke.
+	RetryOnDuplicate DuplicateKeyPolicy = iota
+	// ErrorOnDuplicate makes the backend return ErrDuplicateKey at once. It suits
+	// deterministic entropy, where drawing again would not help.
+	ErrorOnDuplicate
+)
+
+// maxDuplicateKeyRetries is how many more keys RetryOnDuplicate generates after a duplicate.
+const maxDuplicateKeyRetries = 3
+
+// createdAtPrefix is the datastore key under which the creation time of each address is stored.
+const createdAtPrefix = "created"
+
+// successorPrefix is the datastore key under which the successor of each rotated address is stored.
+const successorPrefix = "successor"
+
+// deprecatedPrefix is the datastore key under which deprecated addresses are marked.
+const deprecatedPrefix = "deprecated"
+
 // DSBackend is a wallet backend implementation for storing addresses in a datastore.
 type DSBackend struct {
 	lk sync.RWMutex
 
 	// TODO: use a better interface that supports time locks, encryption, etc.
 	ds repo.Datastore
+	// fallback is read for keys missing from ds, or is nil. It is never written to.
+	fallback repo.Datastore
+	// inFallback holds the addresses in cache that are only stored in fallback.
+	inFallback map[address.Address]struct{}
 
 	// TODO: proper cache
 	cache map[address.Address]struct{}
+	// watchOnly holds the addresses in cache for which there is no private key.
+	watchOnly map[address.Address]struct{}
+	// createdAt holds the time at which the key for each address was stored.
+	createdAt map[address.Address]time.Time
+	// lastCreated is the latest time in createdAt.
+	lastCreated time.Time
+	// successors holds the address each rotated address was rotated to.
+	successors map[address.Address]address.Address
+	// deprecated holds the addresses left out of the default address.
+	deprecated map[address.Address]struct{}
+	// remoteSigners sign for addresses whose private key is not held locally.
+	remoteSigners map[address.Address]RemoteSigner
+	// keyProviders list and sign for addresses whose keys are held elsewhere, in the
+	// order they were added.
+	keyProviders []KeyProvider
+	// hd derives new keys from a seed, or is nil if new keys are random.
+	hd *hdKeychain
+	// entropy is read for the bytes of new random keys, or is nil to use crypto/rand.
+	// entropyLk serializes reads from it.
+	entropy   io.Reader
+	entropyLk sync.Mutex
+	// duplicateKeys decides what happens when a new key is already held.
+	duplicateKeys DuplicateKeyPolicy
+	// onSign is called after each successful signature, or is nil.
+	onSign func(addr address.Address, dataHash []byte)
+	// bulkKeyAccess allows GetAllKeyPairs.
+	bulkKeyAccess bool
+
+	// namespace is the key under which the backend's keys are stored in the datastore it
+	// was given, or empty for the root.
+	namespace ds.Key
+
+	// maxAddresses limits the number of addresses NewAddress and NewAddresses may take the
+	// backend to, or is zero for no limit.
+	maxAddresses int
+	// creating is the number of addresses being created outside the lock.
+	creating int
 }
 
 var _ Backend = (*DSBackend)(nil)
 
+// DSBackendOption is the type of the DSBackend's functional options.
+type DSBackendOption func(backend *DSBackend)
+
+// MaxAddresses returns an option that limits the number of addresses NewAddress and
+// NewAddresses will create. Zero means no limit.
+func MaxAddresses(max int) DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.maxAddresses = max
+	}
+}
+
+// Namespace returns an option that keeps the backend's keys under /namespace in its
+// datastore, so that it can share the datastore with other users. Without it keys are
+// stored at the root of the datastore, and a backend at the root ignores the keys of
+// backends in namespaces below it. NewDSBackend returns ErrReservedNamespace if the name
+// starts with one the backend uses for its own records: "created", "successor" or
+// "deprecated".
+func Namespace(name string) DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.namespace = ds.NewKey(name)
+	}
+}
+
+// Entropy returns an option that makes the backend read the bytes of new random keys from r
+// instead of crypto/rand. The same bytes always produce the same key.
+func Entropy(r io.Reader) DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.entropy = r
+	}
+}
+
+// OnDuplicateKey returns an option that sets what the backend does when it generates a key
+// it already holds. The default is RetryOnDuplicate.
+func OnDuplicateKey(policy DuplicateKeyPolicy) DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.duplicateKeys = policy
+	}
+}
+
+// OnSign returns an option that calls hook after each successful SignBytes with the signing
+// address and the blake2b-256 hash of the signed data, so that signatures can be audited
+// without recording the data itself. The hook is called synchronously and must not call
+// back into the backend.
+func OnSign(hook func(addr address.Address, dataHash []byte)) DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.onSign = hook
+	}
+}
+
+// AllowBulkKeyAccess returns an option that lets GetAllKeyPairs return every private key
+// the backend holds. Without it GetAllKeyPairs fails, so that exposing all keys at once is
+// a deliberate choice made when the backend is constructed.
+func AllowBulkKeyAccess() DSBackendOption {
+	return func(backend *DSBackend) {
+		backend.bulkKeyAccess = true
+	}
+}
+
 // NewDSBackend constructs a new backend using the passed in datastore.
-func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
-	result, err := ds.Query(dsq.Query{
-		KeysOnly: true,
-	})
+func NewDSBackend(ds repo.Datastore, options ...DSBackendOption) (*DSBackend, error) {
+	backend := &DSBackend{
+		remoteSigners: make(map[address.Address]RemoteSigner),
+	}
+	for _, option := range options {
+		option(backend)
+	}
+	if backend.namespace.String() != "" {
+		if isMetadataKey(backend.namespace.String() + "/") {
+			return nil, errors.Wrapf(ErrReservedNamespace, "namespace %s", backend.namespace)
+		}
+		ds = namespace.Wrap(ds, backend.namespace)
+	}
+
+	result, err := ds.Query(dsq.Query{})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query datastore")
 	}
@@ -52,18 +236,141 @@ func NewDSBackend(ds repo.Datastore) (*DSBackend, error) {
 	}
 
 	cache := make(map[address.Address]struct{})
+	watchOnly := make(map[address.Address]struct{})
+	createdAt := make(map[address.Address]time.Time)
+	successors := make(map[address.Address]address.Address)
+	deprecated := make(map[address.Address]struct{})
 	for _, el := range list {
+		if !isOwnKey(el.Key) {
+			continue
+		}
+		if name := strings.TrimPrefix(el.Key, "/"+createdAtPrefix+"/"); name != el.Key {
+			parsedAddr, err := address.NewFromString(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "creation time of invalid address: %s", el.Key)
+			}
+			var t time.Time
+			if err := t.UnmarshalBinary(el.Value); err != nil {
+				return nil, errors.Wrapf(err, "invalid creation time for address %s", parsedAddr)
+			}
+			createdAt[parsedAddr] = t
+			if t.After(backend.lastCreated) {
+				backend.lastCreated = t
+			}
+			continue
+		}
+		if name := strings.TrimPrefix(el.Key, "/"+successorPrefix+"/"); name != el.Key {
+			parsedAddr, err := address.NewFromString(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "successor of invalid address: %s", el.Key)
+			}
+			successor, err := address.NewFromBytes(el.Value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid successor for address %s", parsedAddr)
+			}
+			successors[parsedAddr] = successor
+			continue
+		}
+		if name := strings.TrimPrefix(el.Key, "/"+deprecatedPrefix+"/"); name != el.Key {
+			parsedAddr, err := address.NewFromString(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "deprecation of invalid address: %s", el.Key)
+			}
+			deprecated[parsedAddr] = struct{}{}
+			continue
+		}
+
 		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
 		if err != nil {
 			return nil, errors.Wrapf(err, "trying to restore invalid address: %s", el.Key)
 		}
 		cache[parsedAddr] = struct{}{}
+		// Watch-only addresses are stored without a keyinfo.
+		if len(el.Value) == 0 {
+			watchOnly[parsedAddr] = struct{}{}
+		}
 	}
 
-	return &DSBackend{
-		ds:    ds,
-		cache: cache,
-	}, nil
+	backend.ds = ds
+	backend.cache = cache
+	backend.watchOnly = watchOnly
+	backend.createdAt = createdAt
+	backend.successors = successors
+	backend.deprecated = deprecated
+	return backend, nil
+}
+
+// NewDSBackendWithFallback constructs a backend that stores its keys in primary, and also
+// holds the addresses stored in fallback but missing from primary. Keys are read from primary
+// first and then from fallback, while everything the backend writes goes to primary. This
+// lets keys be moved from one datastore to another gradually. Only the address records of
+// fallback are read: creation times, successors and deprecations stored there are ignored.
+// Options apply to both datastores.
+func NewDSBackendWithFallback(primary, fallback repo.Datastore, options ...DSBackendOption) (*DSBackend, error) {
+	backend, err := NewDSBackend(primary, options...)
+	if err != nil {
+		return nil, err
+	}
+	if backend.namespace.String() != "" {
+		fallback = namespace.Wrap(fallback, backend.namespace)
+	}
+
+	result, err := fallback.Query(dsq.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query fallback datastore")
+	}
+	list, err := result.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fallback query results")
+	}
+
+	inFallback := make(map[address.Address]struct{})
+	for _, el := range list {
+		if !isOwnKey(el.Key) || isMetadataKey(el.Key) {
+			continue
+		}
+		parsedAddr, err := address.NewFromString(strings.Trim(el.Key, "/"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "trying to restore invalid address from fallback: %s", el.Key)
+		}
+		if _, ok := backend.cache[parsedAddr]; ok {
+			continue
+		}
+		backend.cache[parsedAddr] = struct{}{}
+		inFallback[parsedAddr] = struct{}{}
+		if len(el.Value) == 0 {
+			backend.watchOnly[parsedAddr] = struct{}{}
+		}
+	}
+
+	backend.fallback = fallback
+	backend.inFallback = inFallback
+	return backend, nil
+}
+
+// isOwnKey returns true if key, relative to the backend's namespace, is one the backend
+// stores: an address, or a record about an address. Deeper keys belong to backends in
+// namespaces below the backend's own.
+func isOwnKey(key string) bool {
+	switch len(ds.NewKey(key).List()) {
+	case 1:
+		return true
+	case 2:
+		return isMetadataKey(key)
+	default:
+		return false
+	}
+}
+
+// isMetadataKey returns true if key holds a record about an address rather than the address
+// itself.
+func isMetadataKey(key string) bool {
+	for _, prefix := range []string{createdAtPrefix, successorPrefix, deprecatedPrefix} {
+		if strings.HasPrefix(key, "/"+prefix+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 // ImportKey loads the address in `ai` and KeyInfo `ki` into the backend
@@ -71,84 +378,689 @@ func (backend *DSBackend) ImportKey(ki *types.KeyInfo) error {
 	return backend.putKeyInfo(ki)
 }
 
-// Addresses returns a list of all addresses that are stored in this backend.
+// ImportKeyInfo stores the key ki and returns its address. Importing a key the backend
+// already holds does nothing. Only secp256k1 keys are supported.
+func (backend *DSBackend) ImportKeyInfo(ki *types.KeyInfo) (address.Address, error) {
+	if ki.Type() != SECP256K1 {
+		return address.Undef, errors.Wrapf(ErrUnsupportedKeyType, "cannot import %q key", ki.Type())
+	}
+
+	addr, err := ki.Address()
+	if err != nil {
+		return address.Undef, err
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if backend.hasKeyLocked(addr) {
+		return addr, nil
+	}
+	return backend.putKeyInfoLocked(ki)
+}
+
+// Addresses returns a list of all addresses that are stored in this backend, have a remote
+// signer registered or are listed by a key provider.
 func (backend *DSBackend) Addresses() []address.Address {
 	backend.lk.RLock()
-	defer backend.lk.RUnlock()
-
+	seen := make(map[address.Address]struct{}, len(backend.cache))
 	var cpy []address.Address
 	for addr := range backend.cache {
+		seen[addr] = struct{}{}
 		cpy = append(cpy, addr)
 	}
+	for addr := range backend.remoteSigners {
+		if _, ok := seen[addr]; !ok {
+			seen[addr] = struct{}{}
+			cpy = append(cpy, addr)
+		}
+	}
+	providers := backend.keyProviders
+	backend.lk.RUnlock()
+
+	// Providers may be remote, so they are not called with the lock held.
+	for _, provider := range providers {
+		for _, addr := range provider.List() {
+			if _, ok := seen[addr]; !ok {
+				seen[addr] = struct{}{}
+				cpy = append(cpy, addr)
+			}
+		}
+	}
 	return cpy
 }
 
-// HasAddress checks if the passed in address is stored in this backend.
+// HasAddress checks if the passed in address is stored in this backend, has a
+// remote signer registered or is listed by a key provider.
 // Safe for concurrent access.
 func (backend *DSBackend) HasAddress(addr address.Address) bool {
 	backend.lk.RLock()
-	defer backend.lk.RUnlock()
+	_, ok := backend.cache[addr]
+	_, remote := backend.remoteSigners[addr]
+	providers := backend.keyProviders
+	backend.lk.RUnlock()
+	if ok || remote {
+		return true
+	}
+
+	for _, provider := range providers {
+		for _, listed := range provider.List() {
+			if listed == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanSign checks if the passed in address is stored in this backend together with its
+// private key, i.e. it is not watch-only, has a remote signer registered or can be signed
+// for by a key provider.
+// Safe for concurrent access.
+func (backend *DSBackend) CanSign(addr address.Address) bool {
+	backend.lk.RLock()
+	_, remote := backend.remoteSigners[addr]
+	local := backend.hasKeyLocked(addr)
+	backend.lk.RUnlock()
+
+	return local || remote || backend.signingProvider(addr) != nil
+}
+
+// signingProvider returns the first key provider that can sign for addr, or nil if none can.
+func (backend *DSBackend) signingProvider(addr address.Address) KeyProvider {
+	backend.lk.RLock()
+	providers := backend.keyProviders
+	backend.lk.RUnlock()
+
+	for _, provider := range providers {
+		if provider.CanSign(addr) {
+			return provider
+		}
+	}
+	return nil
+}
 
+// hasKeyLocked returns true if the private key for addr is in the datastore. The
+// caller must hold the lock.
+func (backend *DSBackend) hasKeyLocked(addr address.Address) bool {
 	_, ok := backend.cache[addr]
-	return ok
+	_, watched := backend.watchOnly[addr]
+	return ok && !watched
 }
 
-// NewAddress creates a new address and stores it.
+// AddRemoteSigner registers a signer to sign for addr when the backend does not hold
+// its private key. Registrations are not persisted.
 // Safe for concurrent access.
-func (backend *DSBackend) NewAddress() (address.Address, error) {
-	prv, err := crypto.GenerateKey()
+func (backend *DSBackend) AddRemoteSigner(addr address.Address, signer RemoteSigner) {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.remoteSigners[addr] = signer
+}
+
+// AddKeyProvider registers a provider whose addresses the backend lists and signs for.
+// Local keys and remote signers take precedence over providers, and providers over those
+// added after them. Registrations are not persisted.
+// Safe for concurrent access.
+func (backend *DSBackend) AddKeyProvider(provider KeyProvider) {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	backend.keyProviders = append(backend.keyProviders, provider)
+}
+
+// AddWatchOnly stores an address without its private key, so that it is known to the
+// backend but cannot be signed for. Adding an address the backend already holds is a no-op.
+// Safe for concurrent access.
+func (backend *DSBackend) AddWatchOnly(addr address.Address) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if _, ok := backend.cache[addr]; ok {
+		return nil
+	}
+
+	if err := backend.ds.Put(ds.NewKey(addr.String()), []byte{}); err != nil {
+		return errors.Wrap(err, "failed to store watch-only address")
+	}
+
+	backend.cache[addr] = struct{}{}
+	backend.watchOnly[addr] = struct{}{}
+	return nil
+}
+
+// GetDefaultAddress returns the backend's default address, which is the lowest of its
+// addresses in byte order that it can sign for and that are not deprecated. If the backend holds no such addresses the
+// policy decides whether ErrNoDefaultAddress is returned or a single new address is created,
+// even when called concurrently.
+// Safe for concurrent access.
+func (backend *DSBackend) GetDefaultAddress(policy DefaultAddressPolicy) (address.Address, error) {
+	backend.lk.RLock()
+	addr, ok := backend.defaultAddressLocked()
+	backend.lk.RUnlock()
+	if ok {
+		return addr, nil
+	}
+	if policy != CreateIfMissing {
+		return address.Undef, ErrNoDefaultAddress
+	}
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	// Another caller may have created an address while we waited for the write lock.
+	if addr, ok := backend.defaultAddressLocked(); ok {
+		return addr, nil
+	}
+
+	ki, err := backend.newKeyInfo()
 	if err != nil {
 		return address.Undef, err
 	}
+	return backend.putKeyInfoLocked(ki)
+}
 
-	// TODO: maybe the above call should just return a keyinfo?
-	ki := &types.KeyInfo{
-		PrivateKey: prv,
-		Curve:      SECP256K1,
+func (backend *DSBackend) defaultAddressLocked() (address.Address, bool) {
+	var def address.Address
+	found := false
+	for addr := range backend.cache {
+		if _, watched := backend.watchOnly[addr]; watched {
+			continue
+		}
+		if _, deprecated := backend.deprecated[addr]; deprecated {
+			continue
+		}
+		if !found || bytes.Compare(addr.Bytes(), def.Bytes()) < 0 {
+			def = addr
+			found = true
+		}
 	}
+	return def, found
+}
 
-	if err := backend.putKeyInfo(ki); err != nil {
+// NewAddress creates a new address and stores it. It never overwrites a key the backend
+// already holds; see DuplicateKeyPolicy.
+// Safe for concurrent access.
+func (backend *DSBackend) NewAddress() (address.Address, error) {
+	if err := backend.reserveAddresses(1); err != nil {
 		return address.Undef, err
 	}
+	ki, a, err := backend.newUniqueKeyInfo(nil)
 
-	return ki.Address()
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+	backend.creating--
+	if err != nil {
+		return address.Undef, err
+	}
+	// A concurrent caller may have stored the same key since it was checked.
+	if backend.hasKeyLocked(a) {
+		return address.Undef, ErrDuplicateKey
+	}
+
+	return backend.putKeyInfoLocked(ki)
 }
 
-func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
-	a, err := ki.Address()
+// NewAddresses creates n new addresses and stores them with a single batched
+// datastore write. Keys are generated before the lock is taken.
+func (backend *DSBackend) NewAddresses(n int) ([]address.Address, error) {
+	if err := backend.reserveAddresses(n); err != nil {
+		return nil, err
+	}
+	addrs, datums, err := backend.newKeys(n)
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+	backend.creating -= n
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		if backend.hasKeyLocked(a) {
+			return nil, ErrDuplicateKey
+		}
+	}
+
+	batch, err := backend.ds.Batch()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start batch")
+	}
+	created := backend.nextCreatedAtLocked()
+	for i, a := range addrs {
+		if err := batch.Put(ds.NewKey(a.String()), datums[i]); err != nil {
+			return nil, errors.Wrap(err, "failed to store new address")
+		}
+		if err := putCreatedAt(batch, a, created); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, errors.Wrap(err, "failed to store new addresses")
+	}
+
+	for _, a := range addrs {
+		backend.cache[a] = struct{}{}
+		backend.createdAt[a] = created
+		delete(backend.watchOnly, a)
+	}
+	return addrs, nil
+}
+
+// newKeys generates n keys and returns their addresses and marshaled key infos.
+func (backend *DSBackend) newKeys(n int) ([]address.Address, [][]byte, error) {
+	addrs := make([]address.Address, n)
+	datums := make([][]byte, n)
+	generated := make(map[address.Address]struct{}, n)
+	for i := range addrs {
+		ki, a, err := backend.newUniqueKeyInfo(generated)
+		if err != nil {
+			return nil, nil, err
+		}
+		addrs[i] = a
+		generated[a] = struct{}{}
+		if datums[i], err = ki.Marshal(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return addrs, datums, nil
+}
+
+// reserveAddresses counts n addresses as being created, or returns ErrAddressLimitReached if
+// that would exceed the backend's limit. The caller must decrement creating once the
+// addresses are stored or have failed.
+func (backend *DSBackend) reserveAddresses(n int) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	held := len(backend.cache) - len(backend.watchOnly)
+	if backend.maxAddresses > 0 && held+backend.creating+n > backend.maxAddresses {
+		return ErrAddressLimitReached
+	}
+	backend.creating += n
+	return nil
+}
+
+// newUniqueKeyInfo generates a new key and its address, which is neither held by the backend
+// nor in generated. A duplicate is handled as the backend's DuplicateKeyPolicy says.
+func (backend *DSBackend) newUniqueKeyInfo(generated map[address.Address]struct{}) (*types.KeyInfo, address.Address, error) {
+	for retries := 0; ; retries++ {
+		ki, err := backend.newKeyInfo()
+		if err != nil {
+			return nil, address.Undef, err
+		}
+		a, err := ki.Address()
+		if err != nil {
+			return nil, address.Undef, err
+		}
+
+		backend.lk.RLock()
+		held := backend.hasKeyLocked(a)
+		backend.lk.RUnlock()
+		_, dup := generated[a]
+		if !held && !dup {
+			return ki, a, nil
+		}
+		if backend.duplicateKeys == ErrorOnDuplicate || retries == maxDuplicateKeyRetries {
+			return nil, address.Undef, ErrDuplicateKey
+		}
+	}
+}
+
+// newKeyInfo generates a new secp256k1 private key, deriving it from the seed of an HD backend.
+func (backend *DSBackend) newKeyInfo() (*types.KeyInfo, error) {
+	if backend.hd != nil {
+		return backend.hd.nextKeyInfo()
+	}
+
+	var prv []byte
+	var err error
+	if backend.entropy != nil {
+		backend.entropyLk.Lock()
+		prv, err = crypto.DeriveKeyFromSeed(backend.entropy)
+		backend.entropyLk.Unlock()
+	} else {
+		prv, err = crypto.GenerateKey()
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	// TODO: maybe the above call should just return a keyinfo?
+	return &types.KeyInfo{
+		PrivateKey: prv,
+		Curve:      SECP256K1,
+	}, nil
+}
+
+func (backend *DSBackend) putKeyInfo(ki *types.KeyInfo) error {
 	backend.lk.Lock()
 	defer backend.lk.Unlock()
 
+	_, err := backend.putKeyInfoLocked(ki)
+	return err
+}
+
+// putKeyInfoLocked stores the key and returns its address, so callers need not derive
+// the public key a second time. The caller must hold the write lock.
+func (backend *DSBackend) putKeyInfoLocked(ki *types.KeyInfo) (address.Address, error) {
+	a, err := ki.Address()
+	if err != nil {
+		return address.Undef, err
+	}
+
 	kib, err := ki.Marshal()
 	if err != nil {
-		return err
+		return address.Undef, err
 	}
 
 	if err := backend.ds.Put(ds.NewKey(a.String()), kib); err != nil {
-		return errors.Wrap(err, "failed to store new address")
+		return address.Undef, errors.Wrap(err, "failed to store new address")
+	}
+	// A key stored again, such as by a repeated import, keeps its original creation time.
+	if _, ok := backend.createdAt[a]; !ok {
+		created := backend.nextCreatedAtLocked()
+		if err := putCreatedAt(backend.ds, a, created); err != nil {
+			return address.Undef, err
+		}
+		backend.createdAt[a] = created
 	}
 
 	backend.cache[a] = struct{}{}
+	delete(backend.watchOnly, a)
+	delete(backend.inFallback, a)
+	return a, nil
+}
+
+// nextCreatedAtLocked returns the creation time for keys stored now. It is never earlier
+// than a creation time already handed out, even if the wall clock steps backwards. The
+// caller must hold the write lock.
+func (backend *DSBackend) nextCreatedAtLocked() time.Time {
+	// Round(0) drops the monotonic reading, which does not survive being stored.
+	now := time.Now().Round(0)
+	if now.Before(backend.lastCreated) {
+		now = backend.lastCreated
+	}
+	backend.lastCreated = now
+	return now
+}
+
+func putCreatedAt(w ds.Write, addr address.Address, created time.Time) error {
+	datum, err := created.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := w.Put(createdAtKey(addr), datum); err != nil {
+		return errors.Wrap(err, "failed to store address creation time")
+	}
 	return nil
 }
 
-// SignBytes cryptographically signs `data` using the private key `priv`.
-func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func createdAtKey(addr address.Address) ds.Key {
+	return ds.NewKey(createdAtPrefix).ChildString(addr.String())
+}
+
+// CreatedAt returns the time at which the key for addr was created or imported. ok is false
+// for addresses without a stored key and for keys stored before creation times were recorded.
+// Safe for concurrent access.
+func (backend *DSBackend) CreatedAt(addr address.Address) (created time.Time, ok bool) {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	created, ok = backend.createdAt[addr]
+	return created, ok
+}
+
+// AddressesByCreation returns the same addresses as Addresses, oldest first. Addresses
+// without a creation time come last, in byte order.
+// Safe for concurrent access.
+func (backend *DSBackend) AddressesByCreation() []address.Address {
+	addrs := backend.Addresses()
+
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	sort.Slice(addrs, func(i, j int) bool {
+		ti, iok := backend.createdAt[addrs[i]]
+		tj, jok := backend.createdAt[addrs[j]]
+		if iok != jok {
+			return iok
+		}
+		if iok && !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return bytes.Compare(addrs[i].Bytes(), addrs[j].Bytes()) < 0
+	})
+	return addrs
+}
+
+// RotateAddress creates a new address to succeed old, which must be held by the backend,
+// and records the link so that Successor(old) returns it. If deprecate is true old is also
+// marked deprecated: it stays in the backend and can still sign, for obligations made with
+// it, but is no longer picked as the default address. An address may only be rotated once.
+// Safe for concurrent access.
+func (backend *DSBackend) RotateAddress(old address.Address, deprecate bool) (address.Address, error) {
+	if err := backend.reserveAddresses(1); err != nil {
+		return address.Undef, err
+	}
+	ki, a, err := backend.newUniqueKeyInfo(nil)
+
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+	backend.creating--
+	if err != nil {
+		return address.Undef, err
+	}
+	if _, ok := backend.cache[old]; !ok {
+		return address.Undef, errors.New("backend does not contain address")
+	}
+	if _, ok := backend.successors[old]; ok {
+		return address.Undef, ErrAlreadyRotated
+	}
+	// A concurrent caller may have stored the same key since it was checked.
+	if backend.hasKeyLocked(a) {
+		return address.Undef, ErrDuplicateKey
+	}
+
+	if _, err := backend.putKeyInfoLocked(ki); err != nil {
+		return address.Undef, err
+	}
+	if err := backend.ds.Put(successorKey(old), a.Bytes()); err != nil {
+		return address.Undef, errors.Wrap(err, "failed to store address successor")
+	}
+	backend.successors[old] = a
+	if deprecate {
+		if err := backend.ds.Put(deprecatedKey(old), []byte{}); err != nil {
+			return address.Undef, errors.Wrap(err, "failed to store address deprecation")
+		}
+		backend.deprecated[old] = struct{}{}
+	}
+	return a, nil
+}
+
+func successorKey(addr address.Address) ds.Key {
+	return ds.NewKey(successorPrefix).ChildString(addr.String())
+}
+
+func deprecatedKey(addr address.Address) ds.Key {
+	return ds.NewKey(deprecatedPrefix).ChildString(addr.String())
+}
+
+// Successor returns the address addr was rotated to by RotateAddress. ok is false if addr
+// has not been rotated.
+// Safe for concurrent access.
+func (backend *DSBackend) Successor(addr address.Address) (successor address.Address, ok bool) {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	successor, ok = backend.successors[addr]
+	return successor, ok
+}
+
+// IsDeprecated returns true if addr was deprecated when it was rotated.
+// Safe for concurrent access.
+func (backend *DSBackend) IsDeprecated(addr address.Address) bool {
+	backend.lk.RLock()
+	defer backend.lk.RUnlock()
+
+	_, ok := backend.deprecated[addr]
+	return ok
+}
+
+// SignBytes cryptographically signs `data` using the private key for `addr`. Local keys
+// are preferred; otherwise the remote signer registered for `addr` is used.
+func (backend *DSBackend) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
+	sig, err := backend.signBytes(data, addr)
+	if err == nil && backend.onSign != nil {
+		hash := blake2b.Sum256(data)
+		backend.onSign(addr, hash[:])
+	}
+	return sig, err
+}
+
+func (backend *DSBackend) signBytes(data []byte, addr address.Address) (types.RawSignature, error) {
+	backend.lk.RLock()
+	local := backend.hasKeyLocked(addr)
+	remote, hasRemote := backend.remoteSigners[addr]
+	backend.lk.RUnlock()
+
+	if !local && hasRemote {
+		sig, err := remote.RemoteSign(addr, data)
+		if err != nil {
+			return nil, errors.Wrap(err, "remote signer failed")
+		}
+		return externalSignature(sig, "remote signer")
+	}
+	if !local {
+		if provider := backend.signingProvider(addr); provider != nil {
+			sig, err := provider.Sign(addr, data)
+			if err != nil {
+				return nil, errors.Wrap(err, "key provider failed")
+			}
+			return externalSignature(sig, "key provider")
+		}
+	}
+
 	ki, err := backend.GetKeyInfo(addr)
 	if err != nil {
 		return nil, err
 	}
+	defer zeroize(ki.PrivateKey)
 
 	return wutil.Sign(ki.Key(), data)
 }
 
+// selfTestVector is the data SelfTest signs.
+var selfTestVector = []byte("go-filecoin wallet self-test")
+
+// SelfTest checks that the crypto stack the backend signs with works. It generates a
+// throwaway key in memory, signs a test vector with it, verifies the signature, checks a
+// signature over other data fails, and checks the address recovered from the signature is
+// the key's address. It neither reads nor writes the datastore, nor draws from the backend's
+// seed or entropy source, so it is cheap enough to run at startup.
+func (backend *DSBackend) SelfTest() error {
+	prv, err := crypto.GenerateKey()
+	if err != nil {
+		return errors.Wrapf(ErrSelfTestFailed, "generating key: %s", err)
+	}
+	defer zeroize(prv)
+
+	ki := &types.KeyInfo{PrivateKey: prv, Curve: SECP256K1}
+	addr, err := ki.Address()
+	if err != nil {
+		return errors.Wrapf(ErrSelfTestFailed, "deriving address: %s", err)
+	}
+
+	sig, err := wutil.Sign(prv, selfTestVector)
+	if err != nil {
+		return errors.Wrapf(ErrSelfTestFailed, "signing: %s", err)
+	}
+	hash := blake2b.Sum256(selfTestVector)
+	if !crypto.Verify(ki.PublicKey(), hash[:], sig) {
+		return errors.Wrap(ErrSelfTestFailed, "signature does not verify")
+	}
+	other := blake2b.Sum256(append([]byte("not "), selfTestVector...))
+	if crypto.Verify(ki.PublicKey(), other[:], sig) {
+		return errors.Wrap(ErrSelfTestFailed, "signature verifies over other data")
+	}
+
+	pk, err := wutil.Ecrecover(selfTestVector, sig)
+	if err != nil {
+		return errors.Wrapf(ErrSelfTestFailed, "recovering public key: %s", err)
+	}
+	recovered, err := address.NewSecp256k1Address(pk)
+	if err != nil {
+		return errors.Wrapf(ErrSelfTestFailed, "deriving recovered address: %s", err)
+	}
+	if recovered != addr {
+		return errors.Wrapf(ErrSelfTestFailed, "recovered address %s, expected %s", recovered, addr)
+	}
+	return nil
+}
+
+// externalSignature returns the raw bytes of a signature made outside the backend by source.
+func externalSignature(sig types.Signature, source string) (types.RawSignature, error) {
+	// Signed messages are currently always tagged as secp256k1.
+	if sig.Type != types.SigTypeSecp256k1 {
+		return nil, errors.Errorf("%s returned unsupported %s signature", source, sig.Type)
+	}
+	return sig.Data, nil
+}
+
+// DeleteAddress removes addr and any key held for it from the backend. The stored key
+// bytes are overwritten with zeros before they are deleted. This only clears the copy the
+// datastore returns, which for an in-memory datastore is the stored copy itself; copies
+// made elsewhere, such as by a datastore writing to disk or by callers of GetKeyInfo, are
+// not cleared.
+func (backend *DSBackend) DeleteAddress(addr address.Address) error {
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	if _, ok := backend.cache[addr]; !ok {
+		return errors.New("backend does not contain address")
+	}
+	if _, ok := backend.inFallback[addr]; ok {
+		return errors.New("cannot delete address stored only in the fallback datastore")
+	}
+
+	key := ds.NewKey(addr.String())
+	kib, err := backend.ds.Get(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch private key from backend")
+	}
+	zeroize(kib)
+	if err := backend.ds.Delete(key); err != nil {
+		return errors.Wrap(err, "failed to delete address")
+	}
+	if _, ok := backend.createdAt[addr]; ok {
+		if err := backend.ds.Delete(createdAtKey(addr)); err != nil {
+			return errors.Wrap(err, "failed to delete address creation time")
+		}
+	}
+
+	if _, ok := backend.successors[addr]; ok {
+		if err := backend.ds.Delete(successorKey(addr)); err != nil {
+			return errors.Wrap(err, "failed to delete address successor")
+		}
+	}
+	if _, ok := backend.deprecated[addr]; ok {
+		if err := backend.ds.Delete(deprecatedKey(addr)); err != nil {
+			return errors.Wrap(err, "failed to delete address deprecation")
+		}
+	}
+
+	delete(backend.cache, addr)
+	delete(backend.createdAt, addr)
+	delete(backend.successors, addr)
+	delete(backend.deprecated, addr)
+	delete(backend.watchOnly, addr)
+	delete(backend.remoteSigners, addr)
+	return nil
+}
+
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key `pk`.
-func (backend *DSBackend) Verify(data, pk []byte, sig types.Signature) bool {
+func (backend *DSBackend) Verify(data, pk []byte, sig types.RawSignature) bool {
 	return crypto.Verify(pk, data, sig)
 }
 
@@ -158,9 +1070,19 @@ func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, erro
 	if !backend.HasAddress(addr) {
 		return nil, errors.New("backend does not contain address")
 	}
+	backend.lk.RLock()
+	local := backend.hasKeyLocked(addr)
+	backend.lk.RUnlock()
+	if !local {
+		return nil, ErrNoLocalKey
+	}
 
 	// kib is a cbor of types.KeyInfo
-	kib, err := backend.ds.Get(ds.NewKey(addr.String()))
+	key := ds.NewKey(addr.String())
+	kib, err := backend.ds.Get(key)
+	if err == ds.ErrNotFound && backend.fallback != nil {
+		kib, err = backend.fallback.Get(key)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch private key from backend")
 	}
@@ -172,3 +1094,40 @@ func (backend *DSBackend) GetKeyInfo(addr address.Address) (*types.KeyInfo, erro
 
 	return ki, nil
 }
+
+// GetAllKeyPairs returns the key of every address whose private key the backend holds.
+// Watch-only addresses and addresses signed for by remote signers or key providers are left
+// out. It returns ErrBulkAccessDisabled unless the backend was constructed with
+// AllowBulkKeyAccess.
+func (backend *DSBackend) GetAllKeyPairs() (map[address.Address]*types.KeyInfo, error) {
+	if !backend.bulkKeyAccess {
+		return nil, ErrBulkAccessDisabled
+	}
+
+	backend.lk.RLock()
+	var addrs []address.Address
+	for addr := range backend.cache {
+		if backend.hasKeyLocked(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	backend.lk.RUnlock()
+
+	keys := make(map[address.Address]*types.KeyInfo, len(addrs))
+	for _, addr := range addrs {
+		ki, err := backend.GetKeyInfo(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get key for %s", addr)
+		}
+		keys[addr] = ki
+	}
+	return keys, nil
+}
+
+// zeroize overwrites b with zeros so key material does not linger in memory until it is
+// garbage collected.
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -1,14 +1,21 @@
 package wallet
 
 import (
+	"bytes"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/types"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
 func TestDSBackendSimple(t *testing.T) {
@@ -132,3 +139,679 @@ func TestDSBackendParallel(t *testing.T) {
 	wg.Wait()
 	assert.Len(t, fs.Addresses(), 10)
 }
+
+func TestDSBackendMaxAddresses(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("concurrent creators", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore(), MaxAddresses(5))
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		wg.Add(10)
+		for i := 0; i < 10; i++ {
+			go func() {
+				defer wg.Done()
+				_, err := fs.NewAddress()
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.Equal(t, ErrAddressLimitReached, err)
+			}
+		}
+		assert.Equal(t, 5, succeeded)
+		assert.Len(t, fs.Addresses(), 5)
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore(), MaxAddresses(5))
+		require.NoError(t, err)
+
+		_, err = fs.NewAddresses(3)
+		require.NoError(t, err)
+		_, err = fs.NewAddresses(3)
+		assert.Equal(t, ErrAddressLimitReached, err)
+		_, err = fs.NewAddresses(2)
+		require.NoError(t, err)
+		_, err = fs.NewAddress()
+		assert.Equal(t, ErrAddressLimitReached, err)
+	})
+}
+
+func TestDSBackendGetDefaultAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("errors on empty backend unless asked to create", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore())
+		require.NoError(t, err)
+
+		_, err = fs.GetDefaultAddress(ErrorIfMissing)
+		assert.Equal(t, ErrNoDefaultAddress, err)
+
+		addr, err := fs.GetDefaultAddress(CreateIfMissing)
+		require.NoError(t, err)
+		assert.True(t, fs.HasAddress(addr))
+
+		again, err := fs.GetDefaultAddress(ErrorIfMissing)
+		require.NoError(t, err)
+		assert.Equal(t, addr, again)
+	})
+
+	t.Run("concurrent callers create exactly one address", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore())
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		count := 10
+		addrs := make([]address.Address, count)
+		wg.Add(count)
+		for i := 0; i < count; i++ {
+			go func(i int) {
+				defer wg.Done()
+				addr, err := fs.GetDefaultAddress(CreateIfMissing)
+				assert.NoError(t, err)
+				addrs[i] = addr
+			}(i)
+		}
+		wg.Wait()
+
+		require.Len(t, fs.Addresses(), 1)
+		for _, addr := range addrs {
+			assert.Equal(t, fs.Addresses()[0], addr)
+		}
+	})
+}
+
+func TestDSBackendWatchOnly(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	owned, err := fs.NewAddress()
+	require.NoError(t, err)
+	watched := address.NewForTestGetter()()
+	require.NoError(t, fs.AddWatchOnly(watched))
+
+	assert.True(t, fs.HasAddress(owned))
+	assert.True(t, fs.CanSign(owned))
+	assert.True(t, fs.HasAddress(watched))
+	assert.False(t, fs.CanSign(watched))
+
+	_, err = fs.SignBytes([]byte("data"), watched)
+	assert.Equal(t, ErrNoLocalKey, err)
+
+	def, err := fs.GetDefaultAddress(ErrorIfMissing)
+	require.NoError(t, err)
+	assert.Equal(t, owned, def)
+
+	t.Log("watch-only status survives reloading the backend")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	assert.True(t, fs2.HasAddress(watched))
+	assert.False(t, fs2.CanSign(watched))
+	assert.True(t, fs2.CanSign(owned))
+}
+
+type fakeRemoteSigner struct {
+	ki    types.KeyInfo
+	calls int
+}
+
+func (s *fakeRemoteSigner) RemoteSign(addr address.Address, data []byte) (types.Signature, error) {
+	s.calls++
+	sig, err := wutil.Sign(s.ki.Key(), data)
+	if err != nil {
+		return types.Signature{}, err
+	}
+	return types.NewSecp256k1Signature(sig), nil
+}
+
+func TestDSBackendRemoteSigner(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+
+	remote := &fakeRemoteSigner{ki: types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())[0]}
+	remoteAddr, err := remote.ki.Address()
+	require.NoError(t, err)
+
+	_, err = fs.SignBytes([]byte("data"), remoteAddr)
+	assert.Error(t, err)
+	assert.False(t, fs.HasAddress(remoteAddr))
+
+	fs.AddRemoteSigner(remoteAddr, remote)
+	assert.True(t, fs.HasAddress(remoteAddr))
+	assert.True(t, fs.CanSign(remoteAddr))
+	assert.Contains(t, fs.Addresses(), remoteAddr)
+
+	data := []byte("data")
+	sig, err := fs.SignBytes(data, remoteAddr)
+	require.NoError(t, err)
+	assert.Equal(t, 1, remote.calls)
+	assert.True(t, types.IsValidSignature(data, remoteAddr, sig))
+
+	_, err = fs.GetKeyInfo(remoteAddr)
+	assert.Equal(t, ErrNoLocalKey, err)
+
+	t.Log("local keys take precedence over remote signers")
+	localAddr, err := fs.NewAddress()
+	require.NoError(t, err)
+	fs.AddRemoteSigner(localAddr, remote)
+	_, err = fs.SignBytes(data, localAddr)
+	require.NoError(t, err)
+	assert.Equal(t, 1, remote.calls)
+}
+
+func TestDSBackendGetAllKeyPairs(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	_, err = fs.NewAddress()
+	require.NoError(t, err)
+
+	_, err = fs.GetAllKeyPairs()
+	assert.Equal(t, ErrBulkAccessDisabled, err)
+
+	fs, err = NewDSBackend(ds, AllowBulkKeyAccess())
+	require.NoError(t, err)
+	_, err = fs.NewAddresses(2)
+	require.NoError(t, err)
+	owned := fs.Addresses()
+	require.NoError(t, fs.AddWatchOnly(address.NewForTestGetter()()))
+	remote := &fakeRemoteSigner{ki: types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())[0]}
+	remoteAddr, err := remote.ki.Address()
+	require.NoError(t, err)
+	fs.AddRemoteSigner(remoteAddr, remote)
+
+	keys, err := fs.GetAllKeyPairs()
+	require.NoError(t, err)
+	var addrs []address.Address
+	for addr, ki := range keys {
+		addrs = append(addrs, addr)
+		kiAddr, err := ki.Address()
+		require.NoError(t, err)
+		assert.Equal(t, addr, kiAddr)
+	}
+	assert.ElementsMatch(t, owned, addrs)
+}
+
+func TestDSBackendOnSign(t *testing.T) {
+	tf.UnitTest(t)
+
+	type signing struct {
+		addr address.Address
+		hash []byte
+	}
+	var signings []signing
+	fs, err := NewDSBackend(datastore.NewMapDatastore(), OnSign(func(addr address.Address, dataHash []byte) {
+		signings = append(signings, signing{addr, dataHash})
+	}))
+	require.NoError(t, err)
+
+	addr, err := fs.NewAddress()
+	require.NoError(t, err)
+
+	data := []byte("data")
+	_, err = fs.SignBytes(data, addr)
+	require.NoError(t, err)
+	require.Len(t, signings, 1)
+	assert.Equal(t, addr, signings[0].addr)
+	assert.NotContains(t, string(signings[0].hash), string(data))
+
+	_, err = fs.SignBytes(data, addr)
+	require.NoError(t, err)
+	require.Len(t, signings, 2)
+	assert.Equal(t, signings[0].hash, signings[1].hash)
+
+	_, err = fs.SignBytes([]byte("other"), addr)
+	require.NoError(t, err)
+	require.Len(t, signings, 3)
+	assert.NotEqual(t, signings[0].hash, signings[2].hash)
+
+	t.Log("failed signatures are not reported")
+	_, err = fs.SignBytes(data, address.TestAddress)
+	assert.Error(t, err)
+	assert.Len(t, signings, 3)
+}
+
+func TestDSBackendNewAddresses(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	addrs, err := fs.NewAddresses(5)
+	require.NoError(t, err)
+	require.Len(t, addrs, 5)
+	assert.Len(t, fs.Addresses(), 5)
+
+	t.Log("each address matches its stored key and can sign")
+	data := []byte("data")
+	for _, addr := range addrs {
+		ki, err := fs.GetKeyInfo(addr)
+		require.NoError(t, err)
+		kiAddr, err := ki.Address()
+		require.NoError(t, err)
+		assert.Equal(t, addr, kiAddr)
+
+		sig, err := fs.SignBytes(data, addr)
+		require.NoError(t, err)
+		assert.True(t, types.IsValidSignature(data, addr, sig))
+	}
+
+	t.Log("batched addresses are persisted")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	for _, addr := range addrs {
+		assert.True(t, fs2.HasAddress(addr))
+		assert.True(t, fs2.CanSign(addr))
+	}
+}
+
+func BenchmarkNewAddress(b *testing.B) {
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := fs.NewAddress()
+		require.NoError(b, err)
+	}
+}
+
+func BenchmarkNewAddresses(b *testing.B) {
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	_, err = fs.NewAddresses(b.N)
+	require.NoError(b, err)
+}
+
+func BenchmarkHasAddressMissing(b *testing.B) {
+	fs, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(b, err)
+	_, err = fs.NewAddresses(1000)
+	require.NoError(b, err)
+	missing := address.NewForTestGetter()()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if fs.HasAddress(missing) {
+			b.Fatal("found missing address")
+		}
+	}
+}
+
+func TestDSBackendDeleteAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	addr, err := fs.NewAddress()
+	require.NoError(t, err)
+	_, err = fs.SignBytes([]byte("data"), addr)
+	require.NoError(t, err)
+
+	stored, err := ds.Get(datastore.NewKey(addr.String()))
+	require.NoError(t, err)
+	require.NotEqual(t, make([]byte, len(stored)), stored)
+
+	require.NoError(t, fs.DeleteAddress(addr))
+	assert.Equal(t, make([]byte, len(stored)), stored)
+	assert.False(t, fs.HasAddress(addr))
+	_, err = fs.GetKeyInfo(addr)
+	assert.Error(t, err)
+
+	assert.Error(t, fs.DeleteAddress(addr))
+}
+
+func TestDSBackendFallback(t *testing.T) {
+	tf.UnitTest(t)
+
+	fallbackDS := datastore.NewMapDatastore()
+	old, err := NewDSBackend(fallbackDS)
+	require.NoError(t, err)
+	oldAddr, err := old.NewAddress()
+	require.NoError(t, err)
+
+	primaryDS := datastore.NewMapDatastore()
+	fs, err := NewDSBackendWithFallback(primaryDS, fallbackDS)
+	require.NoError(t, err)
+
+	t.Log("keys only in the fallback are readable and signable")
+	assert.True(t, fs.HasAddress(oldAddr))
+	assert.True(t, fs.CanSign(oldAddr))
+	ki, err := fs.GetKeyInfo(oldAddr)
+	require.NoError(t, err)
+	addr, err := ki.Address()
+	require.NoError(t, err)
+	assert.Equal(t, oldAddr, addr)
+
+	data := []byte("data")
+	sig, err := fs.SignBytes(data, oldAddr)
+	require.NoError(t, err)
+	assert.True(t, types.IsValidSignature(data, oldAddr, sig))
+
+	t.Log("new keys are only stored in the primary")
+	newAddr, err := fs.NewAddress()
+	require.NoError(t, err)
+	_, err = primaryDS.Get(datastore.NewKey(newAddr.String()))
+	assert.NoError(t, err)
+	_, err = fallbackDS.Get(datastore.NewKey(newAddr.String()))
+	assert.Equal(t, datastore.ErrNotFound, err)
+	_, err = primaryDS.Get(datastore.NewKey(oldAddr.String()))
+	assert.Equal(t, datastore.ErrNotFound, err)
+	assert.ElementsMatch(t, []address.Address{oldAddr, newAddr}, fs.Addresses())
+
+	t.Log("keys only in the fallback cannot be deleted")
+	assert.Error(t, fs.DeleteAddress(oldAddr))
+	_, err = fallbackDS.Get(datastore.NewKey(oldAddr.String()))
+	assert.NoError(t, err)
+
+	t.Log("the primary wins when both hold an address")
+	require.NoError(t, fs.ImportKey(ki))
+	both, err := NewDSBackendWithFallback(primaryDS, fallbackDS)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []address.Address{oldAddr, newAddr}, both.Addresses())
+}
+
+func TestDSBackendImportKeyInfo(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	ki := &types.KeyInfo{
+		PrivateKey: bytes.Repeat([]byte{1}, 32),
+		Curve:      SECP256K1,
+	}
+	addr, err := fs.ImportKeyInfo(ki)
+	require.NoError(t, err)
+	assert.Equal(t, "t1ksu3ktw4xhyaoltwr546b3epfs5wxxqfyyxipwi", addr.String())
+	assert.True(t, fs.CanSign(addr))
+
+	stored, err := fs.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.True(t, ki.Equals(stored))
+
+	t.Log("re-importing is a no-op")
+	again, err := fs.ImportKeyInfo(ki)
+	require.NoError(t, err)
+	assert.Equal(t, addr, again)
+	assert.Equal(t, []address.Address{addr}, fs.Addresses())
+
+	t.Log("unsupported key types are rejected")
+	_, err = fs.ImportKeyInfo(&types.KeyInfo{PrivateKey: ki.PrivateKey, Curve: "bls"})
+	assert.Equal(t, ErrUnsupportedKeyType, errors.Cause(err))
+}
+
+func TestDSBackendNamespace(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs1, err := NewDSBackend(ds, Namespace("one"))
+	require.NoError(t, err)
+	fs2, err := NewDSBackend(ds, Namespace("two"))
+	require.NoError(t, err)
+
+	addr1, err := fs1.NewAddress()
+	require.NoError(t, err)
+	addr2, err := fs2.NewAddress()
+	require.NoError(t, err)
+
+	has, err := ds.Has(datastore.NewKey("one").ChildString(addr1.String()))
+	require.NoError(t, err)
+	assert.True(t, has)
+
+	t.Log("reloaded backends only see their own namespace")
+	fs1, err = NewDSBackend(ds, Namespace("one"))
+	require.NoError(t, err)
+	fs2, err = NewDSBackend(ds, Namespace("two"))
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{addr1}, fs1.Addresses())
+	assert.Equal(t, []address.Address{addr2}, fs2.Addresses())
+	assert.False(t, fs1.HasAddress(addr2))
+	assert.False(t, fs2.HasAddress(addr1))
+
+	t.Log("a backend at the root ignores namespaced keys")
+	root, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	rootAddr, err := root.NewAddress()
+	require.NoError(t, err)
+	root, err = NewDSBackend(ds)
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{rootAddr}, root.Addresses())
+	fs1, err = NewDSBackend(ds, Namespace("one"))
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{addr1}, fs1.Addresses())
+
+	t.Log("namespaces named after address records are rejected")
+	for _, name := range []string{createdAtPrefix, successorPrefix, deprecatedPrefix, "created/nested"} {
+		_, err := NewDSBackend(ds, Namespace(name))
+		assert.Equal(t, ErrReservedNamespace, errors.Cause(err), name)
+	}
+	_, err = NewDSBackend(ds, Namespace("createdby"))
+	assert.NoError(t, err)
+}
+
+// repeatingReader returns the same bytes on every read.
+type repeatingReader []byte
+
+func (r repeatingReader) Read(p []byte) (int, error) {
+	return copy(p, r), nil
+}
+
+func TestDSBackendDuplicateKey(t *testing.T) {
+	tf.UnitTest(t)
+
+	seed := bytes.Repeat([]byte{1}, 32)
+
+	t.Run("deterministic entropy errors", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore(), Entropy(repeatingReader(seed)), OnDuplicateKey(ErrorOnDuplicate))
+		require.NoError(t, err)
+
+		addr, err := fs.NewAddress()
+		require.NoError(t, err)
+		ki, err := fs.GetKeyInfo(addr)
+		require.NoError(t, err)
+
+		_, err = fs.NewAddress()
+		assert.Equal(t, ErrDuplicateKey, err)
+		_, err = fs.NewAddresses(2)
+		assert.Equal(t, ErrDuplicateKey, err)
+
+		assert.Equal(t, []address.Address{addr}, fs.Addresses())
+		stored, err := fs.GetKeyInfo(addr)
+		require.NoError(t, err)
+		assert.True(t, ki.Equals(stored))
+	})
+
+	t.Run("random entropy retries", func(t *testing.T) {
+		other := bytes.Repeat([]byte{2}, 32)
+		entropy := bytes.NewReader(bytes.Join([][]byte{seed, seed, other}, nil))
+		fs, err := NewDSBackend(datastore.NewMapDatastore(), Entropy(entropy))
+		require.NoError(t, err)
+
+		addr1, err := fs.NewAddress()
+		require.NoError(t, err)
+		addr2, err := fs.NewAddress()
+		require.NoError(t, err)
+		assert.NotEqual(t, addr1, addr2)
+		assert.Len(t, fs.Addresses(), 2)
+	})
+
+	t.Run("retries are bounded", func(t *testing.T) {
+		fs, err := NewDSBackend(datastore.NewMapDatastore(), Entropy(repeatingReader(seed)))
+		require.NoError(t, err)
+
+		_, err = fs.NewAddress()
+		require.NoError(t, err)
+		_, err = fs.NewAddress()
+		assert.Equal(t, ErrDuplicateKey, err)
+		assert.Len(t, fs.Addresses(), 1)
+	})
+}
+
+func TestDSBackendCreatedAt(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	before := time.Now()
+	addr1, err := fs.NewAddress()
+	require.NoError(t, err)
+	addr2, err := fs.NewAddress()
+	require.NoError(t, err)
+	batch, err := fs.NewAddresses(2)
+	require.NoError(t, err)
+
+	created1, ok := fs.CreatedAt(addr1)
+	require.True(t, ok)
+	created2, ok := fs.CreatedAt(addr2)
+	require.True(t, ok)
+	created3, ok := fs.CreatedAt(batch[0])
+	require.True(t, ok)
+	assert.False(t, created1.Before(before.Round(0)))
+	assert.False(t, created2.Before(created1))
+	assert.False(t, created3.Before(created2))
+
+	watched := address.NewForTestGetter()()
+	require.NoError(t, fs.AddWatchOnly(watched))
+	_, ok = fs.CreatedAt(watched)
+	assert.False(t, ok)
+
+	t.Log("importing a held key keeps its creation time")
+	ki, err := fs.GetKeyInfo(addr1)
+	require.NoError(t, err)
+	require.NoError(t, fs.ImportKey(ki))
+	again, ok := fs.CreatedAt(addr1)
+	require.True(t, ok)
+	assert.True(t, created1.Equal(again))
+
+	t.Log("creation times survive a reload")
+	reloaded, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	reloadedCreated, ok := reloaded.CreatedAt(addr2)
+	require.True(t, ok)
+	assert.True(t, created2.Equal(reloadedCreated))
+	assert.Len(t, reloaded.Addresses(), 5)
+
+	byCreation := reloaded.AddressesByCreation()
+	require.Len(t, byCreation, 5)
+	assert.Equal(t, addr1, byCreation[0])
+	assert.Equal(t, watched, byCreation[4])
+
+	t.Log("deleting an address deletes its creation time")
+	require.NoError(t, reloaded.DeleteAddress(addr1))
+	_, ok = reloaded.CreatedAt(addr1)
+	assert.False(t, ok)
+	has, err := ds.Has(createdAtKey(addr1))
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+func TestDSBackendRotateAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	addrs, err := fs.NewAddresses(2)
+	require.NoError(t, err)
+	// Rotate the default address, so its deprecation changes the default.
+	old, err := fs.GetDefaultAddress(ErrorIfMissing)
+	require.NoError(t, err)
+	other := addrs[0]
+	if other == old {
+		other = addrs[1]
+	}
+
+	_, ok := fs.Successor(old)
+	assert.False(t, ok)
+
+	successor, err := fs.RotateAddress(old, true)
+	require.NoError(t, err)
+	assert.NotEqual(t, old, successor)
+	assert.True(t, fs.CanSign(successor))
+	got, ok := fs.Successor(old)
+	require.True(t, ok)
+	assert.Equal(t, successor, got)
+
+	t.Log("the deprecated address can still sign but is not the default")
+	assert.True(t, fs.IsDeprecated(old))
+	assert.True(t, fs.CanSign(old))
+	_, err = fs.SignBytes([]byte("data"), old)
+	require.NoError(t, err)
+	def, err := fs.GetDefaultAddress(ErrorIfMissing)
+	require.NoError(t, err)
+	assert.NotEqual(t, old, def)
+	assert.Contains(t, []address.Address{other, successor}, def)
+
+	t.Log("an address is rotated only once")
+	_, err = fs.RotateAddress(old, false)
+	assert.Equal(t, ErrAlreadyRotated, err)
+	_, err = fs.RotateAddress(address.TestAddress, false)
+	assert.Error(t, err)
+
+	t.Log("rotations survive a restart")
+	fs2, err := NewDSBackend(ds)
+	require.NoError(t, err)
+	got, ok = fs2.Successor(old)
+	require.True(t, ok)
+	assert.Equal(t, successor, got)
+	assert.True(t, fs2.IsDeprecated(old))
+	assert.Len(t, fs2.Addresses(), 3)
+
+	t.Log("rotating without deprecating keeps the address eligible")
+	next, err := fs2.RotateAddress(other, false)
+	require.NoError(t, err)
+	got, ok = fs2.Successor(other)
+	require.True(t, ok)
+	assert.Equal(t, next, got)
+	assert.False(t, fs2.IsDeprecated(other))
+
+	require.NoError(t, fs2.DeleteAddress(old))
+	_, ok = fs2.Successor(old)
+	assert.False(t, ok)
+	assert.False(t, fs2.IsDeprecated(old))
+}
+
+func TestDSBackendSelfTest(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewDSBackend(ds)
+	require.NoError(t, err)
+
+	require.NoError(t, fs.SelfTest())
+
+	t.Log("the self-test stores nothing")
+	assert.Empty(t, fs.Addresses())
+	res, err := ds.Query(query.Query{})
+	require.NoError(t, err)
+	entries, err := res.Rest()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
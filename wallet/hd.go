@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"sync"
+
+	secp256k1 "github.com/ipsn/go-secp256k1"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/crypto"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// hdPath is the BIP32 path, below the master key, under which HD backend keys are derived.
+// Every level is hardened; key i is derived at m/44'/461'/0'/0'/i'.
+var hdPath = []uint32{44, 461, 0, 0}
+
+const hardenedKeyStart = 0x80000000
+
+// ErrInvalidHDKey is returned in the vanishingly unlikely case that a seed or index does
+// not produce a valid secp256k1 private key.
+var ErrInvalidHDKey = errors.New("derived key is not a valid private key")
+
+// ErrNotHD is returned when asking a backend that does not derive its keys from a seed about
+// derived keys.
+var ErrNotHD = errors.New("backend does not derive keys from a seed")
+
+// hdKey is an extended private key: a secp256k1 private key and its BIP32 chain code.
+type hdKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+func newMasterHDKey(seed []byte) (hdKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(secp256k1.S256().Params().N) >= 0 {
+		return hdKey{}, ErrInvalidHDKey
+	}
+	return hdKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// hardenedChild derives the hardened child key at index i.
+func (k hdKey) hardenedChild(i uint32) (hdKey, error) {
+	data := make([]byte, 0, 1+crypto.PrivateKeyBytes+4)
+	data = append(data, 0)
+	data = append(data, k.key...)
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], i+hardenedKeyStart)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data) // nolint: errcheck
+	sum := mac.Sum(nil)
+
+	n := secp256k1.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return hdKey{}, ErrInvalidHDKey
+	}
+	child := il.Add(il, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return hdKey{}, ErrInvalidHDKey
+	}
+
+	key := make([]byte, crypto.PrivateKeyBytes)
+	blob := child.Bytes()
+	copy(key[crypto.PrivateKeyBytes-len(blob):], blob)
+	return hdKey{key: key, chainCode: sum[32:]}, nil
+}
+
+// hdKeychain derives the sequence of keys handed out by an HD backend.
+type hdKeychain struct {
+	lk   sync.Mutex
+	root hdKey // key at hdPath
+	next uint32
+}
+
+func newHDKeychain(seed []byte) (*hdKeychain, error) {
+	k, err := newMasterHDKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range hdPath {
+		if k, err = k.hardenedChild(i); err != nil {
+			return nil, err
+		}
+	}
+	return &hdKeychain{root: k}, nil
+}
+
+// keyInfo returns the key at index i.
+func (chain *hdKeychain) keyInfo(i uint32) (*types.KeyInfo, error) {
+	k, err := chain.root.hardenedChild(i)
+	if err != nil {
+		return nil, err
+	}
+	return &types.KeyInfo{PrivateKey: k.key, Curve: SECP256K1}, nil
+}
+
+// nextKeyInfo returns the key at the next unused index.
+func (chain *hdKeychain) nextKeyInfo() (*types.KeyInfo, error) {
+	chain.lk.Lock()
+	defer chain.lk.Unlock()
+
+	ki, err := chain.keyInfo(chain.next)
+	if err != nil {
+		return nil, err
+	}
+	chain.next++
+	return ki, nil
+}
+
+// NewHDBackend constructs a backend on ds whose new addresses are derived deterministically
+// from seed, so that they can be recovered from the seed alone. Addresses already derived
+// into ds are not handed out again.
+func NewHDBackend(ds repo.Datastore, seed []byte, options ...DSBackendOption) (*DSBackend, error) {
+	backend, err := NewDSBackend(ds, options...)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := newHDKeychain(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for ; ; chain.next++ {
+		ki, err := chain.keyInfo(chain.next)
+		if err != nil {
+			return nil, err
+		}
+		a, err := ki.Address()
+		if err != nil {
+			return nil, err
+		}
+		if !backend.HasAddress(a) {
+			break
+		}
+	}
+
+	backend.hd = chain
+	return backend, nil
+}
+
+// PreviewDerivedAddress returns the address of the key at HD index i, which is the address
+// the backend's NewAddress hands out once it reaches index i. Nothing is stored, so this can
+// be used to show an address before it is created. It returns ErrNotHD if the backend was not
+// constructed with NewHDBackend.
+func (backend *DSBackend) PreviewDerivedAddress(i uint32) (address.Address, error) {
+	if backend.hd == nil {
+		return address.Undef, ErrNotHD
+	}
+	ki, err := backend.hd.keyInfo(i)
+	if err != nil {
+		return address.Undef, err
+	}
+	defer zeroize(ki.PrivateKey)
+	return ki.Address()
+}
@@ -0,0 +1,18 @@
+package wallet
+
+import (
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+)
+
+// NewMemBackend returns a backend that keeps its keys in memory only, for tests and nodes
+// that need not remember their addresses. It is a DSBackend over an in-memory datastore,
+// so it accepts the same options and works with everything a DSBackend does.
+func NewMemBackend(options ...DSBackendOption) *DSBackend {
+	backend, err := NewDSBackend(dss.MutexWrap(datastore.NewMapDatastore()), options...)
+	if err != nil {
+		// Loading an empty datastore cannot fail.
+		panic(err)
+	}
+	return backend
+}
@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
+)
+
+func TestMemBackend(t *testing.T) {
+	tf.UnitTest(t)
+
+	mb := NewMemBackend()
+	assert.Empty(t, mb.Addresses())
+
+	addr, err := mb.NewAddress()
+	require.NoError(t, err)
+	assert.True(t, mb.HasAddress(addr))
+	assert.True(t, mb.CanSign(addr))
+
+	data := []byte("data")
+	sig, err := mb.SignBytes(data, addr)
+	require.NoError(t, err)
+	ki, err := mb.GetKeyInfo(addr)
+	require.NoError(t, err)
+	valid, err := wutil.Verify(ki.PublicKey(), data, sig)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	t.Log("state is not shared between backends")
+	assert.False(t, NewMemBackend().HasAddress(addr))
+}
@@ -0,0 +1,35 @@
+package wallet
+
+import (
+	"github.com/pkg/errors"
+	bip39 "github.com/tyler-smith/go-bip39"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+// mnemonicEntropyBits is the entropy of a generated mnemonic, which gives 24 words.
+const mnemonicEntropyBits = 256
+
+// ErrInvalidMnemonic is returned when a mnemonic phrase has an unknown word, the wrong
+// number of words or a bad checksum.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic phrase")
+
+// GenerateMnemonic returns a new random 24 word BIP39 mnemonic phrase.
+func GenerateMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to generate entropy")
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// NewHDBackendFromMnemonic constructs an HD backend on ds from a BIP39 mnemonic phrase. The
+// seed is the BIP39 seed of the phrase with an empty passphrase, so the backend derives the
+// same addresses as NewHDBackend given that seed.
+func NewHDBackendFromMnemonic(ds repo.Datastore, mnemonic string, options ...DSBackendOption) (*DSBackend, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidMnemonic, err.Error())
+	}
+	return NewHDBackend(ds, seed, options...)
+}
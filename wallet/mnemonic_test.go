@@ -0,0 +1,129 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bip39 "github.com/tyler-smith/go-bip39"
+
+	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
+)
+
+func TestHDKeyDerivation(t *testing.T) {
+	tf.UnitTest(t)
+
+	// BIP32 test vector 1, chain m/0'.
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+	master, err := newMasterHDKey(seed)
+	require.NoError(t, err)
+	child, err := master.hardenedChild(0)
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", hex.EncodeToString(child.key))
+	assert.Equal(t, "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141", hex.EncodeToString(child.chainCode))
+}
+
+func TestHDBackend(t *testing.T) {
+	tf.UnitTest(t)
+
+	seed := []byte("an hd backend test seed of some length")
+	ds := datastore.NewMapDatastore()
+	fs, err := NewHDBackend(ds, seed)
+	require.NoError(t, err)
+
+	addr0, err := fs.NewAddress()
+	require.NoError(t, err)
+	addr1, err := fs.NewAddress()
+	require.NoError(t, err)
+	assert.NotEqual(t, addr0, addr1)
+
+	t.Log("the same seed derives the same addresses")
+	other, err := NewHDBackend(datastore.NewMapDatastore(), seed)
+	require.NoError(t, err)
+	addr, err := other.NewAddress()
+	require.NoError(t, err)
+	assert.Equal(t, addr0, addr)
+
+	t.Log("reopening the datastore continues after the derived addresses")
+	fs, err = NewHDBackend(ds, seed)
+	require.NoError(t, err)
+	addr2, err := fs.NewAddress()
+	require.NoError(t, err)
+	assert.NotEqual(t, addr0, addr2)
+	assert.NotEqual(t, addr1, addr2)
+	assert.Len(t, fs.Addresses(), 3)
+}
+
+func TestHDBackendPreviewDerivedAddress(t *testing.T) {
+	tf.UnitTest(t)
+
+	ds := datastore.NewMapDatastore()
+	fs, err := NewHDBackend(ds, []byte("an hd backend test seed of some length"))
+	require.NoError(t, err)
+
+	preview, err := fs.PreviewDerivedAddress(3)
+	require.NoError(t, err)
+	again, err := fs.PreviewDerivedAddress(3)
+	require.NoError(t, err)
+	assert.Equal(t, preview, again)
+
+	t.Log("previewing stores nothing")
+	assert.Empty(t, fs.Addresses())
+	results, err := ds.Query(query.Query{})
+	require.NoError(t, err)
+	entries, err := results.Rest()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	t.Log("the address is the one derived at index 3")
+	addrs, err := fs.NewAddresses(4)
+	require.NoError(t, err)
+	assert.Equal(t, preview, addrs[3])
+
+	t.Log("backends without a seed have nothing to preview")
+	random, err := NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	_, err = random.PreviewDerivedAddress(0)
+	assert.Equal(t, ErrNotHD, err)
+}
+
+func TestMnemonic(t *testing.T) {
+	tf.UnitTest(t)
+
+	mnemonic, err := GenerateMnemonic()
+	require.NoError(t, err)
+	assert.Len(t, strings.Fields(mnemonic), 24)
+
+	fs, err := NewHDBackendFromMnemonic(datastore.NewMapDatastore(), mnemonic)
+	require.NoError(t, err)
+	addr, err := fs.NewAddress()
+	require.NoError(t, err)
+
+	t.Log("the mnemonic re-imports to the same addresses")
+	reimported, err := NewHDBackendFromMnemonic(datastore.NewMapDatastore(), mnemonic)
+	require.NoError(t, err)
+	reimportedAddr, err := reimported.NewAddress()
+	require.NoError(t, err)
+	assert.Equal(t, addr, reimportedAddr)
+
+	t.Log("the mnemonic derives the same addresses as its raw seed")
+	raw, err := NewHDBackend(datastore.NewMapDatastore(), bip39.NewSeed(mnemonic, ""))
+	require.NoError(t, err)
+	rawAddr, err := raw.NewAddress()
+	require.NoError(t, err)
+	assert.Equal(t, addr, rawAddr)
+
+	t.Log("a phrase with a bad checksum is rejected")
+	badChecksum := strings.Repeat("abandon ", 23) + "abandon"
+	_, err = NewHDBackendFromMnemonic(datastore.NewMapDatastore(), badChecksum)
+	assert.Equal(t, ErrInvalidMnemonic, errors.Cause(err))
+
+	_, err = NewHDBackendFromMnemonic(datastore.NewMapDatastore(), "not a mnemonic")
+	assert.Equal(t, ErrInvalidMnemonic, errors.Cause(err))
+}
@@ -128,13 +128,13 @@ func TestBadFrom(t *testing.T) {
 	msg := types.NewMessage(addr, addr, 1, nil, "", nil)
 	meteredMsg := types.NewMeteredMessage(*msg, types.NewGasPrice(0), types.NewGasUnits(0))
 	// Can't use NewSignedMessage constructor as it always signs with msg.From.
-	bmsg, err := meteredMsg.Marshal()
+	bmsg, err := meteredMsg.SigningBytes()
 	require.NoError(t, err)
 	sig, err := fs.SignBytes(bmsg, addr2) // sign with addr != msg.From
 	require.NoError(t, err)
 	smsg := &types.SignedMessage{
 		MeteredMessage: *meteredMsg,
-		Signature:      sig,
+		Signature:      types.NewSecp256k1Signature(sig),
 	}
 
 	assert.False(t, smsg.VerifySignature())
@@ -149,7 +149,7 @@ func TestSignedMessageBadSignature(t *testing.T) {
 	smsg, err := types.NewSignedMessage(*msg, fs, types.NewGasPrice(0), types.NewGasUnits(0))
 	require.NoError(t, err)
 
-	smsg.Signature[0] = smsg.Signature[0] ^ 0xFF
+	smsg.Signature.Data[0] = smsg.Signature.Data[0] ^ 0xFF
 	assert.False(t, smsg.VerifySignature())
 }
 
@@ -2,11 +2,13 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
 	"sync"
 
+	"github.com/minio/blake2b-simd"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -17,6 +19,9 @@ import (
 var (
 	// ErrUnknownAddress is returned when the given address is not stored in this wallet.
 	ErrUnknownAddress = errors.New("unknown address")
+	// ErrNoSuitableAddress is returned when no address the wallet can sign for has the
+	// balance asked for.
+	ErrNoSuitableAddress = errors.New("no address with sufficient balance")
 )
 
 // Wallet manages the locally stored addresses.
@@ -48,6 +53,14 @@ func (w *Wallet) HasAddress(a address.Address) bool {
 	return err == nil
 }
 
+// CanSign checks if the private key for the given address is stored, so that the
+// wallet can sign on its behalf. Unlike HasAddress it is false for watch-only addresses.
+// Safe for concurrent access.
+func (w *Wallet) CanSign(a address.Address) bool {
+	backend, err := w.Find(a)
+	return err == nil && backend.CanSign(a)
+}
+
 // Find searches through all backends and returns the one storing the passed
 // in address.
 // Safe for concurrent access.
@@ -86,6 +99,35 @@ func (w *Wallet) Addresses() []address.Address {
 	return out
 }
 
+// SelectAddressWithBalance returns the address with the largest balance of those the wallet
+// can sign for, provided it holds at least min. Balances are looked up with balanceOf, so
+// the wallet need not know about chain state. Of addresses with equal balances the first
+// in the order of Addresses is returned. If no address qualifies it returns
+// ErrNoSuitableAddress.
+func (w *Wallet) SelectAddressWithBalance(ctx context.Context, min *types.AttoFIL, balanceOf func(address.Address) *types.AttoFIL) (address.Address, error) {
+	best := address.Undef
+	var bestBalance *types.AttoFIL
+	for _, addr := range w.Addresses() {
+		if err := ctx.Err(); err != nil {
+			return address.Undef, err
+		}
+		if !w.CanSign(addr) {
+			continue
+		}
+		balance := balanceOf(addr)
+		if balance.LessThan(min) {
+			continue
+		}
+		if bestBalance == nil || balance.GreaterThan(bestBalance) {
+			best, bestBalance = addr, balance
+		}
+	}
+	if bestBalance == nil {
+		return address.Undef, ErrNoSuitableAddress
+	}
+	return best, nil
+}
+
 // Backends returns backends by their kind.
 func (w *Wallet) Backends(kind reflect.Type) []Backend {
 	w.lk.Lock()
@@ -98,7 +140,7 @@ func (w *Wallet) Backends(kind reflect.Type) []Backend {
 
 // SignBytes cryptographically signs `data` using the private key corresponding to
 // address `addr`
-func (w *Wallet) SignBytes(data []byte, addr address.Address) (types.Signature, error) {
+func (w *Wallet) SignBytes(data []byte, addr address.Address) (types.RawSignature, error) {
 	// Check that we are storing the address to sign for.
 	backend, err := w.Find(addr)
 	if err != nil {
@@ -113,6 +155,10 @@ func (w *Wallet) GetAddressForPubKey(pk []byte) (address.Address, error) {
 	addrs := w.Addresses()
 	for _, addr = range addrs {
 		testPk, err := w.GetPubKeyForAddress(addr)
+		if errors.Cause(err) == ErrNoLocalKey {
+			// Watch-only and remotely signed addresses have no key info to compare against.
+			continue
+		}
 		if err != nil {
 			return addr, errors.New("could not fetch public key")
 		}
@@ -126,7 +172,7 @@ func (w *Wallet) GetAddressForPubKey(pk []byte) (address.Address, error) {
 
 // Verify cryptographically verifies that 'sig' is the signed hash of 'data' with
 // the public key `pk`.
-func (w *Wallet) Verify(data []byte, pk []byte, sig types.Signature) (bool, error) {
+func (w *Wallet) Verify(data []byte, pk []byte, sig types.RawSignature) (bool, error) {
 	return wutil.Verify(pk, data, sig)
 }
 
@@ -134,7 +180,7 @@ func (w *Wallet) Verify(data []byte, pk []byte, sig types.Signature) (bool, erro
 // signature from data.
 // Note: The returned public key should not be used to verify `data` is valid
 // since a public key may have N private key pairs
-func (w *Wallet) Ecrecover(data []byte, sig types.Signature) ([]byte, error) {
+func (w *Wallet) Ecrecover(data []byte, sig types.RawSignature) ([]byte, error) {
 	return wutil.Ecrecover(data, sig)
 }
 
@@ -160,6 +206,22 @@ func (w *Wallet) GetPubKeyForAddress(addr address.Address) ([]byte, error) {
 	return info.PublicKey(), nil
 }
 
+// fingerprintLength is the number of bytes of the public key hash encoded in a fingerprint.
+const fingerprintLength = 10
+
+// Fingerprint returns a short identifier for the key of the given address, which is the
+// base32 encoding of a prefix of the hash of its public key. It is stable across wallets
+// holding the same key and shorter than an address, which makes it handy for logs.
+func (w *Wallet) Fingerprint(addr address.Address) (string, error) {
+	pk, err := w.GetPubKeyForAddress(addr)
+	if err != nil {
+		return "", err
+	}
+
+	hash := blake2b.Sum256(pk)
+	return address.AddressEncoding.WithPadding(-1).EncodeToString(hash[:fingerprintLength]), nil
+}
+
 // NewKeyInfo creates a new KeyInfo struct in the wallet backend and returns it
 func (w *Wallet) NewKeyInfo() (*types.KeyInfo, error) {
 	newAddr, err := NewAddress(w)
@@ -171,16 +233,21 @@ func (w *Wallet) NewKeyInfo() (*types.KeyInfo, error) {
 }
 
 func (w *Wallet) keyInfoForAddr(addr address.Address) (*types.KeyInfo, error) {
-	backend, err := w.Find(addr)
+	info, err := w.GetKeyInfo(addr)
 	if err != nil {
 		return &types.KeyInfo{}, err
 	}
+	return info, nil
+}
 
-	info, err := backend.GetKeyInfo(addr)
+// GetKeyInfo returns the stored KeyInfo for addr, which carries the key's curve along with
+// its private key. It errors if no backend holds addr or its backend holds no private key for it.
+func (w *Wallet) GetKeyInfo(addr address.Address) (*types.KeyInfo, error) {
+	backend, err := w.Find(addr)
 	if err != nil {
-		return &types.KeyInfo{}, err
+		return nil, err
 	}
-	return info, nil
+	return backend.GetKeyInfo(addr)
 }
 
 // Import adds the given keyinfos to the wallet
@@ -2,9 +2,11 @@ package wallet_test
 
 import (
 	"bytes"
+	"context"
 	"testing"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -13,6 +15,7 @@ import (
 	tf "github.com/filecoin-project/go-filecoin/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/wallet"
+	wutil "github.com/filecoin-project/go-filecoin/wallet/util"
 )
 
 func TestWalletSimple(t *testing.T) {
@@ -66,6 +69,130 @@ func TestWalletSimple(t *testing.T) {
 	}
 }
 
+func TestWalletCanSign(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	w := wallet.New(fs)
+
+	owned, err := fs.NewAddress()
+	require.NoError(t, err)
+	assert.True(t, w.HasAddress(owned))
+	assert.True(t, w.CanSign(owned))
+
+	addrGetter := address.NewForTestGetter()
+	watched := addrGetter()
+	require.NoError(t, fs.AddWatchOnly(watched))
+	assert.True(t, w.HasAddress(watched))
+	assert.False(t, w.CanSign(watched))
+
+	unknown := addrGetter()
+	assert.False(t, w.HasAddress(unknown))
+	assert.False(t, w.CanSign(unknown))
+}
+
+func TestWalletSelectAddressWithBalance(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	fs, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	w := wallet.New(fs)
+
+	addrs, err := fs.NewAddresses(3)
+	require.NoError(t, err)
+	watched := address.NewForTestGetter()()
+	require.NoError(t, fs.AddWatchOnly(watched))
+
+	balances := map[address.Address]*types.AttoFIL{
+		addrs[0]: types.NewAttoFILFromFIL(5),
+		addrs[1]: types.NewAttoFILFromFIL(20),
+		addrs[2]: types.NewAttoFILFromFIL(10),
+		watched:  types.NewAttoFILFromFIL(100),
+	}
+	balanceOf := func(addr address.Address) *types.AttoFIL {
+		return balances[addr]
+	}
+
+	selected, err := w.SelectAddressWithBalance(ctx, types.NewAttoFILFromFIL(8), balanceOf)
+	require.NoError(t, err)
+	assert.Equal(t, addrs[1], selected)
+
+	selected, err = w.SelectAddressWithBalance(ctx, types.NewAttoFILFromFIL(20), balanceOf)
+	require.NoError(t, err)
+	assert.Equal(t, addrs[1], selected)
+
+	_, err = w.SelectAddressWithBalance(ctx, types.NewAttoFILFromFIL(21), balanceOf)
+	assert.Equal(t, wallet.ErrNoSuitableAddress, err)
+}
+
+func TestWalletGetKeyInfo(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	w := wallet.New(fs)
+
+	addr, err := fs.NewAddress()
+	require.NoError(t, err)
+	ki, err := w.GetKeyInfo(addr)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.SECP256K1, ki.Type())
+	kiAddr, err := ki.Address()
+	require.NoError(t, err)
+	assert.Equal(t, addr, kiAddr)
+
+	exported, err := w.Export([]address.Address{addr})
+	require.NoError(t, err)
+	assert.Equal(t, exported[0].Key(), ki.Key())
+
+	addrGetter := address.NewForTestGetter()
+	watched := addrGetter()
+	require.NoError(t, fs.AddWatchOnly(watched))
+	_, err = w.GetKeyInfo(watched)
+	assert.Equal(t, wallet.ErrNoLocalKey, err)
+
+	_, err = w.GetKeyInfo(addrGetter())
+	assert.Error(t, err)
+}
+
+func TestWalletFingerprint(t *testing.T) {
+	tf.UnitTest(t)
+
+	kis := types.MustGenerateKeyInfo(2, types.GenerateKeyInfoSeed())
+
+	newWallet := func() *wallet.Wallet {
+		fs, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+		require.NoError(t, err)
+		w := wallet.New(fs)
+		_, err = w.Import([]*types.KeyInfo{&kis[0], &kis[1]})
+		require.NoError(t, err)
+		return w
+	}
+	w1 := newWallet()
+	w2 := newWallet()
+
+	addr0, err := kis[0].Address()
+	require.NoError(t, err)
+	addr1, err := kis[1].Address()
+	require.NoError(t, err)
+
+	fp1, err := w1.Fingerprint(addr0)
+	require.NoError(t, err)
+	fp2, err := w2.Fingerprint(addr0)
+	require.NoError(t, err)
+	assert.Equal(t, fp1, fp2)
+	assert.Len(t, fp1, 16)
+
+	other, err := w1.Fingerprint(addr1)
+	require.NoError(t, err)
+	assert.NotEqual(t, fp1, other)
+
+	_, err = w1.Fingerprint(address.NewForTestGetter()())
+	assert.Error(t, err)
+}
+
 func TestSimpleSignAndVerify(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -223,6 +350,75 @@ func TestWallet_CreateTicket(t *testing.T) {
 		badPubKey := []byte{0xf0}
 		ticket, err := consensus.CreateTicket(proof, badPubKey, w)
 		assert.Error(t, err, "t, SignBytes error in CreateTicket: public key not found")
-		assert.Equal(t, types.Signature(nil), ticket)
+		assert.Equal(t, types.RawSignature(nil), ticket)
 	})
 }
+
+// fakeKeyProvider holds keys for the addresses it lists, except those in listOnly.
+type fakeKeyProvider struct {
+	keys     map[address.Address]types.KeyInfo
+	listOnly []address.Address
+}
+
+func (p *fakeKeyProvider) List() []address.Address {
+	addrs := append([]address.Address{}, p.listOnly...)
+	for addr := range p.keys {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (p *fakeKeyProvider) CanSign(addr address.Address) bool {
+	_, ok := p.keys[addr]
+	return ok
+}
+
+func (p *fakeKeyProvider) Sign(addr address.Address, data []byte) (types.Signature, error) {
+	ki, ok := p.keys[addr]
+	if !ok {
+		return types.Signature{}, errors.New("unknown address")
+	}
+	sig, err := wutil.Sign(ki.Key(), data)
+	if err != nil {
+		return types.Signature{}, err
+	}
+	return types.NewSecp256k1Signature(sig), nil
+}
+
+func TestWalletKeyProvider(t *testing.T) {
+	tf.UnitTest(t)
+
+	fs, err := wallet.NewDSBackend(datastore.NewMapDatastore())
+	require.NoError(t, err)
+	localAddr, err := fs.NewAddress()
+	require.NoError(t, err)
+
+	ki := types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())[0]
+	providedAddr, err := ki.Address()
+	require.NoError(t, err)
+	listedAddr := address.NewForTestGetter()()
+	provider := &fakeKeyProvider{
+		keys:     map[address.Address]types.KeyInfo{providedAddr: ki},
+		listOnly: []address.Address{listedAddr},
+	}
+	fs.AddKeyProvider(provider)
+
+	w := wallet.New(fs)
+	assert.ElementsMatch(t, []address.Address{localAddr, providedAddr, listedAddr}, w.Addresses())
+	assert.True(t, w.HasAddress(providedAddr))
+	assert.True(t, w.CanSign(providedAddr))
+
+	data := []byte("data")
+	sig, err := w.SignBytes(data, providedAddr)
+	require.NoError(t, err)
+	assert.True(t, types.IsValidSignature(data, providedAddr, sig))
+
+	_, err = w.GetKeyInfo(providedAddr)
+	assert.Equal(t, wallet.ErrNoLocalKey, err)
+
+	t.Log("listed addresses the provider cannot sign for are not signable")
+	assert.True(t, w.HasAddress(listedAddr))
+	assert.False(t, w.CanSign(listedAddr))
+	_, err = w.SignBytes(data, listedAddr)
+	assert.Error(t, err)
+}